@@ -23,13 +23,22 @@ const (
 	ATClientSynced              = "client_synced"
 	ATNoteDeployed              = "note_deployed"
 	ATNoteUndeployed            = "note_undeployed"
+	ATNoteDeleted               = "note_deleted"
+	ATNoteRevisionRestored      = "note_revision_restored"
+	ATNoteRepublished           = "note_republished"
+	ATFolderDeployed            = "folder_deployed"
+	ATFolderUndeployed          = "folder_undeployed"
+	ATNoteAutoDeployed          = "note_auto_deployed"
+	ATNoteAutoUndeployed        = "note_auto_undeployed"
 )
 
 func isValidActivityType(at string) bool {
 	switch at {
 	case ATAccountCreated, ATNewLogin, ATClientAuthorized,
 		ATProfileNameUpdated, ATProfileDescriptionUpdated, ATProfilePictureUpdated,
-		ATClientSynced, ATNoteDeployed, ATNoteUndeployed:
+		ATClientSynced, ATNoteDeployed, ATNoteUndeployed, ATNoteDeleted, ATNoteRevisionRestored,
+		ATNoteRepublished, ATFolderDeployed, ATFolderUndeployed,
+		ATNoteAutoDeployed, ATNoteAutoUndeployed:
 		return true
 	default:
 		return false
@@ -19,4 +19,10 @@ func SetAPIGroup(group fiber.Router) {
 	setBinaryGroup(binariesRouter)
 	statsRouter := v1.Group("/stats")
 	setStatsGroup(statsRouter)
+	feedsRouter := v1.Group("/feeds")
+	setFeedsGroup(feedsRouter)
+	domainRouter := v1.Group("/domain")
+	setDomainGroup(domainRouter)
+	collectionsRouter := v1.Group("/collections")
+	setCollectionsGroup(collectionsRouter)
 }
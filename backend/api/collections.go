@@ -0,0 +1,300 @@
+package api
+
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/backend/session"
+	"github.com/shashwtd/webnotes/database"
+)
+
+func setCollectionsGroup(router fiber.Router) {
+	// /api/v1/collections
+	sessionMiddleware := session.RequiredSessionMiddleware()
+	optionalSM := session.OptionalSessionMiddleware() // owners see their notes that are not deployed
+
+	router.Get("/", sessionMiddleware, getCollections())              // GET /api/v1/collections (list the current user's collections)
+	router.Post("/", sessionMiddleware, createCollection())           // POST /api/v1/collections (create a collection with a name, slug and description)
+	router.Put("/:id", sessionMiddleware, updateCollection())         // PUT /api/v1/collections/:id (rename a collection, change its slug or description)
+	router.Delete("/:id", sessionMiddleware, deleteCollection())      // DELETE /api/v1/collections/:id (delete a collection, not its notes)
+	router.Put("/:id/notes", sessionMiddleware, setCollectionNotes()) // PUT /api/v1/collections/:id/notes (set the notes of a collection, in order)
+	router.Get("/:username", optionalSM, listPublicCollections())     // GET /api/v1/collections/:username (list the collections of a user as readers see them)
+	router.Get("/:username/:slug", optionalSM, getPublicCollection()) // GET /api/v1/collections/:username/:slug (get a collection of a user with its deployed notes, in order)
+}
+
+// collectionMembership is a collection a note is part of, with where the note is among the notes
+// of the collection the reader can see.
+type collectionMembership struct {
+	ID       string          `json:"id"`
+	Slug     string          `json:"slug"`
+	Name     string          `json:"name"`
+	Position int             `json:"position"` // from 1
+	Count    int             `json:"count"`
+	Previous *collectionLink `json:"previous,omitempty"`
+	Next     *collectionLink `json:"next,omitempty"`
+}
+
+// collectionLink is a note before or after another in a collection.
+type collectionLink struct {
+	ID    string `json:"id"`
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+}
+
+// visibleNotes returns the notes of a user the reader can see by id: every note for the owner,
+// the public notes as readers see them for the others.
+func visibleNotes(c *fiber.Ctx, userID string) (map[string]database.Note, error) {
+	user, _ := c.Locals("user").(*database.User)
+	owner := user != nil && user.ID == userID
+	var notes []database.Note
+	var err error
+	if owner {
+		notes, err = env.Default.Database.ListNotes(userID, database.ListOptions{})
+	} else {
+		notes, err = env.Default.Database.ListDeployedNotes(userID, database.ListOptions{})
+	}
+	if err != nil {
+		slog.Error("list notes", "error", err)
+		return nil, err
+	}
+
+	visible := make(map[string]database.Note, len(notes))
+	for _, note := range notes {
+		if !owner {
+			publicNote(&note)
+		}
+		visible[note.ID] = note
+	}
+	return visible, nil
+}
+
+// collectionNotes returns the notes of a collection the reader can see, in order, and leaves
+// only their ids in the collection.
+func collectionNotes(collection *database.Collection, visible map[string]database.Note) []database.Note {
+	notes := []database.Note{}
+	collection.NoteIDs = slices.DeleteFunc(collection.NoteIDs, func(id string) bool {
+		note, ok := visible[id]
+		if ok {
+			notes = append(notes, note)
+		}
+		return !ok
+	})
+	return notes
+}
+
+// noteCollections returns the collections note is part of, as the reader sees them.
+func noteCollections(c *fiber.Ctx, note *database.Note) ([]collectionMembership, error) {
+	collections, err := env.Default.Database.ListCollections(note.UserID)
+	if err != nil {
+		slog.Error("list collections", "error", err)
+		return nil, err
+	}
+	collections = slices.DeleteFunc(collections, func(collection database.Collection) bool {
+		return !slices.Contains(collection.NoteIDs, note.ID)
+	})
+	if len(collections) == 0 {
+		return []collectionMembership{}, nil
+	}
+
+	visible, err := visibleNotes(c, note.UserID)
+	if err != nil {
+		return nil, err
+	}
+	memberships := []collectionMembership{}
+	for _, collection := range collections {
+		notes := collectionNotes(&collection, visible)
+		i := slices.IndexFunc(notes, func(n database.Note) bool { return n.ID == note.ID })
+		if i < 0 {
+			continue
+		}
+		membership := collectionMembership{
+			ID:       collection.ID,
+			Slug:     collection.Slug,
+			Name:     collection.Name,
+			Position: i + 1,
+			Count:    len(notes),
+		}
+		if i > 0 {
+			membership.Previous = &collectionLink{ID: notes[i-1].ID, Slug: notes[i-1].Slug, Title: notes[i-1].Title}
+		}
+		if i < len(notes)-1 {
+			membership.Next = &collectionLink{ID: notes[i+1].ID, Slug: notes[i+1].Slug, Title: notes[i+1].Title}
+		}
+		memberships = append(memberships, membership)
+	}
+	return memberships, nil
+}
+
+func getCollections() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*database.User)
+		collections, err := env.Default.Database.ListCollections(user.ID)
+		if err != nil {
+			slog.Error("list collections", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error":       nil,
+			"collections": collections,
+		})
+	}
+}
+
+// collectionRequest is the body of the requests creating and updating collections. The slug is
+// made from the name if it is empty.
+type collectionRequest struct {
+	Name        string `json:"name"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+}
+
+// createCollection creates an empty collection, whose notes are set with setCollectionNotes.
+func createCollection() fiber.Handler {
+	return handler(func(c *fiber.Ctx, body collectionRequest) error {
+		user := c.Locals("user").(*database.User)
+		collection := &database.Collection{UserID: user.ID, Name: body.Name, Slug: body.Slug, Description: body.Description}
+		if msg := collection.Normalize(); msg != "" {
+			return sendStringError(c, fiber.StatusBadRequest, msg)
+		}
+
+		if err := env.Default.Database.InsertCollection(collection); err != nil {
+			slog.Error("insert collection", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"error":      nil,
+			"collection": collection,
+		})
+	})
+}
+
+func updateCollection() fiber.Handler {
+	return handler(func(c *fiber.Ctx, body collectionRequest) error {
+		user := c.Locals("user").(*database.User)
+		collection := &database.Collection{ID: c.Params("id"), UserID: user.ID, Name: body.Name, Slug: body.Slug, Description: body.Description}
+		if msg := collection.Normalize(); msg != "" {
+			return sendStringError(c, fiber.StatusBadRequest, msg)
+		}
+
+		if err := env.Default.Database.UpdateCollection(collection); err != nil {
+			slog.Error("update collection", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error":      nil,
+			"collection": collection,
+		})
+	})
+}
+
+func deleteCollection() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*database.User)
+		if err := env.Default.Database.DeleteCollection(c.Params("id"), user.ID); err != nil {
+			slog.Error("delete collection", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error":   nil,
+			"message": "collection deleted successfully",
+		})
+	}
+}
+
+// setCollectionNotes replaces the notes of a collection by the ids of the body, in that order.
+// Every id must be of a note of the current user, once. Notes that are not deployed can be part of
+// a collection, readers only see it once they are.
+func setCollectionNotes() fiber.Handler {
+	type request struct {
+		Notes []string `json:"notes"`
+	}
+	return handler(func(c *fiber.Ctx, body request) error {
+		user := c.Locals("user").(*database.User)
+		notes, err := env.Default.Database.ListNotes(user.ID, database.ListOptions{})
+		if err != nil {
+			slog.Error("list notes", "error", err)
+			return sendError(c, err)
+		}
+		for i, id := range body.Notes {
+			if slices.Contains(body.Notes[:i], id) {
+				return sendStringError(c, fiber.StatusBadRequest, "note "+id+" is repeated")
+			}
+			if !slices.ContainsFunc(notes, func(n database.Note) bool { return n.ID == id }) {
+				return sendStringError(c, fiber.StatusBadRequest, "note "+id+" not found")
+			}
+		}
+
+		collectionID := c.Params("id")
+		if err := env.Default.Database.SetCollectionNotes(collectionID, user.ID, body.Notes); err != nil {
+			slog.Error("set collection notes", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error": nil,
+			"notes": append([]string{}, body.Notes...),
+		})
+	})
+}
+
+// listPublicCollections lists the collections of a user with the ids of the notes readers see in
+// them. Collections without any are left out, like on the profile page.
+func listPublicCollections() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := env.Default.Database.GetUserIDByUsername(c.Params("username"))
+		if err != nil {
+			slog.Error("get user ID by username", "error", err)
+			return sendError(c, err)
+		}
+		collections, err := env.Default.Database.ListCollections(userID)
+		if err != nil {
+			slog.Error("list collections", "error", err)
+			return sendError(c, err)
+		}
+		visible, err := visibleNotes(c, userID)
+		if err != nil {
+			return sendError(c, err)
+		}
+		for i := range collections {
+			collectionNotes(&collections[i], visible)
+		}
+		collections = slices.DeleteFunc(collections, func(collection database.Collection) bool {
+			return len(collection.NoteIDs) == 0
+		})
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error":       nil,
+			"collections": collections,
+		})
+	}
+}
+
+// getPublicCollection returns a collection of a user with the notes readers see in it, in order.
+// Collections without any are not found, like their pages.
+func getPublicCollection() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := env.Default.Database.GetUserIDByUsername(c.Params("username"))
+		if err != nil {
+			slog.Error("get user ID by username", "error", err)
+			return sendError(c, err)
+		}
+		collection, err := env.Default.Database.GetCollectionBySlug(userID, c.Params("slug"))
+		if err != nil {
+			slog.Error("get collection by slug", "error", err)
+			return sendError(c, err)
+		}
+		visible, err := visibleNotes(c, userID)
+		if err != nil {
+			return sendError(c, err)
+		}
+		notes := collectionNotes(collection, visible)
+		if len(notes) == 0 {
+			return sendError(c, database.ErrNoRows)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error":      nil,
+			"collection": collection,
+			"notes":      notes,
+		})
+	}
+}
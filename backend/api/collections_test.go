@@ -0,0 +1,91 @@
+package api
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/database"
+)
+
+func TestPublicCollectionsOwner(t *testing.T) {
+	user := &database.User{Username: "alice", Email: "alice@example.com"}
+	app, cookie, ids := testNotesApp(t, user, "deployed", "draft")
+	setCollectionsGroup(app.Group("/collections"))
+	if err := env.Default.Database.UndeployNote(ids[1], user.ID); err != nil {
+		t.Fatal(err)
+	}
+	collection := &database.Collection{UserID: user.ID, Slug: "reading", Name: "Reading"}
+	if err := env.Default.Database.InsertCollection(collection); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Default.Database.SetCollectionNotes(collection.ID, user.ID, []string{ids[1], ids[0]}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name, cookie string
+		want         []string
+	}{
+		{"reader", "", []string{"deployed"}},
+		{"owner", cookie, []string{"draft", "deployed"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var response struct {
+				Collection database.Collection `json:"collection"`
+				Notes      []database.Note     `json:"notes"`
+			}
+			if status := testNotesRequest(t, app, tt.cookie, fiber.MethodGet, "/collections/alice/reading", "", &response); status != fiber.StatusOK {
+				t.Fatalf("got status %d", status)
+			}
+			var slugs []string
+			for _, note := range response.Notes {
+				slugs = append(slugs, note.Slug)
+			}
+			if !slices.Equal(slugs, tt.want) || len(response.Collection.NoteIDs) != len(tt.want) {
+				t.Errorf("got notes %v and note ids %v, want %v", slugs, response.Collection.NoteIDs, tt.want)
+			}
+
+			var list struct {
+				Collections []database.Collection `json:"collections"`
+			}
+			testNotesRequest(t, app, tt.cookie, fiber.MethodGet, "/collections/alice", "", &list)
+			if len(list.Collections) != 1 || len(list.Collections[0].NoteIDs) != len(tt.want) {
+				t.Errorf("got collections %v, want one with %d notes", list.Collections, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestPublicCollectionsWithoutNotes(t *testing.T) {
+	user := &database.User{Username: "alice", Email: "alice@example.com"}
+	app, cookie, ids := testNotesApp(t, user, "draft")
+	setCollectionsGroup(app.Group("/collections"))
+	if err := env.Default.Database.UndeployNote(ids[0], user.ID); err != nil {
+		t.Fatal(err)
+	}
+	collection := &database.Collection{UserID: user.ID, Slug: "drafts", Name: "Drafts"}
+	if err := env.Default.Database.InsertCollection(collection); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.Default.Database.SetCollectionNotes(collection.ID, user.ID, ids); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := testNotesRequest(t, app, "", fiber.MethodGet, "/collections/alice/drafts", "", nil); status != fiber.StatusNotFound {
+		t.Errorf("got status %d, want %d", status, fiber.StatusNotFound)
+	}
+	var list struct {
+		Collections []database.Collection `json:"collections"`
+	}
+	testNotesRequest(t, app, "", fiber.MethodGet, "/collections/alice", "", &list)
+	if len(list.Collections) != 0 {
+		t.Errorf("got collections %v, want none", list.Collections)
+	}
+
+	// the owner sees the notes that are not deployed
+	if status := testNotesRequest(t, app, cookie, fiber.MethodGet, "/collections/alice/drafts", "", nil); status != fiber.StatusOK {
+		t.Errorf("owner got status %d, want %d", status, fiber.StatusOK)
+	}
+}
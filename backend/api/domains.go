@@ -0,0 +1,223 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/backend/session"
+	"github.com/shashwtd/webnotes/database"
+)
+
+func setDomainGroup(router fiber.Router) {
+	// /api/v1/domain
+	sessionMiddleware := session.RequiredSessionMiddleware()
+
+	router.Get("/", sessionMiddleware, getDomain())           // GET /api/v1/domain (get the current user's custom domain and its verification record)
+	router.Put("/", sessionMiddleware, setDomain())           // PUT /api/v1/domain (set the current user's custom domain, to be verified)
+	router.Post("/verify", sessionMiddleware, verifyDomain()) // POST /api/v1/domain/verify (verify the current user's custom domain with its TXT record)
+	router.Delete("/", sessionMiddleware, deleteDomain())     // DELETE /api/v1/domain (remove the current user's custom domain)
+}
+
+// pagesNotServedMessage is the error message of the requests setting up custom domains when this instance
+// does not serve the pages they show.
+const pagesNotServedMessage = "custom domains need the pages of this instance, which are not served (SERVE_PAGES)"
+
+// verificationRecord returns the name and value of the DNS TXT record proving the owner of a
+// domain set it as their custom domain.
+func verificationRecord(domain *database.CustomDomain) (name, value string) {
+	return "_webnotes." + domain.Domain, "webnotes-verification=" + domain.Token
+}
+
+func sendDomain(c *fiber.Ctx, domain *database.CustomDomain) error {
+	name, value := verificationRecord(domain)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"error":    nil,
+		"domain":   domain,
+		"verified": domain.Verified(),
+		"record":   fiber.Map{"type": "TXT", "name": name, "value": value},
+	})
+}
+
+func getDomain() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*database.User)
+		domain, err := env.Default.Database.GetCustomDomain(user.ID)
+		if err != nil {
+			slog.Error("get custom domain", "error", err)
+			return sendError(c, err)
+		}
+		return sendDomain(c, domain)
+	}
+}
+
+func setDomain() fiber.Handler {
+	type request struct {
+		Domain string `json:"domain"`
+	}
+	return handler(func(c *fiber.Ctx, body request) error {
+		if !env.Default.ServePages {
+			return sendStringError(c, fiber.StatusNotImplemented, pagesNotServedMessage)
+		}
+		user := c.Locals("user").(*database.User)
+		domain := &database.CustomDomain{UserID: user.ID, Domain: body.Domain}
+		if msg := domain.Normalize(); msg != "" {
+			return sendStringError(c, fiber.StatusBadRequest, msg)
+		}
+		if isOwnHost(domain.Domain) {
+			return sendStringError(c, fiber.StatusBadRequest, "this domain is already used by this instance")
+		}
+
+		if err := env.Default.Database.SetCustomDomain(domain); err != nil {
+			slog.Error("set custom domain", "error", err)
+			return sendError(c, err)
+		}
+		return sendDomain(c, domain)
+	})
+}
+
+// verifyDomain verifies the custom domain of the current user if its verification record is
+// found. It can be called again once verified, to take the domain back after it changed hands.
+func verifyDomain() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !env.Default.ServePages {
+			return sendStringError(c, fiber.StatusNotImplemented, pagesNotServedMessage)
+		}
+		user := c.Locals("user").(*database.User)
+		domain, err := env.Default.Database.GetCustomDomain(user.ID)
+		if err != nil {
+			slog.Error("get custom domain", "error", err)
+			return sendError(c, err)
+		}
+
+		name, value := verificationRecord(domain)
+		ctx, cancel := context.WithTimeout(c.Context(), 10*time.Second)
+		defer cancel()
+		records, err := resolver().LookupTXT(ctx, name)
+		var dnsErr *net.DNSError
+		if err != nil && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+			slog.Error("look up verification record", "name", name, "error", err)
+			return sendStringError(c, fiber.StatusBadGateway, "the verification record could not be looked up, please try again later")
+		}
+		if !slices.ContainsFunc(records, func(r string) bool { return strings.TrimSpace(r) == value }) {
+			return sendStringError(c, fiber.StatusUnprocessableEntity, "the verification record was not found, DNS changes can take a while to show up")
+		}
+
+		domain, err = env.Default.Database.VerifyCustomDomain(user.ID)
+		if err != nil {
+			slog.Error("verify custom domain", "error", err)
+			return sendError(c, err)
+		}
+		return sendDomain(c, domain)
+	}
+}
+
+func deleteDomain() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*database.User)
+		if err := env.Default.Database.DeleteCustomDomain(user.ID); err != nil {
+			slog.Error("delete custom domain", "error", err)
+			return sendError(c, err)
+		}
+		return sendError(c, nil)
+	}
+}
+
+// resolver returns the resolver custom domains are verified with: the DNS server of DNS_RESOLVER,
+// or the system resolver.
+func resolver() *net.Resolver {
+	if env.Default.DNSResolver == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, env.Default.DNSResolver)
+		},
+	}
+}
+
+// CustomDomains routes the requests made to verified custom domains to the pages and feeds of
+// their users: / is the profile, /:slug a deployed note, and /rss.xml, /atom.xml, /feed.json and
+// /sitemap.xml the feeds and sitemap. The api is served as on this backend. It must be used
+// before the routes are set, and only with the pages of SetPagesGroup.
+func CustomDomains() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		host := hostname(c.Hostname())
+		path := c.Path()
+		if host == "" || host == "localhost" || net.ParseIP(host) != nil || isOwnHost(host) || strings.HasPrefix(path, "/api/") {
+			return c.Next()
+		}
+
+		user, err := env.Default.Database.GetUserByCustomDomain(host)
+		if errors.Is(err, database.ErrNoRows) {
+			return c.Next()
+		}
+		if err != nil {
+			slog.Error("get user by custom domain", "error", err)
+			return sendPageError(c, err)
+		}
+
+		c.Locals("custom_domain", host)
+		switch path {
+		case "/robots.txt":
+		case "/sitemap.xml":
+			c.Path("/sitemaps/" + user.Username + ".xml")
+		case "/rss.xml", "/atom.xml", "/feed.json":
+			c.Path("/api/v1/feeds/" + user.Username + path)
+		default:
+			c.Path(strings.TrimSuffix("/"+user.Username+path, "/"))
+		}
+		return c.Next()
+	}
+}
+
+// customDomain returns the custom domain the request was made to, or an empty string if it was
+// made to this backend.
+func customDomain(c *fiber.Ctx) string {
+	domain, _ := c.Locals("custom_domain").(string)
+	return domain
+}
+
+// profileURL returns the url of the profile page of a user for the request: the root of the
+// custom domain it was made to, or else the page under PAGES_URL.
+func profileURL(c *fiber.Ctx, username string) string {
+	if domain := customDomain(c); domain != "" {
+		return "https://" + domain
+	}
+	return env.Default.PagesURL + "/" + username
+}
+
+// feedsURL returns where the feeds of a user are served for the request.
+func feedsURL(c *fiber.Ctx, username string) string {
+	if domain := customDomain(c); domain != "" {
+		return "https://" + domain
+	}
+	return env.Default.PublicURL + "/api/v1/feeds/" + username
+}
+
+// hostname returns a host without its port, lower cased.
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// isOwnHost reports whether host is where this backend or its pages are served.
+func isOwnHost(host string) bool {
+	for _, raw := range []string{env.Default.PublicURL, env.Default.PagesURL} {
+		if u, err := url.Parse(raw); err == nil && hostname(u.Host) == host {
+			return true
+		}
+	}
+	return false
+}
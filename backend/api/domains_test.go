@@ -0,0 +1,83 @@
+package api
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/database"
+)
+
+func TestCustomDomains(t *testing.T) {
+	env.Default.PublicURL = "https://api.webnotes.example"
+	env.Default.PagesURL = "https://webnotes.example"
+	db := database.Memory("")
+	env.Default.Database = db
+	for _, u := range []struct {
+		user   *database.User
+		domain string
+		verify bool
+	}{
+		{&database.User{Username: "alice", Email: "alice@example.com"}, "alice.example", true},
+		{&database.User{Username: "bob", Email: "bob@example.com"}, "bob.example", false},
+	} {
+		if err := db.InsertUser(u.user); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.SetCustomDomain(&database.CustomDomain{UserID: u.user.ID, Domain: u.domain}); err != nil {
+			t.Fatal(err)
+		}
+		if u.verify {
+			if _, err := db.VerifyCustomDomain(u.user.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// the routes answer with the path they were given and the custom domain of the request
+	app := fiber.New()
+	app.Use(CustomDomains())
+	app.Use(func(c *fiber.Ctx) error {
+		return c.SendString(c.Path() + " " + customDomain(c))
+	})
+
+	for _, tt := range []struct {
+		host, path string
+		want       string
+	}{
+		{"alice.example", "/", "/alice alice.example"},
+		{"alice.example", "/hello-world", "/alice/hello-world alice.example"},
+		{"alice.example", "/hello-world/", "/alice/hello-world alice.example"},
+		{"Alice.Example:443", "/hello-world", "/alice/hello-world alice.example"},
+		{"alice.example", "/rss.xml", "/api/v1/feeds/alice/rss.xml alice.example"},
+		{"alice.example", "/atom.xml", "/api/v1/feeds/alice/atom.xml alice.example"},
+		{"alice.example", "/feed.json", "/api/v1/feeds/alice/feed.json alice.example"},
+		{"alice.example", "/sitemap.xml", "/sitemaps/alice.xml alice.example"},
+		{"alice.example", "/robots.txt", "/robots.txt alice.example"},
+		{"alice.example", "/api/v1/notes/og/x.png", "/api/v1/notes/og/x.png "},
+
+		// own hosts, ip hosts, unknown and unverified domains fall through
+		{"webnotes.example", "/hello-world", "/hello-world "},
+		{"api.webnotes.example", "/hello-world", "/hello-world "},
+		{"localhost:8080", "/hello-world", "/hello-world "},
+		{"127.0.0.1:8080", "/hello-world", "/hello-world "},
+		{"[::1]:8080", "/hello-world", "/hello-world "},
+		{"unknown.example", "/hello-world", "/hello-world "},
+		{"bob.example", "/hello-world", "/hello-world "},
+	} {
+		t.Run(tt.host+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodGet, tt.path, nil)
+			req.Host = tt.host
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := io.ReadAll(resp.Body)
+			if got := string(data); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/shashwtd/webnotes/backend/env"
+)
+
+// decodeBody is a middleware that decompresses gzip and zstd encoded request bodies in place, so
+// that the next handlers can read them with c.Body(). Like the compressed body, the decompressed
+// body may not be larger than the body limit.
+func decodeBody() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		encoding := strings.ToLower(strings.TrimSpace(c.Get(fiber.HeaderContentEncoding)))
+
+		var r io.Reader
+		switch encoding {
+		case "", "identity":
+			return c.Next()
+		case "gzip", "x-gzip":
+			zr, err := gzip.NewReader(bytes.NewReader(c.Request().Body()))
+			if err != nil {
+				return sendStringError(c, fiber.StatusBadRequest, "the request body is not valid gzip")
+			}
+			defer zr.Close()
+			r = zr
+		case "zstd":
+			zr, err := zstd.NewReader(bytes.NewReader(c.Request().Body()), zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return sendStringError(c, fiber.StatusBadRequest, "the request body is not valid zstd")
+			}
+			defer zr.Close()
+			r = zr
+		default:
+			return sendStringError(c, fiber.StatusUnsupportedMediaType, fmt.Sprintf("unsupported content encoding %q (use gzip or zstd)", encoding))
+		}
+
+		limit := cmp.Or(env.Default.BodyLimit, fiber.DefaultBodyLimit)
+		body, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+		if err != nil {
+			return sendStringError(c, fiber.StatusBadRequest, fmt.Sprintf("the request body is not valid %s", encoding))
+		}
+		if len(body) > limit {
+			return sendStringError(c, fiber.StatusRequestEntityTooLarge, "the decompressed request body is too large, split the sync in chunks")
+		}
+
+		c.Request().SetBodyRaw(body)
+		c.Request().Header.Del(fiber.HeaderContentEncoding)
+		return c.Next()
+	}
+}
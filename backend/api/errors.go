@@ -10,6 +10,8 @@ import (
 
 var (
 	ErrNonDeployedNoteNotAccessible = errors.New("non-deployed note is not accessible to non-owners")
+	ErrProtectedNote                = errors.New("note is password protected and no valid access token was given")
+	ErrWrongNotePassword            = errors.New("wrong note password")
 )
 
 // ErrorPattern represents a pattern to match and its corresponding status and message
@@ -30,26 +32,56 @@ var errorPatterns = []errorPattern{
 		StatusCode: fiber.StatusConflict,
 		Message:    "username already in use, use a different username or log in",
 	},
+	{
+		Contains:   []string{"duplicate key value violates unique constraint", "sync_rules_user_id_action_match_value_key"},
+		StatusCode: fiber.StatusConflict,
+		Message:    "this sync rule already exists",
+	},
+	{
+		Contains:   []string{"duplicate key value violates unique constraint", "collections_user_id_slug_key"},
+		StatusCode: fiber.StatusConflict,
+		Message:    "you already have a collection with this slug",
+	},
 	{
 		Contains:   []string{"invalid input syntax for type uuid"},
 		StatusCode: fiber.StatusUnprocessableEntity,
 		Message:    "the id passed is invalid",
 	},
 	{
-		Contains:   []string{"no rows in result set", "multiple (or no) rows returned"},
+		Contains:   []string{"get last activity by type", "multiple (or no) rows returned"},
 		StatusCode: fiber.StatusNotFound,
-		Message:    "the requested resource was not found or you do not have access to it",
+		Message:    "no activities of the requested type found",
 	},
 	{
-		Contains:   []string{"get last activity by type", "multiple (or no) rows returned"},
+		Contains:   []string{"get last activity by type", "no rows in result set"},
 		StatusCode: fiber.StatusNotFound,
 		Message:    "no activities of the requested type found",
 	},
+	{
+		Contains:   []string{"no rows in result set"}, // pgx and database.ErrNoRows
+		StatusCode: fiber.StatusNotFound,
+		Message:    "the requested resource was not found or you do not have access to it",
+	},
+	{
+		Contains:   []string{"multiple (or no) rows returned"}, // postgrest
+		StatusCode: fiber.StatusNotFound,
+		Message:    "the requested resource was not found or you do not have access to it",
+	},
 	{
 		Contains:   []string{ErrNonDeployedNoteNotAccessible.Error()},
 		StatusCode: fiber.StatusNotFound,
 		Message:    "the requested resource was not found or you do not have access to it",
 	},
+	{
+		Contains:   []string{ErrProtectedNote.Error()},
+		StatusCode: fiber.StatusUnauthorized,
+		Message:    "this note is password protected, exchange its password for an access token",
+	},
+	{
+		Contains:   []string{ErrWrongNotePassword.Error()},
+		StatusCode: fiber.StatusUnauthorized,
+		Message:    "the password is incorrect",
+	},
 	{
 		Contains:   []string{"request Content-Type has bad boundary", "multipart/form-data"},
 		StatusCode: fiber.StatusBadRequest,
@@ -66,7 +98,15 @@ func sendError(c *fiber.Ctx, err error) error {
 	if err == nil {
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{"error": nil})
 	}
+	status, message := matchError(err)
+	return c.Status(status).JSON(fiber.Map{
+		"error": message,
+	})
+}
 
+// matchError returns the status code and message of the first error pattern err matches, or an
+// internal server error.
+func matchError(err error) (int, string) {
 	errMessage := err.Error()
 
 	// Match known patterns
@@ -79,9 +119,7 @@ func sendError(c *fiber.Ctx, err error) error {
 			}
 		}
 		if matched { // matched with this pattern, send the message and status code
-			return c.Status(pattern.StatusCode).JSON(fiber.Map{
-				"error": pattern.Message,
-			})
+			return pattern.StatusCode, pattern.Message
 		}
 		// if not matched, continue to the next pattern
 	}
@@ -89,9 +127,7 @@ func sendError(c *fiber.Ctx, err error) error {
 	slog.Error("unmatched error", "error", errMessage)
 
 	// Default error
-	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-		"error": "an error occurred, please try again later",
-	})
+	return fiber.StatusInternalServerError, "an error occurred, please try again later"
 }
 
 func sendStringError(c *fiber.Ctx, statusCode int, message string) error {
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/backend/markdown"
+	"github.com/shashwtd/webnotes/database"
+)
+
+// exportNotes sends the current user's notes as a zip with a markdown file per note, named after
+// its slug. Each file starts with front matter holding the title, dates and folder of the note.
+func exportNotes() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*database.User)
+		notes, err := env.Default.Database.ListNotes(user.ID, database.ListOptions{})
+		if err != nil {
+			slog.Error("list notes", "error", err)
+			return sendError(c, err)
+		}
+
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for _, listed := range notes {
+			note, err := env.Default.Database.GetNoteByID(listed.ID) // listed notes have no body
+			if err != nil {
+				slog.Error("get note by ID", "error", err)
+				return sendError(c, err)
+			}
+			modified, _ := time.Parse(time.RFC3339Nano, note.UpdatedAt)
+			w, err := zw.CreateHeader(&zip.FileHeader{Name: note.Slug + ".md", Method: zip.Deflate, Modified: modified})
+			if err != nil {
+				slog.Error("create zip entry", "error", err)
+				return sendError(c, err)
+			}
+			if _, err := w.Write([]byte(exportMarkdown(note))); err != nil {
+				slog.Error("write zip entry", "error", err)
+				return sendError(c, err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			slog.Error("close zip", "error", err)
+			return sendError(c, err)
+		}
+
+		c.Set("Content-Type", "application/zip")
+		c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-notes.zip"`, user.Username))
+		return c.Send(buf.Bytes())
+	}
+}
+
+// exportMarkdown returns the exported file of a note. Strings in the front matter are quoted the
+// way YAML reads them.
+func exportMarkdown(note *database.Note) string {
+	front := "---\ntitle: " + strconv.Quote(note.Title) + "\n"
+	front += "created_at: " + note.CreatedAt + "\nupdated_at: " + note.UpdatedAt + "\n"
+	if note.FolderName != "" {
+		front += "folder: " + strconv.Quote(note.FolderName) + "\n"
+	}
+	front += "deployed: " + strconv.FormatBool(note.Deployed) + "\n---\n\n"
+	return front + markdown.Convert(note.Body)
+}
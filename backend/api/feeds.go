@@ -0,0 +1,294 @@
+package api
+
+import (
+	"cmp"
+	"encoding/xml"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/etag"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/database"
+)
+
+// feedSize is how many of the most recently updated notes a feed has.
+const feedSize = 20
+
+func setFeedsGroup(router fiber.Router) {
+	// /api/v1/feeds
+	router.Get("/:username/rss.xml", etag.New(), feedHandler(sendRSS))    // GET /api/v1/feeds/:username/rss.xml (RSS 2.0 feed of a user's deployed notes)
+	router.Get("/:username/atom.xml", etag.New(), feedHandler(sendAtom))  // GET /api/v1/feeds/:username/atom.xml (Atom 1.0 feed of a user's deployed notes)
+	router.Get("/:username/feed.json", etag.New(), feedHandler(sendJSON)) // GET /api/v1/feeds/:username/feed.json (JSON Feed 1.1 of a user's deployed notes)
+}
+
+// feed is what the feeds of a user are made of: the user and their most recently updated deployed
+// notes, as readers see them and with their bodies.
+type feed struct {
+	User    *database.User
+	Notes   []database.Note
+	Updated time.Time // when the last note was updated
+
+	profile string // url of the profile page of User
+	feeds   string // where the feeds of User are served
+}
+
+// title returns the title of the feed.
+func (f *feed) title() string {
+	return cmp.Or(f.User.Name, f.User.Username)
+}
+
+// link returns the url of the page of a note, or of the profile if note is nil.
+func (f *feed) link(note *database.Note) string {
+	if note == nil {
+		return f.profile
+	}
+	return f.profile + "/" + note.Slug
+}
+
+// self returns the url of the feed in a format.
+func (f *feed) self(file string) string {
+	return f.feeds + "/" + file
+}
+
+// feedHandler builds the feed of the user in the path and sends it with send. Feed readers that
+// already have the latest version, by ETag, get a 304 Not Modified. There is no Last-Modified: a
+// note undeployed, unlisted or protected leaves the feed without making it any newer.
+func feedHandler(send func(c *fiber.Ctx, f *feed) error) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := env.Default.Database.GetUserByUsername(c.Params("username"))
+		if err != nil {
+			slog.Error("get user by username", "error", err)
+			return sendError(c, err)
+		}
+		notes, err := env.Default.Database.ListDeployedNotes(user.ID, database.ListOptions{
+			Sort:   database.SortUpdated,
+			Limit:  feedSize,
+			Bodies: true,
+		})
+		if err != nil {
+			slog.Error("list deployed notes", "error", err)
+			return sendError(c, err)
+		}
+
+		// pinned notes are listed first, the feed is newest first
+		slices.SortStableFunc(notes, func(a, b database.Note) int { return noteUpdated(&b).Compare(noteUpdated(&a)) })
+
+		f := &feed{
+			User:    user,
+			Notes:   notes,
+			profile: profileURL(c, user.Username),
+			feeds:   feedsURL(c, user.Username),
+		}
+		f.Updated, _ = time.Parse(time.RFC3339Nano, user.CreatedAt)
+		if len(notes) > 0 {
+			f.Updated = noteUpdated(&notes[0])
+		}
+		for i := range f.Notes {
+			publicNote(&f.Notes[i])
+		}
+
+		c.Set("Cache-Control", "public, max-age=300")
+		return send(c, f)
+	}
+}
+
+// noteUpdated returns when what readers see of a note last changed: when its snapshot was
+// published if it is deployed as one, or else when it was last updated.
+func noteUpdated(note *database.Note) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, note.PublicUpdatedAt())
+	return t
+}
+
+// noteCreated returns when a note was created.
+func noteCreated(note *database.Note) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, note.CreatedAt)
+	return t
+}
+
+// noteGUID returns the id of a note in feeds, which never changes.
+func noteGUID(note *database.Note) string {
+	return "urn:uuid:" + note.ID
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func sendRSS(c *fiber.Ctx, f *feed) error {
+	doc := rss{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:         f.title(),
+			Link:          f.link(nil),
+			Description:   cmp.Or(f.User.Description, "Notes by "+f.title()),
+			Self:          atomLink{Href: f.self("rss.xml"), Rel: "self", Type: "application/rss+xml"},
+			LastBuildDate: f.Updated.UTC().Format(time.RFC1123Z),
+		},
+	}
+	for i := range f.Notes {
+		note := &f.Notes[i]
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       note.Title,
+			Link:        f.link(note),
+			GUID:        rssGUID{Value: noteGUID(note)},
+			PubDate:     noteUpdated(note).UTC().Format(time.RFC1123Z),
+			Description: note.Body,
+		})
+	}
+	return sendXML(c, "application/rss+xml; charset=utf-8", doc)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published,omitempty"`
+	Link      atomLink    `xml:"link"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+func sendAtom(c *fiber.Ctx, f *feed) error {
+	doc := atomFeed{
+		ID:      f.link(nil),
+		Title:   f.title(),
+		Updated: f.Updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.link(nil), Rel: "alternate", Type: "text/html"},
+			{Href: f.self("atom.xml"), Rel: "self", Type: "application/atom+xml"},
+		},
+		Author: atomAuthor{Name: f.title(), URI: f.link(nil)},
+	}
+	for i := range f.Notes {
+		note := &f.Notes[i]
+		entry := atomEntry{
+			ID:      noteGUID(note),
+			Title:   note.Title,
+			Updated: noteUpdated(note).UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: f.link(note), Rel: "alternate", Type: "text/html"},
+			Content: atomContent{Type: "html", Value: note.Body},
+		}
+		if created := noteCreated(note); !created.IsZero() {
+			entry.Published = created.UTC().Format(time.RFC3339)
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return sendXML(c, "application/atom+xml; charset=utf-8", doc)
+}
+
+func sendXML(c *fiber.Ctx, contentType string, doc any) error {
+	out, err := xml.Marshal(doc)
+	if err != nil {
+		slog.Error("marshal feed", "error", err)
+		return sendError(c, err)
+	}
+	c.Set("Content-Type", contentType)
+	return c.Send(append([]byte(xml.Header), out...))
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description,omitempty"`
+	Icon        string         `json:"icon,omitempty"`
+	Authors     []jsonAuthor   `json:"authors"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonAuthor struct {
+	Name   string `json:"name"`
+	URL    string `json:"url,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
+}
+
+type jsonFeedItem struct {
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	Title         string `json:"title"`
+	ContentHTML   string `json:"content_html"`
+	DatePublished string `json:"date_published,omitempty"`
+	DateModified  string `json:"date_modified"`
+}
+
+func sendJSON(c *fiber.Ctx, f *feed) error {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.title(),
+		HomePageURL: f.link(nil),
+		FeedURL:     f.self("feed.json"),
+		Description: f.User.Description,
+		Icon:        f.User.ProfilePictureURL,
+		Authors:     []jsonAuthor{{Name: f.title(), URL: f.link(nil), Avatar: f.User.ProfilePictureURL}},
+		Items:       []jsonFeedItem{},
+	}
+	for i := range f.Notes {
+		note := &f.Notes[i]
+		item := jsonFeedItem{
+			ID:           noteGUID(note),
+			URL:          f.link(note),
+			Title:        note.Title,
+			ContentHTML:  note.Body,
+			DateModified: noteUpdated(note).UTC().Format(time.RFC3339),
+		}
+		if created := noteCreated(note); !created.IsZero() {
+			item.DatePublished = created.UTC().Format(time.RFC3339)
+		}
+		doc.Items = append(doc.Items, item)
+	}
+	return c.Status(fiber.StatusOK).JSON(doc, "application/feed+json; charset=utf-8")
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"log/slog"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/database"
+)
+
+// DeployedFolder is a folder of a user's deployed notes, as shown on their public profile.
+type DeployedFolder struct {
+	ID    string          `json:"id"`
+	Name  string          `json:"name"`
+	Notes []database.Note `json:"notes"`
+}
+
+// folderParam returns the folder id of the folder route parameter. Folder ids of Apple Notes
+// contain slashes, so clients path escape them.
+func folderParam(c *fiber.Ctx) (string, bool) {
+	folderID, err := url.PathUnescape(c.Params("folder"))
+	return folderID, err == nil && folderID != ""
+}
+
+func listFolders() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*database.User)
+		folders, err := env.Default.Database.ListFolders(user.ID)
+		if err != nil {
+			slog.Error("list folders", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error":   nil,
+			"folders": folders,
+		})
+	}
+}
+
+// deployFolder deploys every note of a folder that is not deployed yet. Notes deployed as a
+// snapshot stay so.
+func deployFolder() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		folderID, ok := folderParam(c)
+		if !ok {
+			return sendStringError(c, fiber.StatusBadRequest, "invalid folder")
+		}
+		user := c.Locals("user").(*database.User)
+
+		count, err := env.Default.Database.DeployFolder(folderID, user.ID)
+		if err != nil {
+			slog.Error("deploy folder", "error", err)
+			return sendError(c, err)
+		}
+
+		setActivity(user.ID, ATFolderDeployed, onlineString(c, "folder %s deployed successfully (%d notes)", folderID, count))
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message":  "folder deployed successfully",
+			"deployed": count,
+			"error":    nil,
+		})
+	}
+}
+
+func undeployFolder() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		folderID, ok := folderParam(c)
+		if !ok {
+			return sendStringError(c, fiber.StatusBadRequest, "invalid folder")
+		}
+		user := c.Locals("user").(*database.User)
+
+		count, err := env.Default.Database.UndeployFolder(folderID, user.ID)
+		if err != nil {
+			slog.Error("undeploy folder", "error", err)
+			return sendError(c, err)
+		}
+
+		setActivity(user.ID, ATFolderUndeployed, onlineString(c, "folder %s undeployed successfully (%d notes)", folderID, count))
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message":    "folder undeployed successfully",
+			"undeployed": count,
+			"error":      nil,
+		})
+	}
+}
+
+// listDeployedFolders returns the deployed notes of a user grouped by folder, as readers see them.
+// Deployed notes without a folder are returned apart.
+func listDeployedFolders() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		username := c.Params("username")
+
+		userID, err := env.Default.Database.GetUserIDByUsername(username)
+		if err != nil {
+			slog.Error("get user ID by username", "username", username, "error", err)
+			return sendError(c, err)
+		}
+
+		notes, err := env.Default.Database.ListDeployedNotes(userID, database.ListOptions{})
+		if err != nil {
+			slog.Error("retrieve notes", "error", err)
+			return sendError(c, err)
+		}
+
+		folders := []DeployedFolder{}
+		unfiled := []database.Note{}
+		index := make(map[string]int)
+		for _, folder := range database.FoldersOf(notes) {
+			index[folder.ID] = len(folders)
+			folders = append(folders, DeployedFolder{ID: folder.ID, Name: folder.Name, Notes: []database.Note{}})
+		}
+		for _, note := range notes {
+			publicNote(&note)
+			if i, ok := index[note.FolderID]; ok {
+				folders[i].Notes = append(folders[i].Notes, note)
+				continue
+			}
+			unfiled = append(unfiled, note)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error":   nil,
+			"folders": folders,
+			"notes":   unfiled,
+		})
+	}
+}
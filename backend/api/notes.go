@@ -2,9 +2,13 @@ package api
 
 import (
 	"log/slog"
+	"slices"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/etag"
 	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/backend/markdown"
+	"github.com/shashwtd/webnotes/backend/sanitize"
 	"github.com/shashwtd/webnotes/backend/session"
 	"github.com/shashwtd/webnotes/database"
 )
@@ -14,14 +18,53 @@ func setNotesGroup(router fiber.Router) {
 	requiredSM := session.RequiredSessionMiddleware()
 	optionalSM := session.OptionalSessionMiddleware()
 
-	router.Get("/list", requiredSM, listNotes())                   // GET /api/v1/notes/list (list all notes for the current user)
-	router.Get("/list/:username", optionalSM, listDeployedNotes()) // GET /api/v1/notes/list/:username (list all deployed notes for a specific user)
-	router.Post("/list", requiredSM, saveNotes())                  // POST /api/v1/notes/list (save a list of notes for the current user)
-
-	router.Post("/deploy/:id", requiredSM, deployNote())     // POST /api/v1/notes/deploy/:username (deploy notes for a specific user)
-	router.Delete("/deploy/:id", requiredSM, undeployNote()) // DELETE /api/v1/notes/deploy/:username (undeploy notes for a specific user)
-
-	router.Get("/count", requiredSM, countNotes()) // GET /api/v1/notes/count (count all notes for the current user)
+	router.Get("/list", requiredSM, listNotes())                   // GET /api/v1/notes/list?folder=&tag= (list all notes for the current user, or the ones of a folder or with a tag)
+	router.Get("/list/:username", optionalSM, listDeployedNotes()) // GET /api/v1/notes/list/:username?tag=&sort=&order=&limit=&cursor= (list the deployed notes of a user, pinned first, a page at a time)
+	router.Post("/list", requiredSM, decodeBody(), saveNotes())    // POST /api/v1/notes/list (save a list of notes for the current user, gzip or zstd encoded bodies are accepted)
+	router.Get("/manifest", requiredSM, getNoteManifest())         // GET /api/v1/notes/manifest?source= (what the server has of the current user's notes from a source)
+
+	router.Post("/uploads", requiredSM, decodeBody(), beginUpload())                   // POST /api/v1/notes/uploads (begin a chunked sync)
+	router.Get("/uploads/:id", requiredSM, getUpload())                                // GET /api/v1/notes/uploads/:id (get a chunked sync and its received chunks)
+	router.Put("/uploads/:id/chunks/:seq", requiredSM, decodeBody(), putUploadChunk()) // PUT /api/v1/notes/uploads/:id/chunks/:seq (send or resend a chunk of notes)
+	router.Post("/uploads/:id/commit", requiredSM, decodeBody(), commitUpload())       // POST /api/v1/notes/uploads/:id/commit (sync every chunk at once)
+	router.Delete("/uploads/:id", requiredSM, deleteUpload())                          // DELETE /api/v1/notes/uploads/:id (abandon a chunked sync)
+
+	router.Post("/deploy/:id", requiredSM, deployNote())              // POST /api/v1/notes/deploy/:username?mode= (deploy notes for a specific user, live or as a snapshot)
+	router.Delete("/deploy/:id", requiredSM, undeployNote())          // DELETE /api/v1/notes/deploy/:username (undeploy notes for a specific user)
+	router.Get("/deploy/:id/preview", requiredSM, previewNote())      // GET /api/v1/notes/deploy/:id/preview?mode= (what changed since a snapshot was published)
+	router.Post("/deploy/:id/republish", requiredSM, republishNote()) // POST /api/v1/notes/deploy/:id/republish (publish a new snapshot)
+
+	router.Post("/noindex/:id", requiredSM, setNoteNoindex(true))    // POST /api/v1/notes/noindex/:id (keep search engines from indexing a note)
+	router.Delete("/noindex/:id", requiredSM, setNoteNoindex(false)) // DELETE /api/v1/notes/noindex/:id (let search engines index a note again)
+	router.Put("/visibility/:id", requiredSM, setNoteVisibility())   // PUT /api/v1/notes/visibility/:id (make a note private, public, unlisted or password protected)
+	router.Post("/pin/:id", requiredSM, setNotePinned(true))         // POST /api/v1/notes/pin/:id (pin a note to the top of the current user's profile)
+	router.Delete("/pin/:id", requiredSM, setNotePinned(false))      // DELETE /api/v1/notes/pin/:id (unpin a note)
+	router.Put("/order", requiredSM, setNotesOrder())                // PUT /api/v1/notes/order (set the order of the current user's notes when sorted manually)
+	router.Put("/schedule/:id", requiredSM, scheduleNote())          // PUT /api/v1/notes/schedule/:id (schedule when a note is deployed and when it expires)
+	router.Delete("/schedule/:id", requiredSM, unscheduleNote())     // DELETE /api/v1/notes/schedule/:id (cancel the schedule of a note)
+
+	router.Get("/folders", requiredSM, listFolders())                      // GET /api/v1/notes/folders (list the folders of the current user's notes)
+	router.Post("/folders/:folder/deploy", requiredSM, deployFolder())     // POST /api/v1/notes/folders/:folder/deploy (deploy every note of a folder, the id path escaped)
+	router.Delete("/folders/:folder/deploy", requiredSM, undeployFolder()) // DELETE /api/v1/notes/folders/:folder/deploy (undeploy every note of a folder)
+	router.Get("/folders/:username", optionalSM, listDeployedFolders())    // GET /api/v1/notes/folders/:username (deployed notes of a user grouped by folder)
+
+	router.Get("/tags", requiredSM, listTags())                   // GET /api/v1/notes/tags (list the tags of the current user's notes with counts)
+	router.Get("/tags/:username", optionalSM, listDeployedTags()) // GET /api/v1/notes/tags/:username (list the tags of a user's deployed notes with counts)
+
+	router.Get("/rules", requiredSM, listSyncRules())         // GET /api/v1/notes/rules (list the sync rules of the current user)
+	router.Post("/rules", requiredSM, createSyncRule())       // POST /api/v1/notes/rules (add a rule deploying, undeploying or excluding notes by tag or folder)
+	router.Delete("/rules/:id", requiredSM, deleteSyncRule()) // DELETE /api/v1/notes/rules/:id (delete a sync rule)
+
+	router.Get("/count", requiredSM, countNotes())   // GET /api/v1/notes/count (count all notes for the current user)
+	router.Get("/export", requiredSM, exportNotes()) // GET /api/v1/notes/export (download the current user's notes as a zip of markdown files)
+
+	router.Get("/search", requiredSM, searchNotes())                   // GET /api/v1/notes/search?q=&deployed=&from=&to=&limit=&offset= (search the current user's notes)
+	router.Get("/search/:username", optionalSM, searchDeployedNotes()) // GET /api/v1/notes/search/:username?q=&limit=&offset= (search the deployed notes of a user)
+
+	router.Get("/revisions/:id", requiredSM, listNoteRevisions())                      // GET /api/v1/notes/revisions/:id (list the revisions of a note, newest first)
+	router.Get("/revisions/:id/diff", requiredSM, diffNoteRevisions())                 // GET /api/v1/notes/revisions/:id/diff?from=&to=&mode= (diff two revisions, unified or words)
+	router.Get("/revisions/:id/:revision", requiredSM, getNoteRevision())              // GET /api/v1/notes/revisions/:id/:revision (get a revision with its body)
+	router.Post("/revisions/:id/:revision/restore", requiredSM, restoreNoteRevision()) // POST /api/v1/notes/revisions/:id/:revision/restore (make a revision the current version)
 
 	router.Post("/view/:id", func(c *fiber.Ctx) error { // POST /api/v1/notes/:id/viewed (increment view count for a note)
 		noteID := c.Params("id")
@@ -35,18 +78,33 @@ func setNotesGroup(router fiber.Router) {
 		})
 	})
 
-	router.Get("/:id", optionalSM, getNoteID())               // GET /api/v1/notes/:id (get a note by ID)
-	router.Get("/:username/:slug", optionalSM, getNoteSlug()) // GET /api/v1/notes/:username/:id (get a note by ID for a specific user)
+	router.Get("/og/:id.png", etag.New(), getOGImage()) // GET /api/v1/notes/og/:id.png (Open Graph image of a deployed note)
+
+	router.Get("/:username/tags/:tag", optionalSM, listTaggedNotes()) // GET /api/v1/notes/:username/tags/:tag (list the deployed notes of a user with a tag)
+
+	// share routes only match note ids, and unknown tokens fall through, so that they leave
+	// /:username/:slug to the notes slugged "shares" and the user named "shared"
+	router.Get("/shared/:token", getSharedNote())                            // GET /api/v1/notes/shared/:token (read a note with a share link, deployed or not)
+	router.Get("/:id<guid>/shares", requiredSM, listNoteShares())            // GET /api/v1/notes/:id/shares (list the share links of a note)
+	router.Post("/:id<guid>/shares", requiredSM, createNoteShare())          // POST /api/v1/notes/:id/shares (mint a share link expiring at a time, optionally after a number of views)
+	router.Delete("/:id<guid>/shares/:share", requiredSM, revokeNoteShare()) // DELETE /api/v1/notes/:id/shares/:share (revoke a share link)
+
+	router.Get("/:id", optionalSM, getNoteID())               // GET /api/v1/notes/:id (get a note by ID, with its body as markdown)
+	router.Get("/:username/:slug", optionalSM, getNoteSlug()) // GET /api/v1/notes/:username/:id (get a note by ID for a specific user, with its body as markdown)
+	router.Post("/:username/:slug/access", accessNote())      // POST /api/v1/notes/:username/:slug/access (exchange the password of a protected note for an access token)
 }
 
 func listNotes() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		user := c.Locals("user").(*database.User)
-		notes, err := env.Default.Database.ListNotes(user.ID)
+		notes, err := env.Default.Database.ListNotes(user.ID, listOptions(c))
 		if err != nil {
 			slog.Error("list notes", "error", err)
 			return sendError(c, err)
 		}
+		if folderID := c.Query("folder"); folderID != "" {
+			notes = slices.DeleteFunc(notes, func(n database.Note) bool { return n.FolderID != folderID })
+		}
 		return c.JSON(notes) // defaults to 200 OK
 	}
 }
@@ -55,20 +113,29 @@ func listDeployedNotes() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		username := c.Params("username")
 
-		userID, err := env.Default.Database.GetUserIDByUsername(username)
+		user, err := env.Default.Database.GetUserByUsername(username)
 		if err != nil {
-			slog.Error("get user ID by username", "username", username, "error", err)
+			slog.Error("get user by username", "username", username, "error", err)
 			return sendError(c, err)
 		}
 
-		notes, err := env.Default.Database.ListDeployedNotes(userID)
-
-		// get the user id by username
+		opts, msg := sortedListOptions(c, user)
+		if msg != "" {
+			return sendStringError(c, fiber.StatusBadRequest, msg)
+		}
+		notes, err := env.Default.Database.ListDeployedNotes(user.ID, opts)
 		if err != nil {
 			slog.Error("retrieve notes", "error", err)
 			return sendError(c, err)
 		}
+		if opts.Limit > 0 && len(notes) == opts.Limit {
+			c.Set("X-Next-Cursor", opts.Cursor(&notes[len(notes)-1], true))
+		}
+		for i := range notes {
+			publicNote(&notes[i])
+		}
 
+		setRobots(c, user.Noindex)
 		return c.JSON(notes)
 	}
 }
@@ -88,18 +155,29 @@ func countNotes() fiber.Handler {
 	}
 }
 
+// deployNote deploys a note. With the mode query parameter set to snapshot, readers see the note as
+// it is now until it is republished; by default (live) they see every change synced.
 func deployNote() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		noteID := c.Params("id")
 		user := c.Locals("user").(*database.User) // ensure user is set in context by session middleware
 
-		err := env.Default.Database.DeployNote(noteID, user.ID)
+		var err error
+		mode := c.Query("mode", database.PublishLive)
+		switch mode {
+		case database.PublishLive:
+			err = env.Default.Database.DeployNote(noteID, user.ID)
+		case database.PublishSnapshot:
+			err = env.Default.Database.PublishNote(noteID, user.ID)
+		default:
+			return sendStringError(c, fiber.StatusBadRequest, "invalid mode, must be live or snapshot")
+		}
 		if err != nil {
 			slog.Error("deploy note", "error", err)
 			return sendError(c, err)
 		}
 
-		setActivity(user.ID, ATNoteDeployed, onlineString(c, "note %s deployed successfully", noteID))
+		setActivity(user.ID, ATNoteDeployed, onlineString(c, "note %s deployed successfully (%s)", noteID, mode))
 
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{
 			"message": "note deployed successfully",
@@ -128,23 +206,6 @@ func undeployNote() fiber.Handler {
 	}
 }
 
-func saveNotes() fiber.Handler {
-	return handler(func(c *fiber.Ctx, body []database.Note) error {
-		user := c.Locals("user").(*database.User) // ensure user is set in context by session middleware
-		err := env.Default.Database.InsertNotesForUser(user.ID, body)
-		if err != nil {
-			slog.Error("insert notes for user", "error", err)
-			return sendError(c, err)
-		}
-
-		setActivity(user.ID, ATClientSynced, onlineString(c, "%d notes synced successfully", len(body)))
-		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-			"message": "notes saved successfully",
-			"error":   nil,
-		})
-	})
-}
-
 func getNoteID() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		noteID := c.Params("id")
@@ -158,8 +219,21 @@ func getNoteID() fiber.Handler {
 		if !canUserAccessNote(c, note) {
 			return sendError(c, ErrNonDeployedNoteNotAccessible)
 		}
+		if err := checkNoteAccess(c, note, c.Query("access_token")); err != nil {
+			return sendError(c, err)
+		}
+		if !isNoteOwner(c, note) {
+			publicNote(note)
+			if err := noindexNote(c, note); err != nil {
+				return sendError(c, err)
+			}
+		}
 
-		return c.JSON(note)
+		response := withMarkdown(note)
+		if response.Collections, err = noteCollections(c, note); err != nil {
+			return sendError(c, err)
+		}
+		return c.JSON(response)
 	}
 }
 
@@ -177,16 +251,101 @@ func getNoteSlug() fiber.Handler {
 		if !canUserAccessNote(c, note) {
 			return sendError(c, ErrNonDeployedNoteNotAccessible)
 		}
+		if err := checkNoteAccess(c, note, c.Query("access_token")); err != nil {
+			return sendError(c, err)
+		}
+		if !isNoteOwner(c, note) {
+			publicNote(note)
+			if err := noindexNote(c, note); err != nil {
+				return sendError(c, err)
+			}
+		}
 
-		return c.JSON(note)
+		response := withMarkdown(note)
+		if response.Collections, err = noteCollections(c, note); err != nil {
+			return sendError(c, err)
+		}
+		return c.JSON(response)
 	}
 }
 
 func canUserAccessNote(c *fiber.Ctx, note *database.Note) bool {
-	var isOwner bool
-	if c.Locals("user") != nil {
+	return isNoteOwner(c, note) || note.Deployed
+}
+
+func isNoteOwner(c *fiber.Ctx, note *database.Note) bool {
+	if c.Locals("user") == nil {
+		return false
+	}
+	user := c.Locals("user").(*database.User)
+	return user.ID == note.UserID
+}
+
+// setNoteNoindex keeps search engines from indexing a note, or lets them index it again.
+func setNoteNoindex(noindex bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		noteID := c.Params("id")
 		user := c.Locals("user").(*database.User)
-		isOwner = user.ID == note.UserID
+
+		err := env.Default.Database.SetNoteNoindex(noteID, user.ID, noindex)
+		if err != nil {
+			slog.Error("set note noindex", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error": nil,
+		})
 	}
-	return isOwner || note.Deployed
+}
+
+// noindexNote marks a note served to readers as noindex if it or its owner is, or if it is not
+// public, and tells search engines not to index the response.
+func noindexNote(c *fiber.Ctx, note *database.Note) error {
+	owner, err := env.Default.Database.GetUserByID(note.UserID)
+	if err != nil {
+		slog.Error("get user by ID", "error", err)
+		return err
+	}
+	note.Noindex = note.Noindex || owner.Noindex || note.Visibility != database.VisibilityPublic
+	setRobots(c, note.Noindex)
+	return nil
+}
+
+// setRobots tells search engines not to index the response if noindex is set.
+func setRobots(c *fiber.Ctx, noindex bool) {
+	if noindex {
+		c.Set("X-Robots-Tag", "noindex")
+	}
+}
+
+// noteWithMarkdown is a note with its body converted to markdown.
+type noteWithMarkdown struct {
+	*database.Note
+	BodyMarkdown string                 `json:"body_markdown"`
+	Collections  []collectionMembership `json:"collections,omitempty"` // the collections of the note the reader sees it in
+}
+
+func withMarkdown(note *database.Note) noteWithMarkdown {
+	return noteWithMarkdown{Note: note, BodyMarkdown: markdown.Convert(note.Body)}
+}
+
+// publicNote turns note into what its readers see: the published snapshot if it is deployed as
+// one, and never the private working copy of such a note, nor when it was last edited. The body is
+// sanitized, as anyone can sync any html.
+func publicNote(note *database.Note) {
+	if note.PublishMode == database.PublishSnapshot {
+		note.Title = note.PublishedTitle
+		note.Body = note.PublishedBody
+		note.UpdatedAt = note.PublicUpdatedAt()
+		note.ContentHash = "" // hash of the working copy
+	}
+	sanitizeNote(note)
+}
+
+// sanitizeNote sanitizes the body of note and leaves out its published snapshot, for readers of
+// its working copy.
+func sanitizeNote(note *database.Note) {
+	note.Body = sanitize.HTML(note.Body)
+	note.PublishedTitle = ""
+	note.PublishedBody = ""
 }
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif" // profile picture formats
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/backend/ogimage"
+	"github.com/shashwtd/webnotes/database"
+)
+
+// ogImageVersion is part of the keys of the images, change it to render every image again when
+// their design changes.
+const ogImageVersion = "1"
+
+// maxAvatarSize is the size past which profile pictures are not downloaded for images.
+const maxAvatarSize = 5 << 20
+
+// maxAvatarDimension is the width or height past which profile pictures are not decoded, so that
+// a small file claiming huge dimensions cannot make the decoder allocate too much.
+const maxAvatarDimension = 4096
+
+var avatarClient = &http.Client{Timeout: 5 * time.Second}
+
+// ogImageURL returns the url of the Open Graph image of a note.
+func ogImageURL(note *database.Note) string {
+	return env.Default.PublicURL + "/api/v1/notes/og/" + note.ID + ".png"
+}
+
+// getOGImage sends the Open Graph image of a deployed note. Images are rendered on the first
+// request and stored with a key made of everything they show, so they are rendered again once the
+// note, the name or the profile picture of its author changes.
+func getOGImage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		noteID := c.Params("id")
+		note, err := env.Default.Database.GetNoteByID(noteID)
+		if err != nil {
+			slog.Error("get note by ID", "error", err)
+			return sendError(c, err)
+		}
+		// the image shows an excerpt, protected notes have none
+		if !note.Deployed || note.Visibility == database.VisibilityProtected {
+			return sendError(c, ErrNonDeployedNoteNotAccessible)
+		}
+		publicNote(note)
+		author, err := env.Default.Database.GetUserByID(note.UserID)
+		if err != nil {
+			slog.Error("get user by ID", "error", err)
+			return sendError(c, err)
+		}
+
+		key := ogImageKey(note, author)
+		data, err := env.Default.Database.GetOGImage(noteID, key)
+		if err != nil { // not rendered yet, or outdated
+			data, err = ogimage.Render(ogimage.Card{
+				Title:    note.Title,
+				Excerpt:  excerpt(note.Body, note.Title),
+				Author:   cmp.Or(author.Name, author.Username),
+				Username: author.Username,
+				Avatar:   loadAvatar(author.ProfilePictureURL),
+			})
+			if err != nil {
+				slog.Error("render og image", "error", err)
+				return sendError(c, err)
+			}
+			if err := env.Default.Database.SaveOGImage(noteID, key, data); err != nil {
+				slog.Error("save og image", "error", err) // rendered again next time
+			}
+		}
+
+		c.Set("Content-Type", "image/png")
+		c.Set("Cache-Control", "public, max-age=3600")
+		return c.Send(data)
+	}
+}
+
+// ogImageKey returns the key of the image of a note as readers see it: a hash of what the image
+// shows.
+func ogImageKey(note *database.Note, author *database.User) string {
+	h := sha256.New()
+	for _, s := range []string{ogImageVersion, database.ContentHash(note), author.Name, author.Username, author.ProfilePictureURL} {
+		fmt.Fprintf(h, "%d:%s", len(s), s)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// loadAvatar returns the profile picture at url, or nil if it cannot be loaded. Pictures stored
+// by this backend are read from the database.
+func loadAvatar(url string) image.Image {
+	if url == "" {
+		return nil
+	}
+
+	var data []byte
+	if filename, ok := strings.CutPrefix(url, env.Default.PublicURL+"/api/v1/profile/picture/"); ok {
+		var err error
+		data, _, err = env.Default.Database.GetProfilePicture(filename)
+		if err != nil {
+			slog.Warn("get profile picture for og image", "error", err)
+			return nil
+		}
+	} else {
+		resp, err := avatarClient.Get(url)
+		if err != nil {
+			slog.Warn("download profile picture for og image", "error", err)
+			return nil
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			slog.Warn("download profile picture for og image", "status", resp.StatusCode)
+			return nil
+		}
+		data, err = io.ReadAll(io.LimitReader(resp.Body, maxAvatarSize))
+		if err != nil {
+			slog.Warn("download profile picture for og image", "error", err)
+			return nil
+		}
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		slog.Warn("decode profile picture for og image", "error", err)
+		return nil
+	}
+	if config.Width > maxAvatarDimension || config.Height > maxAvatarDimension {
+		slog.Warn("profile picture too large for og image", "width", config.Width, "height", config.Height)
+		return nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		slog.Warn("decode profile picture for og image", "error", err)
+		return nil
+	}
+	return img
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"cmp"
+	"log/slog"
+	"slices"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/database"
+)
+
+// maxListLimit is the largest page of a listing of deployed notes.
+const maxListLimit = 100
+
+// sortedListOptions reads the filters, sort and page of a listing of the deployed notes of a user
+// from the query parameters: tag, sort (the default sort of the user if empty), order (asc or
+// desc), limit (every note if empty) and cursor, as returned in the X-Next-Cursor header of the
+// previous page. It returns an error message if they are invalid.
+func sortedListOptions(c *fiber.Ctx, user *database.User) (database.ListOptions, string) {
+	opts := listOptions(c)
+	opts.Sort = cmp.Or(c.Query("sort"), user.NotesSort, database.SortUpdated)
+	if !database.ValidSort(opts.Sort) {
+		return opts, "invalid sort, must be updated, created, views or manual"
+	}
+	switch c.Query("order") {
+	case "", "desc":
+	case "asc":
+		opts.Ascending = true
+	default:
+		return opts, "invalid order, must be asc or desc"
+	}
+	if c.Query("limit") != "" {
+		opts.Limit = c.QueryInt("limit", 0)
+		if opts.Limit < 1 || opts.Limit > maxListLimit {
+			return opts, "invalid limit, must be between 1 and " + strconv.Itoa(maxListLimit)
+		}
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := database.ParseCursor(cursor)
+		if err != nil || after.Sort != opts.Sort || after.Ascending != opts.Ascending {
+			return opts, "invalid cursor, must be the one of the previous page with the same sort and order"
+		}
+		opts.After = after
+	}
+	return opts, ""
+}
+
+// setNotePinned pins a note to the top of the current user's profile, or unpins it.
+func setNotePinned(pinned bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		noteID := c.Params("id")
+		user := c.Locals("user").(*database.User)
+
+		err := env.Default.Database.SetNotePinned(noteID, user.ID, pinned)
+		if err != nil {
+			slog.Error("set note pinned", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error":  nil,
+			"pinned": pinned,
+		})
+	}
+}
+
+// setNotesOrder sets the order of the current user's notes when sorted manually: the notes listed
+// first come first, and the ones not listed come after them.
+func setNotesOrder() fiber.Handler {
+	type request struct {
+		Notes []string `json:"notes"`
+	}
+	return handler(func(c *fiber.Ctx, body request) error {
+		user := c.Locals("user").(*database.User)
+		notes, err := env.Default.Database.ListNotes(user.ID, database.ListOptions{})
+		if err != nil {
+			slog.Error("list notes", "error", err)
+			return sendError(c, err)
+		}
+		for i, id := range body.Notes {
+			if slices.Contains(body.Notes[:i], id) {
+				return sendStringError(c, fiber.StatusBadRequest, "note "+id+" is repeated")
+			}
+			if !slices.ContainsFunc(notes, func(n database.Note) bool { return n.ID == id }) {
+				return sendStringError(c, fiber.StatusBadRequest, "note "+id+" not found")
+			}
+		}
+
+		if err := env.Default.Database.SetNotesManualOrder(user.ID, body.Notes); err != nil {
+			slog.Error("set notes manual order", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error": nil,
+			"notes": append([]string{}, body.Notes...),
+		})
+	})
+}
@@ -0,0 +1,326 @@
+package api
+
+import (
+	"bytes"
+	"cmp"
+	"embed"
+	"errors"
+	"html"
+	"html/template"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/etag"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/database"
+)
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+// pageTemplates are the templates of the pages, by name, each with the layout.
+var pageTemplates = func() map[string]*template.Template {
+	funcs := template.FuncMap{"date": formatDate}
+	pages := make(map[string]*template.Template)
+	for _, name := range []string{"note", "profile", "collection", "protected", "error"} {
+		pages[name] = template.Must(template.New(name).Funcs(funcs).ParseFS(templateFiles, "templates/layout.html", "templates/"+name+".html"))
+	}
+	return pages
+}()
+
+// SetPagesGroup serves the html pages of profiles and deployed notes, so they can be hosted
+// without the frontend and crawlers see their content. It must be set after the api and sitemap
+// groups, as /:username matches every path.
+func SetPagesGroup(router fiber.Router) {
+	router.Get("/:username", etag.New(), profilePage())            // GET /:username (profile of a user with their deployed notes)
+	router.Get("/:username/:slug", etag.New(), notePage())         // GET /:username/:slug (deployed note of a user)
+	router.Post("/:username/:slug", unlockNotePage())              // POST /:username/:slug (unlock a password protected note with the password form)
+	router.Get("/:username/c/:slug", etag.New(), collectionPage()) // GET /:username/c/:slug (collection of a user with its deployed notes, in order)
+}
+
+// page is what the templates are executed with.
+type page struct {
+	Title        string
+	Description  string
+	CanonicalURL string
+	Type         string // og:type
+	Image        string
+	Noindex      bool   // whether search engines should not index the page
+	Private      bool   // whether shared caches should not store the page, as only some readers can see it
+	Message      string // shown on the page, like why a form was refused
+
+	ProfileURL string // where the profile of User is served, for links between pages
+	Feeds      string // where the feeds of User are served
+
+	User  *database.User
+	Note  *database.Note
+	Body  template.HTML // sanitized body of Note
+	Notes []database.Note
+
+	Collection  *database.Collection   // shown on its page with Notes
+	Collections []database.Collection  // of User, listed on their profile
+	Memberships []collectionMembership // collections Note is part of, to go from one note to the next
+}
+
+func profilePage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := env.Default.Database.GetUserByUsername(c.Params("username"))
+		if err != nil {
+			slog.Error("get user by username", "error", err)
+			return sendPageError(c, err)
+		}
+		notes, err := env.Default.Database.ListDeployedNotes(user.ID, database.ListOptions{Sort: cmp.Or(user.NotesSort, database.SortUpdated)})
+		if err != nil {
+			slog.Error("list deployed notes", "error", err)
+			return sendPageError(c, err)
+		}
+		for i := range notes {
+			publicNote(&notes[i])
+		}
+		collections, err := env.Default.Database.ListCollections(user.ID)
+		if err != nil {
+			slog.Error("list collections", "error", err)
+			return sendPageError(c, err)
+		}
+		collections = slices.DeleteFunc(collections, func(collection database.Collection) bool {
+			return !slices.ContainsFunc(notes, func(n database.Note) bool { return slices.Contains(collection.NoteIDs, n.ID) })
+		})
+
+		name := cmp.Or(user.Name, user.Username)
+		return sendPage(c, fiber.StatusOK, "profile", page{
+			Title:        name,
+			Description:  cmp.Or(user.Description, "Notes by "+name),
+			CanonicalURL: profileURL(c, user.Username),
+			Type:         "profile",
+			Image:        user.ProfilePictureURL,
+			Noindex:      user.Noindex,
+			ProfileURL:   profileURL(c, user.Username),
+			Feeds:        feedsURL(c, user.Username),
+			User:         user,
+			Notes:        notes,
+			Collections:  collections,
+		})
+	}
+}
+
+func notePage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := env.Default.Database.GetUserByUsername(c.Params("username"))
+		if err != nil {
+			slog.Error("get user by username", "error", err)
+			return sendPageError(c, err)
+		}
+		note, err := env.Default.Database.GetNoteBySlug(user.Username, c.Params("slug"))
+		if err != nil {
+			slog.Error("get note by username and slug", "error", err)
+			return sendPageError(c, err)
+		}
+		// owners see their notes in the app, pages are only for readers
+		if !note.Deployed {
+			return sendPageError(c, ErrNonDeployedNoteNotAccessible)
+		}
+		if note.Visibility == database.VisibilityProtected {
+			err := checkNoteAccess(c, note, c.Cookies(noteAccessCookie(note)))
+			if errors.Is(err, ErrProtectedNote) {
+				return sendPasswordPage(c, user, "")
+			}
+			if err != nil {
+				return sendPageError(c, err)
+			}
+		}
+		publicNote(note)
+
+		if err := env.Default.Database.IncrementNoteViews(note.ID); err != nil {
+			slog.Error("view note", "error", err) // the page is still served
+		}
+		memberships, err := noteCollections(c, note)
+		if err != nil {
+			return sendPageError(c, err)
+		}
+
+		title := cmp.Or(note.Title, "Untitled")
+		return sendPage(c, fiber.StatusOK, "note", page{
+			Title:        title + " · " + cmp.Or(user.Name, user.Username),
+			Description:  excerpt(note.Body, note.Title),
+			CanonicalURL: profileURL(c, user.Username) + "/" + note.Slug,
+			Type:         "article",
+			Image:        ogImageURL(note),
+			Noindex:      note.Noindex || user.Noindex || note.Visibility != database.VisibilityPublic,
+			Private:      note.Visibility == database.VisibilityProtected,
+			ProfileURL:   profileURL(c, user.Username),
+			Feeds:        feedsURL(c, user.Username),
+			User:         user,
+			Note:         note,
+			Body:         template.HTML(note.Body), // sanitized by publicNote
+			Memberships:  memberships,
+		})
+	}
+}
+
+// collectionPage lists the deployed public notes of a collection, in order. Collections without
+// any are not found, like with the api, and are not linked from the profile page.
+func collectionPage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := env.Default.Database.GetUserByUsername(c.Params("username"))
+		if err != nil {
+			slog.Error("get user by username", "error", err)
+			return sendPageError(c, err)
+		}
+		collection, err := env.Default.Database.GetCollectionBySlug(user.ID, c.Params("slug"))
+		if err != nil {
+			slog.Error("get collection by slug", "error", err)
+			return sendPageError(c, err)
+		}
+		visible, err := visibleNotes(c, user.ID)
+		if err != nil {
+			return sendPageError(c, err)
+		}
+		notes := collectionNotes(collection, visible)
+		if len(notes) == 0 {
+			return sendPageError(c, database.ErrNoRows)
+		}
+
+		name := cmp.Or(user.Name, user.Username)
+		return sendPage(c, fiber.StatusOK, "collection", page{
+			Title:        collection.Name + " · " + name,
+			Description:  cmp.Or(collection.Description, collection.Name+", notes by "+name),
+			CanonicalURL: profileURL(c, user.Username) + "/c/" + collection.Slug,
+			Type:         "website",
+			Image:        user.ProfilePictureURL,
+			Noindex:      user.Noindex,
+			ProfileURL:   profileURL(c, user.Username),
+			Feeds:        feedsURL(c, user.Username),
+			User:         user,
+			Notes:        notes,
+			Collection:   collection,
+		})
+	}
+}
+
+// unlockNotePage checks the password sent with the form of a protected note, and redirects to the
+// note with a cookie holding an access token if it is right.
+func unlockNotePage() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := env.Default.Database.GetUserByUsername(c.Params("username"))
+		if err != nil {
+			slog.Error("get user by username", "error", err)
+			return sendPageError(c, err)
+		}
+		note, err := env.Default.Database.GetNoteBySlug(user.Username, c.Params("slug"))
+		if err != nil {
+			slog.Error("get note by username and slug", "error", err)
+			return sendPageError(c, err)
+		}
+		if note.Visibility != database.VisibilityProtected {
+			return sendPageError(c, ErrNonDeployedNoteNotAccessible)
+		}
+
+		token, expiresAt, err := newNoteAccessToken(note, c.FormValue("password"))
+		if errors.Is(err, ErrWrongNotePassword) {
+			return sendPasswordPage(c, user, "The password is incorrect.")
+		}
+		if err != nil {
+			return sendPageError(c, err)
+		}
+
+		c.Cookie(&fiber.Cookie{
+			Name:     noteAccessCookie(note),
+			Value:    token,
+			Path:     "/",
+			Expires:  expiresAt,
+			HTTPOnly: true,
+			Secure:   true,
+			SameSite: fiber.CookieSameSiteLaxMode,
+		})
+		path := "/" + user.Username + "/" + note.Slug
+		if customDomain(c) != "" {
+			path = "/" + note.Slug
+		}
+		return c.Redirect(path, fiber.StatusSeeOther)
+	}
+}
+
+// noteAccessCookie returns the name of the cookie holding the access token to a protected note.
+func noteAccessCookie(note *database.Note) string {
+	return "note_access_" + note.ID
+}
+
+// sendPasswordPage renders the password form of a protected note of user, without anything about
+// the note, with an error message if there is one.
+func sendPasswordPage(c *fiber.Ctx, user *database.User, message string) error {
+	return sendPage(c, fiber.StatusUnauthorized, "protected", page{
+		Title:      "Password protected note · " + cmp.Or(user.Name, user.Username),
+		Type:       "website",
+		Noindex:    true,
+		ProfileURL: profileURL(c, user.Username),
+		User:       user,
+		Message:    message,
+	})
+}
+
+// sendPage renders a page. Pages are cached for a short time, so that changes to notes show up
+// soon, and only by browsers if they are private.
+func sendPage(c *fiber.Ctx, status int, name string, p page) error {
+	var buf bytes.Buffer
+	if err := pageTemplates[name].ExecuteTemplate(&buf, "layout", p); err != nil {
+		slog.Error("execute page template", "page", name, "error", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("an error occurred, please try again later")
+	}
+	c.Set("Content-Type", fiber.MIMETextHTMLCharsetUTF8)
+	setRobots(c, p.Noindex)
+	switch {
+	case status != fiber.StatusOK:
+	case p.Private:
+		c.Set("Cache-Control", "private, max-age=300")
+	default:
+		c.Set("Cache-Control", "public, max-age=300")
+	}
+	return c.Status(status).Send(buf.Bytes())
+}
+
+// sendPageError renders the error page of err, with the status and message the api would send.
+func sendPageError(c *fiber.Ctx, err error) error {
+	status, message := matchError(err)
+	title := "Something went wrong"
+	if status == fiber.StatusNotFound {
+		title = "Not found"
+	}
+	return sendPage(c, status, "error", page{
+		Title:       title,
+		Description: message,
+		Type:        "website",
+	})
+}
+
+var tagRegexp = regexp.MustCompile(`<[^>]*>`)
+
+// excerpt returns the start of the text of a sanitized body, without its title, for descriptions.
+func excerpt(body, title string) string {
+	text := strings.Join(strings.Fields(html.UnescapeString(tagRegexp.ReplaceAllString(body, " "))), " ")
+	text = strings.TrimSpace(strings.TrimPrefix(text, strings.TrimSpace(title)))
+
+	const maxLength = 160
+	runes := []rune(text)
+	if len(runes) <= maxLength {
+		return text
+	}
+	cut := string(runes[:maxLength])
+	if i := strings.LastIndexByte(cut, ' '); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
+
+// formatDate formats a timestamp of the database for readers, or returns it as is if it cannot be
+// parsed.
+func formatDate(timestamp string) string {
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return timestamp
+	}
+	return t.Format("January 2, 2006")
+}
@@ -20,13 +20,16 @@ func setProfileGroup(router fiber.Router) {
 	// /api/v1/profile
 	sessionMiddleware := session.RequiredSessionMiddleware()
 
-	router.Get("/", sessionMiddleware, getMyProfileHandler()) // GET /api/v1/profile (get the current user's profile)
-	router.Get("/:username", getOtherProfileHandler())        // GET /api/v1/profile/:username (get a specific user's profile)
+	router.Get("/", sessionMiddleware, getMyProfileHandler())    // GET /api/v1/profile (get the current user's profile)
+	router.Get("/:username", getOtherProfileHandler())           // GET /api/v1/profile/:username (get a specific user's profile)
+	router.Get("/picture/:filename", getProfilePictureHandler()) // GET /api/v1/profile/picture/:filename (get a stored profile picture)
 
 	router.Patch("/edit/name", sessionMiddleware, editNameHandler())                         // PATCH /api/v1/profile/edit/name (edit the current user's name)
 	router.Patch("/edit/description", sessionMiddleware, editDescriptionHandler())           // PATCH /api/v1/profile/edit/description (edit the current user's description)
 	router.Patch("/edit/profile-picture", sessionMiddleware, editProfilePictureHandler())    // PATCH /api/v1/profile/edit/profile-picture (edit the current user's profile picture)
 	router.Patch("/edit/socials", sessionMiddleware, editSocialsHandler())                   // PATCH /api/v1/profile/edit/socials (edit the current user's socials)
+	router.Patch("/edit/noindex", sessionMiddleware, editNoindexHandler())                   // PATCH /api/v1/profile/edit/noindex (keep search engines from indexing the current user's profile and notes, or not)
+	router.Patch("/edit/notes-sort", sessionMiddleware, editNotesSortHandler())              // PATCH /api/v1/profile/edit/notes-sort (edit how the notes on the current user's profile are sorted)
 	router.Delete("/edit/profile-picture", sessionMiddleware, deleteProfilePictureHandler()) // DELETE /api/v1/profile/edit/profile-picture (reset curren't user's profile picture to default)
 }
 
@@ -39,6 +42,8 @@ func sendProfile(c *fiber.Ctx, user *database.User) error {
 		"profile_picture_url":  user.ProfilePictureURL,
 		"created_at":           user.CreatedAt,
 		"has_connected_client": user.HasConnectedClient,
+		"noindex":              user.Noindex,
+		"notes_sort":           user.NotesSort,
 	}
 	omitempty(m, "twitter_username", user.TwitterUsername)
 	omitempty(m, "instagram_username", user.InstagramUsername)
@@ -62,10 +67,25 @@ func getOtherProfileHandler() fiber.Handler {
 			return sendError(c, err)
 		}
 
+		setRobots(c, user.Noindex)
 		return sendProfile(c, user)
 	}
 }
 
+func getProfilePictureHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		data, contentType, err := env.Default.Database.GetProfilePicture(c.Params("filename"))
+		if err != nil {
+			slog.Error("get profile picture", "error", err)
+			return sendError(c, err)
+		}
+
+		c.Set("Content-Type", contentType)
+		c.Set("Cache-Control", "public, max-age=31536000, immutable") // filenames are random and never reused
+		return c.Send(data)
+	}
+}
+
 func editNameHandler() fiber.Handler {
 	type expectedBody struct {
 		Name string `json:"name"`
@@ -190,6 +210,44 @@ func editSocialsHandler() fiber.Handler {
 	})
 }
 
+func editNoindexHandler() fiber.Handler {
+	type expectedBody struct {
+		Noindex bool `json:"noindex"`
+	}
+	return handler(func(c *fiber.Ctx, body expectedBody) error {
+		user := c.Locals("user").(*database.User)
+		err := env.Default.Database.SetUserNoindex(user.ID, body.Noindex)
+		if err != nil {
+			slog.Error("set user noindex", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error": nil,
+		})
+	})
+}
+
+func editNotesSortHandler() fiber.Handler {
+	type expectedBody struct {
+		Sort string `json:"sort"`
+	}
+	return handler(func(c *fiber.Ctx, body expectedBody) error {
+		if !database.ValidSort(body.Sort) {
+			return sendStringError(c, fiber.StatusBadRequest, "invalid sort, must be updated, created, views or manual")
+		}
+		user := c.Locals("user").(*database.User)
+		err := env.Default.Database.SetUserNotesSort(user.ID, body.Sort)
+		if err != nil {
+			slog.Error("set user notes sort", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error": nil,
+			"sort":  body.Sort,
+		})
+	})
+}
+
 func deleteProfilePictureHandler() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		user := c.Locals("user").(*database.User)
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/diff"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/database"
+)
+
+// getSnapshotNote returns a note of the current user that is deployed as a snapshot, or sends an
+// error and returns nil.
+func getSnapshotNote(c *fiber.Ctx) (*database.Note, error) {
+	note, err := env.Default.Database.GetNoteByID(c.Params("id"))
+	if err != nil {
+		slog.Error("get note by ID", "error", err)
+		return nil, sendError(c, err)
+	}
+	if !isNoteOwner(c, note) {
+		return nil, sendError(c, ErrNonDeployedNoteNotAccessible)
+	}
+	if !note.Deployed || note.PublishMode != database.PublishSnapshot {
+		return nil, sendStringError(c, fiber.StatusConflict, "the note is not deployed as a snapshot")
+	}
+	return note, nil
+}
+
+// previewNote compares the published snapshot of a note with its working copy, to show what a
+// republish would change. The mode query parameter picks a unified diff ("unified", the default)
+// or a word diff ("words").
+func previewNote() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		mode := c.Query("mode", "unified")
+		if mode != "unified" && mode != "words" {
+			return sendStringError(c, fiber.StatusBadRequest, "invalid mode, must be unified or words")
+		}
+
+		note, err := getSnapshotNote(c)
+		if note == nil {
+			return err
+		}
+
+		resp := fiber.Map{
+			"error":        nil,
+			"mode":         mode,
+			"published_at": note.PublishedAt,
+			"changed":      note.Title != note.PublishedTitle || note.Body != note.PublishedBody,
+		}
+		if mode == "words" {
+			resp["title"] = diff.Words(note.PublishedTitle, note.Title)
+			resp["body"] = diff.Words(note.PublishedBody, note.Body)
+		} else {
+			resp["title"] = diff.Unified("published", "working copy", note.PublishedTitle, note.Title, diffContext)
+			resp["body"] = diff.Unified("published", "working copy", note.PublishedBody, note.Body, diffContext)
+		}
+		return c.Status(fiber.StatusOK).JSON(resp)
+	}
+}
+
+// republishNote replaces the published snapshot of a note with its working copy.
+func republishNote() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		note, err := getSnapshotNote(c)
+		if note == nil {
+			return err
+		}
+
+		user := c.Locals("user").(*database.User)
+		if err := env.Default.Database.PublishNote(note.ID, user.ID); err != nil {
+			slog.Error("publish note", "error", err)
+			return sendError(c, err)
+		}
+
+		setActivity(user.ID, ATNoteRepublished, onlineString(c, "note %s republished successfully", note.ID))
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "note republished successfully",
+			"error":   nil,
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/diff"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/database"
+)
+
+// diffContext is the number of unchanged lines around the changes of a unified diff.
+const diffContext = 3
+
+func listNoteRevisions() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*database.User)
+		revisions, err := env.Default.Database.ListNoteRevisions(c.Params("id"), user.ID)
+		if err != nil {
+			slog.Error("list note revisions", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error":     nil,
+			"revisions": revisions,
+		})
+	}
+}
+
+func getNoteRevision() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*database.User)
+		revision, err := env.Default.Database.GetNoteRevision(c.Params("revision"), c.Params("id"), user.ID)
+		if err != nil {
+			slog.Error("get note revision", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error":    nil,
+			"revision": revision,
+		})
+	}
+}
+
+// diffNoteRevisions compares the title and body of two revisions of a note, given by the from and
+// to query parameters. The mode query parameter picks a unified diff ("unified", the default) or
+// a word diff ("words").
+func diffNoteRevisions() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		fromID, toID := c.Query("from"), c.Query("to")
+		if fromID == "" || toID == "" {
+			return sendStringError(c, fiber.StatusBadRequest, "missing from or to query parameter")
+		}
+		mode := c.Query("mode", "unified")
+		if mode != "unified" && mode != "words" {
+			return sendStringError(c, fiber.StatusBadRequest, "invalid mode, must be unified or words")
+		}
+
+		user := c.Locals("user").(*database.User)
+		noteID := c.Params("id")
+		from, err := env.Default.Database.GetNoteRevision(fromID, noteID, user.ID)
+		if err != nil {
+			slog.Error("get note revision", "error", err)
+			return sendError(c, err)
+		}
+		to, err := env.Default.Database.GetNoteRevision(toID, noteID, user.ID)
+		if err != nil {
+			slog.Error("get note revision", "error", err)
+			return sendError(c, err)
+		}
+
+		resp := fiber.Map{
+			"error": nil,
+			"mode":  mode,
+			"from":  from.ID,
+			"to":    to.ID,
+		}
+		if mode == "words" {
+			resp["title"] = diff.Words(from.Title, to.Title)
+			resp["body"] = diff.Words(from.Body, to.Body)
+		} else {
+			resp["title"] = diff.Unified(from.ID, to.ID, from.Title, to.Title, diffContext)
+			resp["body"] = diff.Unified(from.ID, to.ID, from.Body, to.Body, diffContext)
+		}
+		return c.Status(fiber.StatusOK).JSON(resp)
+	}
+}
+
+// restoreNoteRevision makes an older revision the current title and body of its note, which is
+// what readers of a deployed note see. The restored version stays until the note is edited in its
+// source app.
+func restoreNoteRevision() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*database.User)
+		noteID := c.Params("id")
+		revision, err := env.Default.Database.RestoreNoteRevision(c.Params("revision"), noteID, user.ID)
+		if err != nil {
+			slog.Error("restore note revision", "error", err)
+			return sendError(c, err)
+		}
+
+		setActivity(user.ID, ATNoteRevisionRestored, onlineString(c, "note %s restored to revision %s", noteID, revision.RestoredFrom))
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message":  "revision restored successfully",
+			"error":    nil,
+			"revision": revision,
+		})
+	}
+}
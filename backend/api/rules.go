@@ -0,0 +1,63 @@
+package api
+
+import (
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/database"
+)
+
+func listSyncRules() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*database.User)
+		rules, err := env.Default.Database.ListSyncRules(user.ID)
+		if err != nil {
+			slog.Error("list sync rules", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error": nil,
+			"rules": rules,
+		})
+	}
+}
+
+// createSyncRule adds a sync rule. It applies to the notes synced from then on.
+func createSyncRule() fiber.Handler {
+	type request struct {
+		Action string `json:"action"`
+		Match  string `json:"match"`
+		Value  string `json:"value"`
+	}
+	return handler(func(c *fiber.Ctx, body request) error {
+		user := c.Locals("user").(*database.User)
+		rule := &database.SyncRule{UserID: user.ID, Action: body.Action, Match: body.Match, Value: body.Value}
+		if msg := rule.Normalize(); msg != "" {
+			return sendStringError(c, fiber.StatusBadRequest, msg)
+		}
+
+		if err := env.Default.Database.InsertSyncRule(rule); err != nil {
+			slog.Error("insert sync rule", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"error": nil,
+			"rule":  rule,
+		})
+	})
+}
+
+func deleteSyncRule() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*database.User)
+		if err := env.Default.Database.DeleteSyncRule(c.Params("id"), user.ID); err != nil {
+			slog.Error("delete sync rule", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"message": "sync rule deleted successfully",
+			"error":   nil,
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/database"
+)
+
+// schedulerMaxWait is the longest the scheduler sleeps, so that it also sees the schedules set
+// through other instances of the backend.
+const schedulerMaxWait = 10 * time.Minute
+
+// schedulerWake wakes the scheduler up when a schedule changes.
+var schedulerWake = make(chan struct{}, 1)
+
+// wakeScheduler makes the scheduler look for the next schedule again, without blocking.
+func wakeScheduler() {
+	select {
+	case schedulerWake <- struct{}{}:
+	default:
+	}
+}
+
+// RunScheduler deploys and undeploys the notes whose schedules are due, sleeping until the next
+// one in between. Schedules are stored with the notes, so the ones due while the backend was down
+// are run when it starts. It never returns.
+func RunScheduler() {
+	for {
+		wait := schedulerMaxWait
+		if err := runNoteSchedules(time.Now()); err != nil {
+			wait = time.Minute
+		} else if next, err := env.Default.Database.NextNoteSchedule(); err != nil {
+			slog.Error("next note schedule", "error", err)
+			wait = time.Minute
+		} else if !next.IsZero() {
+			wait = min(wait, max(time.Until(next), time.Second))
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-schedulerWake:
+			timer.Stop()
+		}
+	}
+}
+
+// runNoteSchedules runs the schedules due by now and records their activities.
+func runNoteSchedules(now time.Time) error {
+	changes, err := env.Default.Database.RunNoteSchedules(now)
+	if err != nil {
+		slog.Error("run note schedules", "error", err)
+		return err
+	}
+	for _, change := range changes {
+		switch change.Action {
+		case database.ScheduleDeployed:
+			setActivity(change.UserID, ATNoteDeployed, fmt.Sprintf("note %s deployed as scheduled", change.NoteID))
+		case database.ScheduleUndeployed:
+			setActivity(change.UserID, ATNoteUndeployed, fmt.Sprintf("note %s undeployed as it expired", change.NoteID))
+		}
+	}
+	if len(changes) > 0 {
+		slog.Info("ran note schedules", "count", len(changes))
+	}
+	return nil
+}
+
+// scheduleNote sets when a note is deployed and when it expires and is undeployed. Both times are
+// RFC 3339 timestamps in the future, and empty ones are not scheduled. The expiry must come after
+// the deploy, and needs the note to be deployed or scheduled to be.
+func scheduleNote() fiber.Handler {
+	type request struct {
+		DeployAt  string `json:"deploy_at"`
+		ExpiresAt string `json:"expires_at"`
+	}
+	return handler(func(c *fiber.Ctx, body request) error {
+		noteID := c.Params("id")
+		user := c.Locals("user").(*database.User)
+
+		var schedule database.NoteSchedule
+		for _, field := range []struct {
+			name  string
+			value string
+			dest  *time.Time
+		}{
+			{"deploy_at", body.DeployAt, &schedule.DeployAt},
+			{"expires_at", body.ExpiresAt, &schedule.ExpiresAt},
+		} {
+			if field.value == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, field.value)
+			if err != nil {
+				return sendStringError(c, fiber.StatusBadRequest, fmt.Sprintf("invalid %s, must be an RFC 3339 timestamp", field.name))
+			}
+			if !t.After(time.Now()) {
+				return sendStringError(c, fiber.StatusBadRequest, fmt.Sprintf("invalid %s, must be in the future", field.name))
+			}
+			*field.dest = t
+		}
+		if !schedule.DeployAt.IsZero() && !schedule.ExpiresAt.IsZero() && !schedule.ExpiresAt.After(schedule.DeployAt) {
+			return sendStringError(c, fiber.StatusBadRequest, "invalid expires_at, must be after deploy_at")
+		}
+
+		note, err := env.Default.Database.GetNoteByID(noteID)
+		if err != nil {
+			slog.Error("get note by ID", "error", err)
+			return sendError(c, err)
+		}
+		if !isNoteOwner(c, note) {
+			return sendError(c, database.ErrNoRows)
+		}
+		if !schedule.ExpiresAt.IsZero() && schedule.DeployAt.IsZero() && !note.Deployed {
+			return sendStringError(c, fiber.StatusBadRequest, "note is not deployed, schedule a deploy_at for it to expire")
+		}
+
+		if err := env.Default.Database.SetNoteSchedule(note.ID, user.ID, schedule); err != nil {
+			slog.Error("set note schedule", "error", err)
+			return sendError(c, err)
+		}
+		wakeScheduler()
+
+		response := fiber.Map{"error": nil}
+		if !schedule.DeployAt.IsZero() {
+			response["deploy_at"] = schedule.DeployAt.UTC().Format(time.RFC3339Nano)
+		}
+		if !schedule.ExpiresAt.IsZero() {
+			response["expires_at"] = schedule.ExpiresAt.UTC().Format(time.RFC3339Nano)
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	})
+}
+
+// unscheduleNote cancels the scheduled deploy and expiry of a note.
+func unscheduleNote() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*database.User)
+		if err := env.Default.Database.SetNoteSchedule(c.Params("id"), user.ID, database.NoteSchedule{}); err != nil {
+			slog.Error("set note schedule", "error", err)
+			return sendError(c, err)
+		}
+		wakeScheduler()
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error":   nil,
+			"message": "note schedule cancelled",
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/database"
+)
+
+func TestRunNoteSchedulesActivities(t *testing.T) {
+	db := database.Memory("")
+	env.Default.Database = db
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	results, err := db.InsertNotesForUser("alice", []database.Note{
+		{Source: "apple-notes", SourceIdentifier: "x1", Title: "x1", CreatedAt: "2026-01-01T00:00:00Z", UpdatedAt: "2026-01-01T00:00:00Z"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	noteID := results[0].ID
+	err = db.SetNoteSchedule(noteID, "alice", database.NoteSchedule{DeployAt: start.Add(time.Hour), ExpiresAt: start.Add(2 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, now := range []time.Time{start, start.Add(time.Hour), start.Add(90 * time.Minute), start.Add(2 * time.Hour)} {
+		if err := runNoteSchedules(now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	activities, err := db.GetActivities("alice", time.Time{}, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, activity := range activities {
+		types = append(types, activity.Type)
+	}
+	slices.Sort(types)
+	if want := []string{ATNoteDeployed, ATNoteUndeployed}; !slices.Equal(types, want) {
+		t.Errorf("got activities %v, want %v", types, want)
+	}
+}
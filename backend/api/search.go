@@ -0,0 +1,119 @@
+package api
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/backend/sanitize"
+	"github.com/shashwtd/webnotes/database"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// searchOptions reads the query and page of a search from the query parameters q, limit and
+// offset. It returns an error message if they are invalid.
+func searchOptions(c *fiber.Ctx) (database.SearchOptions, string) {
+	opts := database.SearchOptions{
+		Query:  strings.TrimSpace(c.Query("q")),
+		Limit:  c.QueryInt("limit", defaultSearchLimit),
+		Offset: c.QueryInt("offset", 0),
+	}
+	if opts.Query == "" {
+		return opts, "missing q query parameter"
+	}
+	if opts.Limit < 1 || opts.Limit > maxSearchLimit {
+		return opts, "invalid limit, must be between 1 and " + strconv.Itoa(maxSearchLimit)
+	}
+	if opts.Offset < 0 {
+		return opts, "invalid offset"
+	}
+	return opts, ""
+}
+
+// sanitizeResults sanitizes the highlights of search results. They are html made from the text of
+// the notes, which can hold anything that looks like a tag.
+func sanitizeResults(results []database.SearchResult) {
+	for i := range results {
+		results[i].TitleHighlight = sanitize.HTML(results[i].TitleHighlight)
+		results[i].Snippet = sanitize.HTML(results[i].Snippet)
+	}
+}
+
+// searchNotes searches the current user's notes. Besides q, limit and offset, the deployed query
+// parameter (true or false) and the from and to query parameters (RFC 3339 times, from inclusive)
+// filter the notes by whether they are deployed and by when they were last updated.
+func searchNotes() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		opts, msg := searchOptions(c)
+		if msg != "" {
+			return sendStringError(c, fiber.StatusBadRequest, msg)
+		}
+
+		if raw := c.Query("deployed"); raw != "" {
+			deployed, err := strconv.ParseBool(raw)
+			if err != nil {
+				return sendStringError(c, fiber.StatusBadRequest, "invalid deployed, must be true or false")
+			}
+			opts.Deployed = &deployed
+		}
+		var err error
+		if raw := c.Query("from"); raw != "" {
+			if opts.UpdatedAfter, err = time.Parse(time.RFC3339, raw); err != nil {
+				return sendStringError(c, fiber.StatusBadRequest, "invalid from, must be an RFC 3339 time")
+			}
+		}
+		if raw := c.Query("to"); raw != "" {
+			if opts.UpdatedBefore, err = time.Parse(time.RFC3339, raw); err != nil {
+				return sendStringError(c, fiber.StatusBadRequest, "invalid to, must be an RFC 3339 time")
+			}
+		}
+
+		user := c.Locals("user").(*database.User)
+		results, err := env.Default.Database.SearchNotes(user.ID, opts)
+		if err != nil {
+			slog.Error("search notes", "error", err)
+			return sendError(c, err)
+		}
+		sanitizeResults(results)
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error":   nil,
+			"results": results,
+		})
+	}
+}
+
+// searchDeployedNotes searches the deployed notes of a user, as their readers see them.
+func searchDeployedNotes() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		opts, msg := searchOptions(c)
+		if msg != "" {
+			return sendStringError(c, fiber.StatusBadRequest, msg)
+		}
+		opts.Public = true
+
+		username := c.Params("username")
+		userID, err := env.Default.Database.GetUserIDByUsername(username)
+		if err != nil {
+			slog.Error("get user ID by username", "username", username, "error", err)
+			return sendError(c, err)
+		}
+
+		results, err := env.Default.Database.SearchNotes(userID, opts)
+		if err != nil {
+			slog.Error("search deployed notes", "error", err)
+			return sendError(c, err)
+		}
+		sanitizeResults(results)
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error":   nil,
+			"results": results,
+		})
+	}
+}
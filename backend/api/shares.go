@@ -0,0 +1,142 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/database"
+)
+
+// maxShareLifetime is how far in the future share links can expire.
+const maxShareLifetime = 90 * 24 * time.Hour
+
+// sharedNoteURL returns the URL reading a note shared with token.
+func sharedNoteURL(token string) string {
+	return env.Default.PublicURL + "/api/v1/notes/shared/" + token
+}
+
+// ownedNote returns the note with the id of the path if the current user owns it.
+func ownedNote(c *fiber.Ctx) (*database.Note, error) {
+	note, err := env.Default.Database.GetNoteByID(c.Params("id"))
+	if err != nil {
+		slog.Error("get note by ID", "error", err)
+		return nil, err
+	}
+	if !isNoteOwner(c, note) {
+		return nil, database.ErrNoRows
+	}
+	return note, nil
+}
+
+func listNoteShares() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*database.User)
+		note, err := ownedNote(c)
+		if err != nil {
+			return sendError(c, err)
+		}
+		shares, err := env.Default.Database.ListNoteShares(note.ID, user.ID)
+		if err != nil {
+			slog.Error("list note shares", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error":  nil,
+			"shares": shares,
+		})
+	}
+}
+
+// createNoteShare mints a share link to a note, deployed or not. It expires at expires_at, an RFC
+// 3339 timestamp at most maxShareLifetime away, and is valid for max_views views if it is not 0.
+// The token of the link is only returned here.
+func createNoteShare() fiber.Handler {
+	type request struct {
+		ExpiresAt string `json:"expires_at"`
+		MaxViews  int64  `json:"max_views"`
+	}
+	return handler(func(c *fiber.Ctx, body request) error {
+		user := c.Locals("user").(*database.User)
+		expiresAt, err := time.Parse(time.RFC3339, body.ExpiresAt)
+		switch {
+		case body.ExpiresAt == "":
+			return sendStringError(c, fiber.StatusBadRequest, "missing expires_at")
+		case err != nil:
+			return sendStringError(c, fiber.StatusBadRequest, "invalid expires_at, must be an RFC 3339 timestamp")
+		case !expiresAt.After(time.Now()):
+			return sendStringError(c, fiber.StatusBadRequest, "invalid expires_at, must be in the future")
+		case expiresAt.After(time.Now().Add(maxShareLifetime)):
+			return sendStringError(c, fiber.StatusBadRequest, "invalid expires_at, share links expire within 90 days")
+		case body.MaxViews < 0:
+			return sendStringError(c, fiber.StatusBadRequest, "invalid max_views, must be positive or 0 for no limit")
+		}
+
+		note, err := ownedNote(c)
+		if err != nil {
+			return sendError(c, err)
+		}
+		share := &database.NoteShare{
+			NoteID:    note.ID,
+			UserID:    user.ID,
+			ExpiresAt: expiresAt.UTC().Format(time.RFC3339Nano),
+			MaxViews:  body.MaxViews,
+		}
+		if err := env.Default.Database.InsertNoteShare(share); err != nil {
+			slog.Error("insert note share", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"error": nil,
+			"share": share,
+			"url":   sharedNoteURL(share.Token),
+		})
+	})
+}
+
+func revokeNoteShare() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*database.User)
+		if err := env.Default.Database.RevokeNoteShare(c.Params("share"), c.Params("id"), user.ID); err != nil {
+			slog.Error("revoke note share", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error":   nil,
+			"message": "share link revoked successfully",
+		})
+	}
+}
+
+// getSharedNote reads a note with the token of a share link, which counts as a view of the link.
+// Valid links give access to the note whether it is deployed or not, and readers see its current
+// version, even if a snapshot of it is deployed. Links to notes deleted in their source stop
+// working. Tokens that are not valid are passed on to the next route, the note of a user named
+// "shared".
+func getSharedNote() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		share, err := env.Default.Database.UseNoteShare(c.Params("token"))
+		if errors.Is(err, database.ErrNoRows) {
+			return c.Next()
+		}
+		if err != nil {
+			slog.Error("use note share", "error", err)
+			return sendError(c, err)
+		}
+		note, err := env.Default.Database.GetNoteByID(share.NoteID)
+		if err != nil {
+			slog.Error("get note by ID", "error", err)
+			return sendError(c, err)
+		}
+		if note.DeletedAt != "" {
+			return sendError(c, database.ErrNoRows)
+		}
+
+		sanitizeNote(note)
+		c.Set(fiber.HeaderCacheControl, "no-store") // every read is a view of the link
+		setRobots(c, true)
+		return c.JSON(withMarkdown(note))
+	}
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/backend/session"
+	"github.com/shashwtd/webnotes/database"
+)
+
+// testNotesApp returns an app serving the notes group at /notes, backed by an in-memory store with
+// user and their notes, one per title, deployed. It returns the session cookie of user and the
+// ids of the notes.
+func testNotesApp(t *testing.T, user *database.User, titles ...string) (*fiber.App, string, []string) {
+	t.Helper()
+	env.Default.JWTSigningKey = []byte("test signing key")
+	env.Default.PublicURL = "https://api.webnotes.example"
+	db := database.Memory("")
+	if err := db.InsertUser(user); err != nil {
+		t.Fatal(err)
+	}
+	var notes []database.Note
+	for _, title := range titles {
+		notes = append(notes, database.Note{Source: "apple-notes", SourceIdentifier: title, Title: title, Body: title,
+			CreatedAt: "2026-01-01T00:00:00Z", UpdatedAt: "2026-01-02T00:00:00Z"})
+	}
+	results, err := db.InsertNotesForUser(user.ID, notes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, result := range results {
+		if err := db.DeployNote(result.ID, user.ID); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, result.ID)
+	}
+	env.Default.Database = db
+
+	cookie, err := session.NewSession(user.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := fiber.New()
+	setNotesGroup(app.Group("/notes"))
+	return app, session.CookieName + "=" + cookie, ids
+}
+
+// testNotesRequest makes a request to app, with cookie if it is set, and decodes the JSON response
+// in out if it is not nil. It returns the status of the response.
+func testNotesRequest(t *testing.T, app *fiber.App, cookie, method, path, body string, out any) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	if cookie != "" {
+		req.Header.Set(fiber.HeaderCookie, cookie)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != nil {
+		json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp.StatusCode
+}
+
+func TestShareRoutesLeaveSlugs(t *testing.T) {
+	// a user named "shared" with a note slugged "shares"
+	user := &database.User{Username: "shared", Email: "shared@example.com"}
+	app, cookie, ids := testNotesApp(t, user, "shares", "hello")
+
+	for _, tt := range []struct {
+		name, cookie, path string
+		want               string
+	}{
+		{"note slugged shares", "", "/notes/shared/shares", "shares"},
+		{"note of user shared", "", "/notes/shared/hello", "hello"},
+		{"note slugged shares of the owner", cookie, "/notes/shared/shares", "shares"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var note database.Note
+			if status := testNotesRequest(t, app, tt.cookie, fiber.MethodGet, tt.path, "", &note); status != fiber.StatusOK || note.Slug != tt.want {
+				t.Errorf("got status %d and note %q, want note %q", status, note.Slug, tt.want)
+			}
+		})
+	}
+
+	var response struct {
+		Shares []database.NoteShare `json:"shares"`
+	}
+	if status := testNotesRequest(t, app, cookie, fiber.MethodGet, "/notes/"+ids[0]+"/shares", "", &response); status != fiber.StatusOK || response.Shares == nil {
+		t.Errorf("listing shares got status %d and shares %v, want an empty list", status, response.Shares)
+	}
+	if status := testNotesRequest(t, app, "", fiber.MethodGet, "/notes/"+ids[0]+"/shares", "", nil); status != fiber.StatusUnauthorized {
+		t.Errorf("listing shares without session got status %d, want %d", status, fiber.StatusUnauthorized)
+	}
+}
+
+func TestShareLinks(t *testing.T) {
+	user := &database.User{Username: "alice", Email: "alice@example.com"}
+	app, cookie, ids := testNotesApp(t, user, "private")
+	shares := "/notes/" + ids[0] + "/shares"
+	if err := env.Default.Database.UndeployNote(ids[0], user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	type created struct {
+		Share database.NoteShare `json:"share"`
+		URL   string             `json:"url"`
+	}
+	create := func(expiresAt time.Time, maxViews int) (int, created) {
+		t.Helper()
+		var response created
+		body, _ := json.Marshal(map[string]any{"expires_at": expiresAt.Format(time.RFC3339), "max_views": maxViews})
+		status := testNotesRequest(t, app, cookie, fiber.MethodPost, shares, string(body), &response)
+		return status, response
+	}
+	read := func(token string) int {
+		t.Helper()
+		return testNotesRequest(t, app, "", fiber.MethodGet, "/notes/shared/"+token, "", nil)
+	}
+
+	for _, tt := range []struct {
+		name      string
+		expiresAt time.Time
+		maxViews  int
+	}{
+		{"expired", time.Now().Add(-time.Minute), 0},
+		{"past the max lifetime", time.Now().Add(maxShareLifetime + time.Hour), 0},
+		{"negative views", time.Now().Add(time.Hour), -1},
+	} {
+		if status, _ := create(tt.expiresAt, tt.maxViews); status != fiber.StatusBadRequest {
+			t.Errorf("%s: got status %d, want %d", tt.name, status, fiber.StatusBadRequest)
+		}
+	}
+
+	t.Run("view limit", func(t *testing.T) {
+		status, limited := create(time.Now().Add(time.Hour), 2)
+		if status != fiber.StatusCreated || limited.URL != "https://api.webnotes.example/api/v1/notes/shared/"+limited.Share.Token {
+			t.Fatalf("got status %d and url %q", status, limited.URL)
+		}
+		for i, want := range []int{fiber.StatusOK, fiber.StatusOK, fiber.StatusNotFound, fiber.StatusNotFound} {
+			if got := read(limited.Share.Token); got != want {
+				t.Errorf("read %d got status %d, want %d", i+1, got, want)
+			}
+		}
+		if status := read("not-a-token"); status != fiber.StatusNotFound {
+			t.Errorf("unknown token got status %d, want %d", status, fiber.StatusNotFound)
+		}
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		share := &database.NoteShare{NoteID: ids[0], UserID: user.ID, ExpiresAt: time.Now().Add(-time.Second).UTC().Format(time.RFC3339Nano)}
+		if err := env.Default.Database.InsertNoteShare(share); err != nil {
+			t.Fatal(err)
+		}
+		if status := read(share.Token); status != fiber.StatusNotFound {
+			t.Errorf("expired link got status %d, want %d", status, fiber.StatusNotFound)
+		}
+	})
+
+	t.Run("revocation", func(t *testing.T) {
+		status, unlimited := create(time.Now().Add(time.Hour), 0)
+		if status != fiber.StatusCreated {
+			t.Fatalf("got status %d", status)
+		}
+		for range 3 {
+			if status := read(unlimited.Share.Token); status != fiber.StatusOK {
+				t.Fatalf("link without view limit got status %d", status)
+			}
+		}
+
+		revoke := shares + "/" + unlimited.Share.ID
+		if status := testNotesRequest(t, app, cookie, fiber.MethodDelete, revoke, "", nil); status != fiber.StatusOK {
+			t.Fatalf("revoking got status %d", status)
+		}
+		if status := read(unlimited.Share.Token); status != fiber.StatusNotFound {
+			t.Errorf("revoked link got status %d, want %d", status, fiber.StatusNotFound)
+		}
+		if status := testNotesRequest(t, app, cookie, fiber.MethodDelete, revoke, "", nil); status != fiber.StatusNotFound {
+			t.Errorf("revoking again got status %d, want %d", status, fiber.StatusNotFound)
+		}
+
+		var response struct {
+			Shares []database.NoteShare `json:"shares"`
+		}
+		testNotesRequest(t, app, cookie, fiber.MethodGet, shares, "", &response)
+		listed := false
+		for _, share := range response.Shares {
+			if share.Token != "" {
+				t.Errorf("listed share %s has its token", share.ID)
+			}
+			if share.ID == unlimited.Share.ID {
+				listed = true
+				if share.RevokedAt == "" || share.Views != 3 {
+					t.Errorf("got listed share revoked at %q with %d views, want revoked with 3 views", share.RevokedAt, share.Views)
+				}
+			}
+		}
+		if !listed {
+			t.Error("revoked share is not listed")
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/xml"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/database"
+)
+
+// maxSitemapURLs is how many urls a sitemap, or sitemaps a sitemap index, can have.
+const maxSitemapURLs = 50000
+
+// SetSitemapGroup serves robots.txt and the sitemaps of the pages of profiles and deployed notes:
+// a sitemap index with a sitemap per user, so that large instances stay under the size limits of
+// sitemaps. Noindex users and notes are left out.
+func SetSitemapGroup(router fiber.Router) {
+	router.Get("/robots.txt", robotsTxt())               // GET /robots.txt
+	router.Get("/sitemap.xml", sitemapIndex())           // GET /sitemap.xml (sitemap index of the users with indexable notes)
+	router.Get("/sitemaps/:username.xml", userSitemap()) // GET /sitemaps/:username.xml (sitemap of the profile and deployed notes of a user)
+}
+
+func robotsTxt() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		sitemap := env.Default.PublicURL + "/sitemap.xml"
+		if domain := customDomain(c); domain != "" {
+			sitemap = "https://" + domain + "/sitemap.xml"
+		}
+		c.Set("Content-Type", fiber.MIMETextPlainCharsetUTF8)
+		// the og images of notes are under /api/ but must be fetched by link previews
+		return c.SendString("User-agent: *\nAllow: /api/v1/notes/og/\nDisallow: /api/\n\nSitemap: " + sitemap + "\n")
+	}
+}
+
+type sitemapIndexDoc struct {
+	XMLName  xml.Name       `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+type urlSet struct {
+	XMLName xml.Name       `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapEntry `xml:"url"`
+}
+
+// sitemapEntry is a sitemap of an index or a url of a sitemap.
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+func sitemapIndex() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		users, err := env.Default.Database.ListSitemapUsers()
+		if err != nil {
+			slog.Error("list sitemap users", "error", err)
+			return sendError(c, err)
+		}
+
+		doc := sitemapIndexDoc{Sitemaps: []sitemapEntry{}}
+		for _, user := range users[:min(len(users), maxSitemapURLs)] {
+			doc.Sitemaps = append(doc.Sitemaps, sitemapEntry{
+				Loc:     env.Default.PublicURL + "/sitemaps/" + user.Username + ".xml",
+				LastMod: sitemapTime(user.LastModified),
+			})
+		}
+		c.Set("Cache-Control", "public, max-age=3600")
+		return sendXML(c, "application/xml; charset=utf-8", doc)
+	}
+}
+
+func userSitemap() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := env.Default.Database.GetUserByUsername(c.Params("username"))
+		if err != nil {
+			slog.Error("get user by username", "error", err)
+			return sendError(c, err)
+		}
+		if user.Noindex {
+			return sendError(c, database.ErrNoRows)
+		}
+		notes, err := env.Default.Database.ListDeployedNotes(user.ID, database.ListOptions{})
+		if err != nil {
+			slog.Error("list deployed notes", "error", err)
+			return sendError(c, err)
+		}
+
+		// urls must be on the host of the sitemap, so on the custom domain it was requested on
+		profile := sitemapEntry{Loc: profileURL(c, user.Username)}
+		var lastModified time.Time
+		doc := urlSet{URLs: []sitemapEntry{profile}}
+		for i := range notes {
+			note := &notes[i]
+			if note.Noindex || len(doc.URLs) == maxSitemapURLs {
+				continue
+			}
+			updated := noteUpdated(note)
+			if updated.After(lastModified) {
+				lastModified = updated
+			}
+			doc.URLs = append(doc.URLs, sitemapEntry{
+				Loc:     profile.Loc + "/" + note.Slug,
+				LastMod: updated.UTC().Format(time.RFC3339),
+			})
+		}
+		if !lastModified.IsZero() { // the profile lists the notes
+			doc.URLs[0].LastMod = lastModified.UTC().Format(time.RFC3339)
+		}
+		c.Set("Cache-Control", "public, max-age=3600")
+		return sendXML(c, "application/xml; charset=utf-8", doc)
+	}
+}
+
+// sitemapTime formats a timestamp of the database as sitemaps expect, or returns nothing if it
+// cannot be parsed.
+func sitemapTime(timestamp string) string {
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/database"
+)
+
+// robotsAllowed reports whether robots.txt allows crawling path: the longest Allow or Disallow
+// rule matching it wins, and Allow wins ties, like crawlers do (RFC 9309).
+func robotsAllowed(robots, path string) bool {
+	allowed, longest := true, -1
+	for _, line := range strings.Split(robots, "\n") {
+		rule, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if value == "" || !strings.HasPrefix(path, value) {
+			continue
+		}
+		switch rule = strings.ToLower(strings.TrimSpace(rule)); {
+		case rule == "allow" && len(value) >= longest:
+			allowed, longest = true, len(value)
+		case rule == "disallow" && len(value) > longest:
+			allowed, longest = false, len(value)
+		}
+	}
+	return allowed
+}
+
+func TestRobotsTxt(t *testing.T) {
+	env.Default.PublicURL = "https://webnotes.example"
+	app := fiber.New()
+	SetSitemapGroup(app)
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/robots.txt", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	robots := string(data)
+
+	ogURL := strings.TrimPrefix(ogImageURL(&database.Note{ID: "6f1c1a4e-2b8a-4c57-9a53-1d2f0c3b4a5e"}), env.Default.PublicURL)
+	for path, want := range map[string]bool{
+		ogURL:                       true,
+		"/alice/hello-world":        true,
+		"/api/v1/notes/alice/hello": false,
+		"/api/v1/auth/login":        false,
+	} {
+		if got := robotsAllowed(robots, path); got != want {
+			t.Errorf("robots.txt allows %s: got %v, want %v\n%s", path, got, want, robots)
+		}
+	}
+	if !strings.Contains(robots, "Sitemap: https://webnotes.example/sitemap.xml\n") {
+		t.Errorf("got robots.txt\n%s\nwant the sitemap of the public url", robots)
+	}
+}
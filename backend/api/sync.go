@@ -0,0 +1,148 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/database"
+)
+
+// serverOwnedNoteFields are note fields that only the server sets. A sync payload setting any of
+// them to something other than its zero value is rejected, so that clients cannot address notes
+// by id, write into other accounts, deploy or publish, count views or pick slugs.
+var serverOwnedNoteFields = []string{"id", "user_id", "deployed", "views", "slug", "content_hash",
+	"publish_mode", "published_title", "published_body", "published_at"}
+
+// parseSyncRequest parses the body of a sync. It accepts a database.SyncRequest object, or the
+// plain array of notes sent by older clients (which never deletes anything).
+func parseSyncRequest(payload []byte) (*database.SyncRequest, error) {
+	req := &database.SyncRequest{}
+	rawNotes := payload
+	if !bytes.HasPrefix(bytes.TrimSpace(payload), []byte("[")) {
+		var body struct {
+			Notes json.RawMessage     `json:"notes"`
+			Live  map[string][]string `json:"live"`
+		}
+		if err := json.Unmarshal(payload, &body); err != nil {
+			return nil, fmt.Errorf("parse sync request: %w", err)
+		}
+		rawNotes = body.Notes
+		req.Live = body.Live
+	}
+	if len(rawNotes) == 0 {
+		return req, nil // only deletions
+	}
+
+	if err := checkSyncNotes(rawNotes); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(rawNotes, &req.Notes); err != nil {
+		return nil, fmt.Errorf("parse sync notes: %w", err)
+	}
+	return req, nil
+}
+
+// checkSyncNotes returns an error naming the first server-owned field set in the notes of a sync.
+func checkSyncNotes(rawNotes []byte) error {
+	var notes []map[string]json.RawMessage
+	if err := json.Unmarshal(rawNotes, &notes); err != nil {
+		return fmt.Errorf("parse sync notes: %w", err)
+	}
+	for i, note := range notes {
+		for _, field := range serverOwnedNoteFields {
+			value, ok := note[field]
+			if !ok {
+				continue
+			}
+			switch string(bytes.TrimSpace(value)) {
+			case "null", `""`, "false", "0": // zero values, sent by clients marshalling database.Note
+				continue
+			}
+			return fmt.Errorf("note %d: field %q is set by the server and cannot be synced", i, field)
+		}
+	}
+	return nil
+}
+
+func saveNotes() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		req, err := parseSyncRequest(c.Body())
+		if err != nil {
+			return sendStringError(c, fiber.StatusBadRequest, err.Error())
+		}
+
+		user := c.Locals("user").(*database.User) // ensure user is set in context by session middleware
+		results, err := syncNotes(c, user, req.Notes, req.Live)
+		if err != nil {
+			return sendError(c, err)
+		}
+		return sendSyncResults(c, results)
+	}
+}
+
+// syncNotes syncs notes for user and logs what happened as activities. It returns the sync
+// results, or the error of the store without sending a response.
+func syncNotes(c *fiber.Ctx, user *database.User, notes []database.Note, live map[string][]string) ([]database.SyncResult, error) {
+	results, err := env.Default.Database.InsertNotesForUser(user.ID, notes, live)
+	if err != nil {
+		slog.Error("insert notes for user", "error", err)
+		return nil, err
+	}
+
+	counts := make(map[database.SyncStatus]int)
+	for _, result := range results {
+		counts[result.Status]++
+		switch {
+		case result.Status == database.SyncDeleted:
+			setActivity(user.ID, ATNoteDeleted, onlineString(c, "note %s deleted from %s", result.ID, result.Source))
+			if result.Undeployed {
+				setActivity(user.ID, ATNoteUndeployed, onlineString(c, "note %s undeployed because it was deleted", result.ID))
+			}
+		case result.Deployed:
+			setActivity(user.ID, ATNoteAutoDeployed, onlineString(c, "note %s deployed by sync rule %s", result.ID, result.RuleID))
+		case result.Undeployed:
+			setActivity(user.ID, ATNoteAutoUndeployed, onlineString(c, "note %s undeployed by sync rule %s", result.ID, result.RuleID))
+		}
+	}
+
+	setActivity(user.ID, ATClientSynced, onlineString(c, "%d notes synced (%d inserted, %d updated, %d unchanged, %d failed, %d excluded, %d deleted)",
+		len(notes), counts[database.SyncInserted], counts[database.SyncUpdated], counts[database.SyncUnchanged],
+		counts[database.SyncFailed], counts[database.SyncExcluded], counts[database.SyncDeleted]))
+	return results, nil
+}
+
+// sendSyncResults sends the results of a sync.
+func sendSyncResults(c *fiber.Ctx, results []database.SyncResult) error {
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "notes saved successfully",
+		"results": results,
+		"error":   nil,
+	})
+}
+
+// getNoteManifest returns the updated_at and content_hash of every note the current user synced
+// from a source, so that clients can upload only the notes that are new or changed.
+func getNoteManifest() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		source := c.Query("source")
+		if source == "" {
+			return sendStringError(c, fiber.StatusBadRequest, "missing source query parameter")
+		}
+
+		user := c.Locals("user").(*database.User)
+		manifest, err := env.Default.Database.GetNoteManifest(user.ID, source)
+		if err != nil {
+			slog.Error("get note manifest", "error", err)
+			return sendError(c, err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error":    nil,
+			"manifest": manifest,
+		})
+	}
+}
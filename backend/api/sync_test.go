@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/database"
+)
+
+// testSyncApp returns an app syncing the notes of user with saveNotes at POST /sync, backed by an
+// in-memory store.
+func testSyncApp(t *testing.T, user *database.User) *fiber.App {
+	t.Helper()
+	db := database.Memory("")
+	if err := db.InsertUser(user); err != nil {
+		t.Fatal(err)
+	}
+	env.Default.Database = db
+
+	app := fiber.New()
+	app.Post("/sync", func(c *fiber.Ctx) error {
+		c.Locals("user", user)
+		return c.Next()
+	}, saveNotes())
+	return app
+}
+
+func TestSaveNotesRejectsServerOwnedFields(t *testing.T) {
+	user := &database.User{Username: "alice", Email: "alice@example.com"}
+	app := testSyncApp(t, user)
+
+	for _, field := range []string{
+		`"user_id": "6f1c1a4e-2b8a-4c57-9a53-1d2f0c3b4a5e"`,
+		`"deployed": true`,
+		`"views": 1000`,
+		`"id": "6f1c1a4e-2b8a-4c57-9a53-1d2f0c3b4a5e"`,
+	} {
+		for _, body := range []string{
+			`[{"source": "apple-notes", "source_identifier": "x1", "title": "Hello", ` + field + `}]`,
+			`{"notes": [{"source": "apple-notes", "source_identifier": "x1", "title": "Hello", ` + field + `}]}`,
+		} {
+			req := httptest.NewRequest(fiber.MethodPost, "/sync", strings.NewReader(body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != fiber.StatusBadRequest {
+				t.Errorf("%s: got status %d (%s), want %d", body, resp.StatusCode, data, fiber.StatusBadRequest)
+			}
+		}
+	}
+
+	if count, _ := env.Default.Database.CountNotes(user.ID); count != 0 {
+		t.Errorf("got %d notes synced, want none", count)
+	}
+}
+
+func TestSaveNotesAcceptsZeroServerOwnedFields(t *testing.T) {
+	user := &database.User{Username: "alice", Email: "alice@example.com"}
+	app := testSyncApp(t, user)
+
+	// clients marshalling database.Note send every field with its zero value
+	notes, _ := json.Marshal([]database.Note{{Source: "apple-notes", SourceIdentifier: "x1", Title: "Hello"}})
+	req := httptest.NewRequest(fiber.MethodPost, "/sync", strings.NewReader(string(notes)))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusCreated {
+		data, _ := io.ReadAll(resp.Body)
+		t.Fatalf("got status %d (%s), want %d", resp.StatusCode, data, fiber.StatusCreated)
+	}
+	if count, _ := env.Default.Database.CountNotes(user.ID); count != 1 {
+		t.Errorf("got %d notes synced, want 1", count)
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/database"
+)
+
+// listOptions reads the filters of a list of notes from the query parameters: tag, with or
+// without its leading #.
+func listOptions(c *fiber.Ctx) database.ListOptions {
+	return database.ListOptions{Tag: database.NormalizeTag(c.Query("tag"))}
+}
+
+func listTags() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*database.User)
+		tags, err := env.Default.Database.ListTags(user.ID, false)
+		if err != nil {
+			slog.Error("list tags", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error": nil,
+			"tags":  tags,
+		})
+	}
+}
+
+// listDeployedTags returns the tags of a user's deployed notes, counting only those.
+func listDeployedTags() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		username := c.Params("username")
+		userID, err := env.Default.Database.GetUserIDByUsername(username)
+		if err != nil {
+			slog.Error("get user ID by username", "username", username, "error", err)
+			return sendError(c, err)
+		}
+
+		tags, err := env.Default.Database.ListTags(userID, true)
+		if err != nil {
+			slog.Error("list deployed tags", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error": nil,
+			"tags":  tags,
+		})
+	}
+}
+
+// listTaggedNotes returns the deployed notes of a user with a tag.
+func listTaggedNotes() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		username := c.Params("username")
+		userID, err := env.Default.Database.GetUserIDByUsername(username)
+		if err != nil {
+			slog.Error("get user ID by username", "username", username, "error", err)
+			return sendError(c, err)
+		}
+
+		tag := database.NormalizeTag(c.Params("tag"))
+		if tag == "" {
+			return sendStringError(c, fiber.StatusBadRequest, "missing tag")
+		}
+		notes, err := env.Default.Database.ListDeployedNotes(userID, database.ListOptions{Tag: tag})
+		if err != nil {
+			slog.Error("list tagged notes", "error", err)
+			return sendError(c, err)
+		}
+		if notes == nil {
+			notes = []database.Note{}
+		}
+		for i := range notes {
+			publicNote(&notes[i])
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error": nil,
+			"tag":   tag,
+			"notes": notes,
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/database"
+)
+
+// maxUploadChunks is the number of chunks an upload can have. Chunks are numbered from 0.
+const maxUploadChunks = 10000
+
+// beginUpload starts a chunked sync, for syncs too large to be sent in one request. The notes are
+// then sent with putUploadChunk, and synced all at once by commitUpload.
+func beginUpload() fiber.Handler {
+	type beginUploadExpectedBody struct {
+		Live map[string][]string `json:"live"` // see database.SyncRequest
+	}
+
+	return handler(func(c *fiber.Ctx, body beginUploadExpectedBody) error {
+		user := c.Locals("user").(*database.User)
+		upload := &database.Upload{
+			UserID:    user.ID,
+			Live:      body.Live,
+			ExpiresAt: time.Now().Add(env.Default.UploadLifetime).UTC().Format(time.RFC3339Nano),
+		}
+		if err := env.Default.Database.CreateUpload(upload); err != nil {
+			slog.Error("create upload", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"error":  nil,
+			"upload": upload,
+		})
+	})
+}
+
+// getUpload returns an upload with the sequence numbers of its received chunks, so that a client
+// resuming it knows which chunks are left to send.
+func getUpload() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*database.User)
+		upload, err := env.Default.Database.GetUpload(c.Params("id"), user.ID)
+		if err != nil {
+			slog.Error("get upload", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error":  nil,
+			"upload": upload,
+		})
+	}
+}
+
+// putUploadChunk stores a chunk of an upload: an array of notes, like the legacy body of a sync.
+// Sending a chunk again replaces it.
+func putUploadChunk() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		seq, err := strconv.Atoi(c.Params("seq"))
+		if err != nil || seq < 0 || seq >= maxUploadChunks {
+			return sendStringError(c, fiber.StatusBadRequest, fmt.Sprintf("invalid chunk number, must be between 0 and %d", maxUploadChunks-1))
+		}
+
+		if err := checkSyncNotes(c.Body()); err != nil {
+			return sendStringError(c, fiber.StatusBadRequest, err.Error())
+		}
+		var notes []database.Note
+		if err := json.Unmarshal(c.Body(), &notes); err != nil {
+			return sendStringError(c, fiber.StatusBadRequest, fmt.Sprintf("parse sync notes: %s", err))
+		}
+
+		user := c.Locals("user").(*database.User)
+		if err := env.Default.Database.PutUploadChunk(c.Params("id"), user.ID, seq, notes); err != nil {
+			slog.Error("put upload chunk", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error": nil,
+		})
+	}
+}
+
+// commitUpload syncs the notes of every chunk of an upload and deletes it. The client tells how
+// many chunks it sent, so that an upload missing some of them is not synced.
+func commitUpload() fiber.Handler {
+	type commitUploadExpectedBody struct {
+		Chunks int `json:"chunks"`
+	}
+
+	return handler(func(c *fiber.Ctx, body commitUploadExpectedBody) error {
+		user := c.Locals("user").(*database.User)
+		uploadID := c.Params("id")
+
+		upload, err := env.Default.Database.GetUpload(uploadID, user.ID)
+		if err != nil {
+			slog.Error("get upload", "error", err)
+			return sendError(c, err)
+		}
+		var missing []int
+		for seq := range body.Chunks {
+			if !slices.Contains(upload.Chunks, seq) {
+				missing = append(missing, seq)
+			}
+		}
+		if len(missing) > 0 || len(upload.Chunks) != body.Chunks {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   fmt.Sprintf("the upload has %d chunks, expected %d", len(upload.Chunks), body.Chunks),
+				"missing": missing,
+			})
+		}
+
+		notes, err := env.Default.Database.GetUploadNotes(uploadID, user.ID)
+		if err != nil {
+			slog.Error("get upload notes", "error", err)
+			return sendError(c, err)
+		}
+		results, err := syncNotes(c, user, notes, upload.Live)
+		if err != nil {
+			return sendError(c, err) // the upload is kept so that the commit can be retried
+		}
+
+		// committing again would only find the notes unchanged, the upload can expire instead
+		if err := env.Default.Database.DeleteUpload(uploadID, user.ID); err != nil {
+			slog.Error("delete upload", "error", err)
+		}
+		return sendSyncResults(c, results)
+	})
+}
+
+// deleteUpload abandons an upload.
+func deleteUpload() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(*database.User)
+		if err := env.Default.Database.DeleteUpload(c.Params("id"), user.ID); err != nil {
+			slog.Error("delete upload", "error", err)
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error": nil,
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/backend/session"
+	"github.com/shashwtd/webnotes/database"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// noteAccessValidity is how long the access token to a password protected note is valid.
+const noteAccessValidity = time.Hour
+
+// setNoteVisibility sets who can read a note. Private notes are undeployed, and private notes made
+// public, unlisted or protected are deployed live. Protected notes need a password, which is kept
+// when the note is made protected again without one.
+func setNoteVisibility() fiber.Handler {
+	type request struct {
+		Visibility string `json:"visibility"`
+		Password   string `json:"password"`
+	}
+	return handler(func(c *fiber.Ctx, body request) error {
+		noteID := c.Params("id")
+		user := c.Locals("user").(*database.User)
+		if !database.ValidVisibility(body.Visibility) {
+			return sendStringError(c, fiber.StatusBadRequest, "invalid visibility, must be private, public, unlisted or protected")
+		}
+
+		note, err := env.Default.Database.GetNoteByID(noteID)
+		if err != nil {
+			slog.Error("get note by ID", "error", err)
+			return sendError(c, err)
+		}
+		if !isNoteOwner(c, note) {
+			return sendError(c, database.ErrNoRows)
+		}
+
+		var passwordHash string
+		switch {
+		case body.Password != "" && body.Visibility != database.VisibilityProtected:
+			return sendStringError(c, fiber.StatusBadRequest, "only protected notes have a password")
+		case len(body.Password) > 72: // bcrypt limit
+			return sendStringError(c, fiber.StatusBadRequest, "password too long, must be at most 72 bytes")
+		case body.Password != "":
+			hash, err := bcrypt.GenerateFromPassword([]byte(body.Password), bcrypt.DefaultCost)
+			if err != nil {
+				slog.Error("hash note password", "error", err)
+				return sendError(c, err)
+			}
+			passwordHash = string(hash)
+		case body.Visibility == database.VisibilityProtected:
+			existing, err := env.Default.Database.GetNotePasswordHash(noteID)
+			if err != nil {
+				slog.Error("get note password hash", "error", err)
+				return sendError(c, err)
+			}
+			if existing == "" {
+				return sendStringError(c, fiber.StatusBadRequest, "missing password, protected notes need one")
+			}
+		}
+
+		if err := env.Default.Database.SetNoteVisibility(noteID, user.ID, body.Visibility, passwordHash); err != nil {
+			slog.Error("set note visibility", "error", err)
+			return sendError(c, err)
+		}
+
+		switch {
+		case !note.Deployed && body.Visibility != database.VisibilityPrivate:
+			setActivity(user.ID, ATNoteDeployed, onlineString(c, "note %s deployed successfully (%s)", noteID, body.Visibility))
+		case note.Deployed && body.Visibility == database.VisibilityPrivate:
+			setActivity(user.ID, ATNoteUndeployed, onlineString(c, "note %s undeployed successfully", noteID))
+		}
+
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error":      nil,
+			"visibility": body.Visibility,
+		})
+	})
+}
+
+// accessNote exchanges the password of a protected note for a short lived access token, to pass
+// as the access_token query parameter when getting the note.
+func accessNote() fiber.Handler {
+	type request struct {
+		Password string `json:"password"`
+	}
+	return handler(func(c *fiber.Ctx, body request) error {
+		note, err := env.Default.Database.GetNoteBySlug(c.Params("username"), c.Params("slug"))
+		if err != nil {
+			slog.Error("get note by username and slug", "error", err)
+			return sendError(c, err)
+		}
+		if note.Visibility != database.VisibilityProtected {
+			return sendError(c, ErrNonDeployedNoteNotAccessible)
+		}
+
+		token, expiresAt, err := newNoteAccessToken(note, body.Password)
+		if err != nil {
+			return sendError(c, err)
+		}
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"error":        nil,
+			"access_token": token,
+			"expires_at":   expiresAt.UTC().Format(time.RFC3339),
+		})
+	})
+}
+
+// newNoteAccessToken returns an access token to a protected note if password is its password.
+func newNoteAccessToken(note *database.Note, password string) (string, time.Time, error) {
+	passwordHash, err := env.Default.Database.GetNotePasswordHash(note.ID)
+	if err != nil {
+		slog.Error("get note password hash", "error", err)
+		return "", time.Time{}, err
+	}
+	if passwordHash == "" || bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) != nil {
+		return "", time.Time{}, ErrWrongNotePassword
+	}
+	token, expiresAt, err := session.NewNoteAccessToken(note.ID, passwordHash, noteAccessValidity)
+	if err != nil {
+		slog.Error("create note access token", "error", err)
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// checkNoteAccess returns ErrProtectedNote if note is password protected and token does not give
+// access to it. Owners always have access.
+func checkNoteAccess(c *fiber.Ctx, note *database.Note, token string) error {
+	if note.Visibility != database.VisibilityProtected || isNoteOwner(c, note) {
+		return nil
+	}
+	passwordHash, err := env.Default.Database.GetNotePasswordHash(note.ID)
+	if err != nil {
+		slog.Error("get note password hash", "error", err)
+		return err
+	}
+	if !session.ValidNoteAccessToken(token, note.ID, passwordHash) {
+		return ErrProtectedNote
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shashwtd/webnotes/backend/env"
+	"github.com/shashwtd/webnotes/database"
+)
+
+// testVisibilityApp returns an app where user sets the visibility of their notes at
+// PUT /visibility/:id, and anonymous readers get notes, their access tokens, feeds and sitemaps,
+// backed by an in-memory store with a note of user per title.
+func testVisibilityApp(t *testing.T, user *database.User, titles ...string) (*fiber.App, []database.SyncResult) {
+	t.Helper()
+	env.Default.JWTSigningKey = []byte("test signing key")
+	env.Default.PublicURL = "https://api.webnotes.example"
+	env.Default.PagesURL = "https://webnotes.example"
+	db := database.Memory("")
+	if err := db.InsertUser(user); err != nil {
+		t.Fatal(err)
+	}
+	var notes []database.Note
+	for _, title := range titles {
+		notes = append(notes, database.Note{Source: "apple-notes", SourceIdentifier: title, Title: title, Body: title,
+			CreatedAt: "2026-01-01T00:00:00Z", UpdatedAt: "2026-01-02T00:00:00Z"})
+	}
+	results, err := db.InsertNotesForUser(user.ID, notes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Default.Database = db
+
+	app := fiber.New()
+	app.Put("/visibility/:id", func(c *fiber.Ctx) error {
+		c.Locals("user", user)
+		return c.Next()
+	}, setNoteVisibility())
+	setFeedsGroup(app.Group("/feeds"))
+	SetSitemapGroup(app)
+	app.Get("/notes/:username/:slug", getNoteSlug())
+	app.Post("/notes/:username/:slug/access", accessNote())
+	return app, results
+}
+
+// testRequest makes a request to app and returns the status and body of the response.
+func testRequest(t *testing.T, app *fiber.App, method, path, body string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func TestSetNoteVisibilityPasswords(t *testing.T) {
+	user := &database.User{Username: "alice", Email: "alice@example.com"}
+	app, results := testVisibilityApp(t, user, "secret")
+	path := "/visibility/" + results[0].ID
+
+	for _, tt := range []struct {
+		name, body string
+		want       int
+	}{
+		{"protected without password", `{"visibility": "protected"}`, fiber.StatusBadRequest},
+		{"password of public note", `{"visibility": "public", "password": "hunter2"}`, fiber.StatusBadRequest},
+		{"password over 72 bytes", `{"visibility": "protected", "password": "` + strings.Repeat("a", 73) + `"}`, fiber.StatusBadRequest},
+		{"password of 72 bytes", `{"visibility": "protected", "password": "` + strings.Repeat("a", 72) + `"}`, fiber.StatusOK},
+		{"protected again without password", `{"visibility": "protected"}`, fiber.StatusOK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, body := testRequest(t, app, fiber.MethodPut, path, tt.body); status != tt.want {
+				t.Errorf("got status %d (%s), want %d", status, body, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtectedNoteAccess(t *testing.T) {
+	user := &database.User{Username: "alice", Email: "alice@example.com"}
+	app, results := testVisibilityApp(t, user, "secret")
+	visibility := "/visibility/" + results[0].ID
+	note := "/notes/alice/secret"
+
+	setPassword := func(password string) {
+		t.Helper()
+		body := `{"visibility": "protected", "password": "` + password + `"}`
+		if status, data := testRequest(t, app, fiber.MethodPut, visibility, body); status != fiber.StatusOK {
+			t.Fatalf("set password got status %d (%s)", status, data)
+		}
+	}
+	access := func(password string) (int, string) {
+		t.Helper()
+		status, data := testRequest(t, app, fiber.MethodPost, note+"/access", `{"password": "`+password+`"}`)
+		var response struct {
+			AccessToken string `json:"access_token"`
+		}
+		json.Unmarshal([]byte(data), &response)
+		return status, response.AccessToken
+	}
+
+	setPassword("hunter2")
+	if status, _ := testRequest(t, app, fiber.MethodGet, note, ""); status != fiber.StatusUnauthorized {
+		t.Errorf("without token got status %d, want %d", status, fiber.StatusUnauthorized)
+	}
+	if status, _ := access("wrong"); status != fiber.StatusUnauthorized {
+		t.Errorf("wrong password got status %d, want %d", status, fiber.StatusUnauthorized)
+	}
+	status, token := access("hunter2")
+	if status != fiber.StatusOK || token == "" {
+		t.Fatalf("right password got status %d and token %q", status, token)
+	}
+	if status, data := testRequest(t, app, fiber.MethodGet, note+"?access_token="+token, ""); status != fiber.StatusOK {
+		t.Errorf("with token got status %d (%s), want %d", status, data, fiber.StatusOK)
+	}
+
+	// a new password revokes the tokens of the old one
+	setPassword("correct horse")
+	if status, _ := testRequest(t, app, fiber.MethodGet, note+"?access_token="+token, ""); status != fiber.StatusUnauthorized {
+		t.Errorf("with token of old password got status %d, want %d", status, fiber.StatusUnauthorized)
+	}
+}
+
+func TestOnlyPublicNotesListed(t *testing.T) {
+	user := &database.User{Username: "alice", Email: "alice@example.com"}
+	app, results := testVisibilityApp(t, user, "public", "unlisted", "protected")
+	for i, body := range []string{
+		`{"visibility": "public"}`,
+		`{"visibility": "unlisted"}`,
+		`{"visibility": "protected", "password": "hunter2"}`,
+	} {
+		if status, data := testRequest(t, app, fiber.MethodPut, "/visibility/"+results[i].ID, body); status != fiber.StatusOK {
+			t.Fatalf("%s got status %d (%s)", body, status, data)
+		}
+	}
+
+	notes, err := env.Default.Database.ListDeployedNotes(user.ID, database.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 1 || notes[0].Slug != "public" {
+		t.Errorf("got %d deployed notes listed, want only the public one", len(notes))
+	}
+
+	for _, path := range []string{"/feeds/alice/rss.xml", "/feeds/alice/atom.xml", "/feeds/alice/feed.json", "/sitemaps/alice.xml"} {
+		status, data := testRequest(t, app, fiber.MethodGet, path, "")
+		if status != fiber.StatusOK {
+			t.Fatalf("%s got status %d (%s)", path, status, data)
+		}
+		if !strings.Contains(data, "webnotes.example/alice/public") {
+			t.Errorf("%s does not have the public note:\n%s", path, data)
+		}
+		for _, slug := range []string{"unlisted", "protected"} {
+			if strings.Contains(data, "/alice/"+slug) {
+				t.Errorf("%s has the %s note:\n%s", path, slug, data)
+			}
+		}
+	}
+
+	if _, data := testRequest(t, app, fiber.MethodGet, "/sitemap.xml", ""); !strings.Contains(data, "/sitemaps/alice.xml") {
+		t.Errorf("sitemap index does not have the user:\n%s", data)
+	}
+
+	// users with no public notes are left out of the sitemap index
+	if status, _ := testRequest(t, app, fiber.MethodPut, "/visibility/"+results[0].ID, `{"visibility": "unlisted"}`); status != fiber.StatusOK {
+		t.Fatalf("unlisting got status %d", status)
+	}
+	if _, data := testRequest(t, app, fiber.MethodGet, "/sitemap.xml", ""); strings.Contains(data, "alice") {
+		t.Errorf("sitemap index has a user without public notes:\n%s", data)
+	}
+}
@@ -0,0 +1,253 @@
+// Package diff compares two versions of a text, line by line as a unified diff or word by word.
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Op is what a segment of a diff does.
+type Op string
+
+const (
+	Equal  Op = "equal"  // the text is in both versions
+	Insert Op = "insert" // the text is only in the new version
+	Delete Op = "delete" // the text is only in the old version
+)
+
+// Segment is a run of text with the same Op in a word diff.
+type Segment struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxEdits bounds the work done to find the smallest diff. Past it, the changed part of the texts
+// is reported as deleted and inserted as a whole.
+const maxEdits = 1000
+
+// Words returns the word diff between a and b. Words and the whitespace between them are compared
+// as separate tokens, and consecutive tokens with the same Op are merged into one segment.
+func Words(a, b string) []Segment {
+	ta, tb := words(a), words(b)
+
+	segments := []Segment{}
+	var i, j int
+	for _, op := range script(ta, tb) {
+		var text string
+		switch op {
+		case Equal:
+			text = ta[i]
+			i++
+			j++
+		case Delete:
+			text = ta[i]
+			i++
+		case Insert:
+			text = tb[j]
+			j++
+		}
+		if n := len(segments); n > 0 && segments[n-1].Op == op {
+			segments[n-1].Text += text
+			continue
+		}
+		segments = append(segments, Segment{Op: op, Text: text})
+	}
+	return segments
+}
+
+// words splits s in runs of whitespace and runs of other characters.
+func words(s string) []string {
+	var tokens []string
+	start, space := 0, false
+	for i, r := range s {
+		isSpace := unicode.IsSpace(r)
+		if i > 0 && isSpace != space {
+			tokens = append(tokens, s[start:i])
+			start = i
+		}
+		space = isSpace
+	}
+	if start < len(s) {
+		tokens = append(tokens, s[start:])
+	}
+	return tokens
+}
+
+// Unified returns the unified diff between a and b, with context lines of context around the
+// changes, or an empty string if they are equal. aName and bName label the two versions.
+func Unified(aName, bName, a, b string, context int) string {
+	la, lb := lines(a), lines(b)
+	ops := script(la, lb)
+
+	// line numbers in a and b before each op
+	ia := make([]int, len(ops)+1)
+	ib := make([]int, len(ops)+1)
+	for k, op := range ops {
+		ia[k+1], ib[k+1] = ia[k], ib[k]
+		if op != Insert {
+			ia[k+1]++
+		}
+		if op != Delete {
+			ib[k+1]++
+		}
+	}
+
+	var out strings.Builder
+	for k := 0; k < len(ops); {
+		if ops[k] == Equal {
+			k++
+			continue
+		}
+
+		// a hunk goes from context lines before a change to context lines after the last change
+		// that is at most 2*context lines away from the next one
+		start := max(k-context, 0)
+		end := k
+		for end < len(ops) {
+			if ops[end] != Equal {
+				end++
+				continue
+			}
+			next := end
+			for next < len(ops) && ops[next] == Equal {
+				next++
+			}
+			if next == len(ops) || next-end > 2*context {
+				end = min(end+context, len(ops))
+				break
+			}
+			end = next
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(ia[start], ia[end]-ia[start]), hunkRange(ib[start], ib[end]-ib[start]))
+		for h := start; h < end; h++ {
+			switch ops[h] {
+			case Equal:
+				writeLine(&out, ' ', la[ia[h]])
+			case Delete:
+				writeLine(&out, '-', la[ia[h]])
+			case Insert:
+				writeLine(&out, '+', lb[ib[h]])
+			}
+		}
+		k = end
+	}
+	return out.String()
+}
+
+// hunkRange formats the start and length of a hunk, with start counted from 0.
+func hunkRange(start, length int) string {
+	switch length {
+	case 0:
+		return fmt.Sprintf("%d,0", start) // the line before an empty range
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+func writeLine(out *strings.Builder, prefix byte, line string) {
+	out.WriteByte(prefix)
+	out.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		out.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// lines splits s after each newline.
+func lines(s string) []string {
+	l := strings.SplitAfter(s, "\n")
+	if l[len(l)-1] == "" {
+		l = l[:len(l)-1]
+	}
+	return l
+}
+
+// script returns the operations turning a into b, one per token of a or b.
+func script[T comparable](a, b []T) []Op {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := slices.Repeat([]Op{Equal}, prefix)
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	return append(ops, slices.Repeat([]Op{Equal}, suffix)...)
+}
+
+// myers returns the shortest edit script turning a into b, with the algorithm from Eugene W.
+// Myers' "An O(ND) Difference Algorithm and Its Variations".
+func myers[T comparable](a, b []T) []Op {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxEdits)
+
+	// v[k+offset] is the furthest x reached on diagonal k = x-y
+	offset := limit + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int // v before each round, for diagonals -d to d
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // down from diagonal k+1: insert
+			} else {
+				x = v[offset+k-1] + 1 // right from diagonal k-1: delete
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, n, m)
+			}
+		}
+	}
+
+	// too different, replace everything
+	return append(slices.Repeat([]Op{Delete}, n), slices.Repeat([]Op{Insert}, m)...)
+}
+
+// backtrack follows the rounds of myers back from (n, m) to (0, 0).
+func backtrack(trace [][]int, n, m int) []Op {
+	var ops []Op
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d] // v[k+d] is diagonal k
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[k-1+d] < v[k+1+d]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[prevK+d]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, Equal)
+			x, y = x-1, y-1
+		}
+		if x == prevX {
+			ops = append(ops, Insert)
+		} else {
+			ops = append(ops, Delete)
+		}
+		x, y = prevX, prevY
+	}
+	for ; x > 0; x-- {
+		ops = append(ops, Equal)
+	}
+	slices.Reverse(ops)
+	return ops
+}
@@ -0,0 +1,114 @@
+package diff
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		a, b    string
+		context int
+		want    string
+	}{
+		{"empty", "", "", 3, ""},
+		{"identical", "a\nb\n", "a\nb\n", 3, ""},
+		{"insert only", "", "x\ny\n", 3, "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+x\n+y\n"},
+		{"delete only", "x\ny\n", "", 3, "--- old\n+++ new\n@@ -1,2 +0,0 @@\n-x\n-y\n"},
+		{
+			"context", "a\nb\nc\nd\ne\n", "a\nb\nC\nd\ne\n", 1,
+			"--- old\n+++ new\n@@ -2,3 +2,3 @@\n b\n-c\n+C\n d\n",
+		},
+		{
+			"separate hunks", "a\nb\nc\nd\ne\nf\ng\nh\n", "a\nB\nc\nd\ne\nf\nh\n", 1,
+			"--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n@@ -6,3 +6,2 @@\n f\n-g\n h\n",
+		},
+		{
+			"close changes share a hunk", "a\nb\nc\nd\ne\n", "A\nb\nc\nD\ne\n", 1,
+			"--- old\n+++ new\n@@ -1,5 +1,5 @@\n-a\n+A\n b\n c\n-d\n+D\n e\n",
+		},
+		{
+			"no newline at end", "x", "y", 3,
+			"--- old\n+++ new\n@@ -1 +1 @@\n-x\n\\ No newline at end of file\n+y\n\\ No newline at end of file\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified("old", "new", tt.a, tt.b, tt.context); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWords(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		a, b string
+		want []Segment
+	}{
+		{"empty", "", "", []Segment{}},
+		{"identical", "the quick fox", "the quick fox", []Segment{{Equal, "the quick fox"}}},
+		{"insert only", "the fox", "the quick fox", []Segment{{Equal, "the "}, {Insert, "quick "}, {Equal, "fox"}}},
+		{"delete only", "the quick fox", "the fox", []Segment{{Equal, "the "}, {Delete, "quick "}, {Equal, "fox"}}},
+		{"replace", "the quick fox", "the slow fox", []Segment{{Equal, "the "}, {Delete, "quick"}, {Insert, "slow"}, {Equal, " fox"}}},
+		{"whitespace", "a b", "a  b", []Segment{{Equal, "a"}, {Delete, " "}, {Insert, "  "}, {Equal, "b"}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Words(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWordsTokens(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		want []string
+	}{
+		{"", nil},
+		{"word", []string{"word"}},
+		{"  hello  world\n", []string{"  ", "hello", "  ", "world", "\n"}},
+		{"héllo wörld", []string{"héllo", " ", "wörld"}},
+	} {
+		if got := words(tt.s); !slices.Equal(got, tt.want) {
+			t.Errorf("words(%q) got %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestScriptMaxEdits(t *testing.T) {
+	count := func(ops []Op, op Op) int {
+		n := 0
+		for _, o := range ops {
+			if o == op {
+				n++
+			}
+		}
+		return n
+	}
+
+	// every other token changed, the last one is kept
+	a := make([]int, 1100)
+	b := make([]int, 1100)
+	for i := range a {
+		a[i], b[i] = i, i
+		if i%2 == 0 {
+			b[i] = -i - 1
+		}
+	}
+
+	// past maxEdits, the changed part is replaced as a whole
+	ops := script(a, b)
+	if got, want := [3]int{count(ops, Equal), count(ops, Delete), count(ops, Insert)}, [3]int{1, 1099, 1099}; got != want {
+		t.Errorf("got %v equal, delete and insert ops, want %v", got, want)
+	}
+
+	// below it, the diff is the smallest one
+	ops = script(a[:400], b[:400])
+	if got, want := [3]int{count(ops, Equal), count(ops, Delete), count(ops, Insert)}, [3]int{200, 200, 200}; got != want {
+		t.Errorf("got %v equal, delete and insert ops, want %v", got, want)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"encoding/hex"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/shashwtd/webnotes/database"
 )
@@ -11,18 +14,75 @@ import (
 type Environment struct {
 	Debug bool // DEBUG
 
+	DatabaseDriver string // DATABASE_DRIVER ("supabase", "postgres" or "memory", defaults to "supabase")
+
+	DatabaseURL         string // DATABASE_URL (postgres connection string, used by the postgres driver and to migrate the supabase database)
+	DatabaseAutoMigrate bool   // DATABASE_AUTO_MIGRATE (apply pending migrations on startup, defaults to true)
+
+	PublicURL string // PUBLIC_URL (public URL of this backend, defaults to http://localhost:8080)
+
+	ServePages bool   // SERVE_PAGES (serve html pages of profiles and deployed notes at /:username and /:username/:slug, defaults to false)
+	PagesURL   string // PAGES_URL (public URL the pages are served at, used in canonical URLs, defaults to PUBLIC_URL)
+
+	DNSResolver string // DNS_RESOLVER (address of the DNS server custom domains are verified with, like 127.0.0.1:53, defaults to the system resolver)
+
+	DeletedNotesGracePeriod time.Duration // DELETED_NOTES_GRACE_PERIOD (time before deleted notes are purged, defaults to 720h)
+
+	BodyLimit      int           // BODY_LIMIT (max size of a request body in bytes, compressed or not, defaults to 32 MiB)
+	UploadLifetime time.Duration // UPLOAD_LIFETIME (time a chunked upload can take before it is purged, defaults to 24h)
+
 	SupabaseURL            string // SUPABASE_URL
 	SupabaseServiceRoleKey string // SUPABASE_SR_KEY
 
 	JWTSigningKey []byte // JWT_SIGNING_KEY (hex encoded)
 
-	Database *database.DB // database from database.Database function (should be set in main.go)
+	Database database.Store // database store chosen by DatabaseDriver (should be set in main.go)
 }
 
 var Default Environment
 
 func Load() error {
 	Default.Debug = os.Getenv("DEBUG") == "true"
+	Default.DatabaseDriver = os.Getenv("DATABASE_DRIVER")
+	if Default.DatabaseDriver == "" {
+		Default.DatabaseDriver = "supabase"
+	}
+	Default.DatabaseURL = os.Getenv("DATABASE_URL")
+	Default.DatabaseAutoMigrate = os.Getenv("DATABASE_AUTO_MIGRATE") != "false"
+	Default.PublicURL = strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+	if Default.PublicURL == "" {
+		Default.PublicURL = "http://localhost:8080"
+	}
+	Default.ServePages = os.Getenv("SERVE_PAGES") == "true"
+	Default.PagesURL = strings.TrimSuffix(os.Getenv("PAGES_URL"), "/")
+	if Default.PagesURL == "" {
+		Default.PagesURL = Default.PublicURL
+	}
+	Default.DNSResolver = os.Getenv("DNS_RESOLVER")
+	Default.DeletedNotesGracePeriod = 30 * 24 * time.Hour
+	if raw := os.Getenv("DELETED_NOTES_GRACE_PERIOD"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("parsing DELETED_NOTES_GRACE_PERIOD: %w", err)
+		}
+		Default.DeletedNotesGracePeriod = d
+	}
+	Default.BodyLimit = 32 << 20
+	if raw := os.Getenv("BODY_LIMIT"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("parsing BODY_LIMIT: invalid size %q", raw)
+		}
+		Default.BodyLimit = n
+	}
+	Default.UploadLifetime = 24 * time.Hour
+	if raw := os.Getenv("UPLOAD_LIFETIME"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("parsing UPLOAD_LIFETIME: %w", err)
+		}
+		Default.UploadLifetime = d
+	}
 	Default.SupabaseURL = os.Getenv("SUPABASE_URL")
 	Default.SupabaseServiceRoleKey = os.Getenv("SUPABASE_SR_KEY")
 
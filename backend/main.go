@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/shashwtd/webnotes/backend/api"
 	"github.com/shashwtd/webnotes/backend/env"
@@ -17,22 +19,28 @@ func main() {
 		slog.Error("failed to load environment variables", "error", err)
 		return
 	}
-	env.Default.Database, err = database.Database(
-		env.Default.SupabaseURL,
-		env.Default.SupabaseServiceRoleKey,
-	)
+	env.Default.Database, err = openDatabase()
 	if err != nil {
 		slog.Error("failed to connect to database", "error", err)
 		return
 	}
 
-	app := fiber.New()
+	go purgeDeletedNotes(time.Hour)
+	go purgeExpiredUploads(time.Hour)
+	go api.RunScheduler()
+
+	app := fiber.New(fiber.Config{
+		BodyLimit: env.Default.BodyLimit,
+	})
 	app.Use(cors.New(cors.Config{
 		AllowOriginsFunc: func(origin string) bool {
 			return true
 		},
 		AllowCredentials: true,
 	}))
+	if env.Default.ServePages {
+		app.Use(api.CustomDomains()) // custom domains show the pages
+	}
 
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusOK).SendString("OK")
@@ -40,9 +48,118 @@ func main() {
 
 	apiGroup := app.Group("/api")
 	api.SetAPIGroup(apiGroup)
+	api.SetSitemapGroup(app)
+	if env.Default.ServePages {
+		api.SetPagesGroup(app)
+	}
 
 	if err := app.Listen(":8080"); err != nil {
 		slog.Error("listen and server error", "error", err)
 		return
 	}
 }
+
+// openDatabase returns the store selected by the DATABASE_DRIVER environment variable.
+func openDatabase() (database.Store, error) {
+	pfpsURL := env.Default.PublicURL + "/api/v1/profile/picture" // served by the profile api group
+
+	switch env.Default.DatabaseDriver {
+	case "supabase":
+		// the supabase database is migrated through its postgres connection string when given
+		if env.Default.DatabaseURL != "" && env.Default.DatabaseAutoMigrate {
+			pg, err := database.Postgres(env.Default.DatabaseURL, pfpsURL)
+			if err != nil {
+				return nil, err
+			}
+			err = migrate(pg)
+			pg.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		db, err := database.Database(
+			env.Default.SupabaseURL,
+			env.Default.SupabaseServiceRoleKey,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkSchemaVersion(db); err != nil {
+			return nil, err
+		}
+		return db, nil
+	case "postgres":
+		db, err := database.Postgres(env.Default.DatabaseURL, pfpsURL)
+		if err != nil {
+			return nil, err
+		}
+		if env.Default.DatabaseAutoMigrate {
+			if err := migrate(db); err != nil {
+				db.Close()
+				return nil, err
+			}
+		}
+		return db, nil
+	case "memory":
+		slog.Warn("using in-memory database, nothing will be persisted")
+		return database.Memory(pfpsURL), nil
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", env.Default.DatabaseDriver)
+	}
+}
+
+// migrate applies the pending migrations to a postgres database.
+func migrate(db *database.PostgresDB) error {
+	applied, err := db.Migrate()
+	if err != nil {
+		return fmt.Errorf("migrate database: %w", err)
+	}
+	slog.Info("database migrated", "applied", applied)
+	return nil
+}
+
+// checkSchemaVersion fails when the migrations were not applied to the supabase database, instead
+// of letting every request that touches a missing table or column fail.
+func checkSchemaVersion(db *database.DB) error {
+	latest, err := database.LatestSchemaVersion()
+	if err != nil {
+		return err
+	}
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return fmt.Errorf("check schema version (were the migrations applied? see the README): %w", err)
+	}
+	if current < latest {
+		return fmt.Errorf("database schema is at version %d, want %d: apply the migrations in database/migrations (see the README)", current, latest)
+	}
+	return nil
+}
+
+// purgeDeletedNotes permanently deletes, every interval, the notes that were deleted in their
+// source for longer than the grace period.
+func purgeDeletedNotes(interval time.Duration) {
+	for ; ; time.Sleep(interval) {
+		purged, err := env.Default.Database.PurgeDeletedNotes(time.Now().Add(-env.Default.DeletedNotesGracePeriod))
+		if err != nil {
+			slog.Error("purge deleted notes", "error", err)
+			continue
+		}
+		if purged > 0 {
+			slog.Info("purged deleted notes", "count", purged)
+		}
+	}
+}
+
+// purgeExpiredUploads deletes, every interval, the chunked uploads that were never committed.
+func purgeExpiredUploads(interval time.Duration) {
+	for ; ; time.Sleep(interval) {
+		purged, err := env.Default.Database.PurgeExpiredUploads(time.Now())
+		if err != nil {
+			slog.Error("purge expired uploads", "error", err)
+			continue
+		}
+		if purged > 0 {
+			slog.Info("purged expired uploads", "count", purged)
+		}
+	}
+}
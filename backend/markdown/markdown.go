@@ -0,0 +1,390 @@
+// Package markdown converts the html of Apple Notes bodies to GitHub flavored markdown: headings,
+// lists and checklists, tables, links and images, bold, italic, strikethrough and monospace.
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shashwtd/webnotes/backend/sanitize"
+)
+
+// Convert returns the markdown of an html body. The body is sanitized first, so only what readers
+// would see is converted.
+func Convert(body string) string {
+	md := strings.Join(blocks(parse(sanitize.HTML(body)).children), "\n\n")
+	if md == "" {
+		return ""
+	}
+	return md + "\n"
+}
+
+// node is an element or, if name is empty, a text node of sanitized html.
+type node struct {
+	name     string
+	attrs    map[string]string
+	text     string // unescaped
+	children []*node
+}
+
+var attrRegexp = regexp.MustCompile(`([a-z-]+)="([^"]*)"`)
+
+// parse builds the tree of sanitized html: tags are balanced, attributes are double quoted and
+// text is escaped.
+func parse(s string) *node {
+	root := &node{name: "#root"}
+	stack := []*node{root}
+	for len(s) > 0 {
+		parent := stack[len(stack)-1]
+		i := strings.IndexByte(s, '<')
+		if i < 0 {
+			i = len(s)
+		}
+		if i > 0 {
+			parent.children = append(parent.children, &node{text: html.UnescapeString(s[:i])})
+			s = s[i:]
+			continue
+		}
+
+		end := strings.IndexByte(s, '>')
+		tag := s[1:end]
+		s = s[end+1:]
+		if strings.HasPrefix(tag, "/") {
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+
+		name, attrs, _ := strings.Cut(tag, " ")
+		n := &node{name: name, attrs: make(map[string]string)}
+		for _, m := range attrRegexp.FindAllStringSubmatch(attrs, -1) {
+			n.attrs[m[1]] = html.UnescapeString(m[2])
+		}
+		parent.children = append(parent.children, n)
+		if name != "br" && name != "hr" && name != "img" {
+			stack = append(stack, n)
+		}
+	}
+	return root
+}
+
+// isBlock reports whether n is a block, rendered on lines of its own.
+func isBlock(n *node) bool {
+	switch n.name {
+	case "blockquote", "div", "h1", "h2", "h3", "h4", "h5", "h6", "hr", "li", "ol", "p", "pre", "table", "ul":
+		return true
+	}
+	return false
+}
+
+// blocks renders nodes as blocks, one per block node and one per run of inline nodes between
+// them. Apple Notes puts every line in a <div>, so every line becomes a paragraph.
+func blocks(nodes []*node) []string {
+	var parts []string
+	var run []*node
+	flush := func() {
+		if s := paragraph(run); s != "" {
+			parts = append(parts, s)
+		}
+		run = nil
+	}
+	for _, n := range nodes {
+		if !isBlock(n) {
+			run = append(run, n)
+			continue
+		}
+		flush()
+		if s := block(n); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	flush()
+	return parts
+}
+
+// paragraph renders a run of inline nodes, without the line breaks at its ends, like the <br> of
+// the empty lines of Apple Notes.
+func paragraph(run []*node) string {
+	isBreak := func(n *node) bool { return n.name == "br" || n.name == "" && strings.TrimSpace(n.text) == "" }
+	for len(run) > 0 && isBreak(run[0]) {
+		run = run[1:]
+	}
+	for len(run) > 0 && isBreak(run[len(run)-1]) {
+		run = run[:len(run)-1]
+	}
+
+	lines := strings.Split(strings.TrimSpace(inline(run)), "\n")
+	for i, line := range lines {
+		lines[i] = escapeLineStart(strings.TrimLeft(line, " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func block(n *node) string {
+	switch n.name {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level, _ := strconv.Atoi(n.name[1:])
+		text := strings.TrimSpace(oneLine(inline(n.children)))
+		if text == "" {
+			return ""
+		}
+		return strings.Repeat("#", level) + " " + text
+	case "ul", "ol":
+		return list(n)
+	case "table":
+		return table(n)
+	case "pre":
+		return codeBlock(textOf(n))
+	case "blockquote":
+		return indent(strings.Join(blocks(n.children), "\n\n"), "> ", "> ")
+	case "hr":
+		return "---"
+	}
+	return strings.Join(blocks(n.children), "\n\n") // div, p, and li outside of a list
+}
+
+// list renders a list. Nested lists are indented under the item before them, and checklists (a
+// <ul class="checklist">, with <li class="checked"> for the checked items) become task lists.
+func list(n *node) string {
+	number, _ := strconv.Atoi(n.attrs["start"])
+	number = max(number, 1)
+	checklist := hasClass(n, "checklist")
+
+	var items []string
+	for _, child := range n.children {
+		switch {
+		case child.name == "ul" || child.name == "ol":
+			if s := list(child); s != "" && len(items) > 0 {
+				items[len(items)-1] += "\n" + indent(s, "  ", "  ")
+			} else if s != "" {
+				items = append(items, s)
+			}
+			continue
+		case child.name != "li":
+			continue
+		}
+
+		marker, task := "- ", ""
+		switch {
+		case n.name == "ol":
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		case checklist && hasClass(child, "checked"):
+			task = "[x] "
+		case checklist:
+			task = "[ ] "
+		}
+		// continuation lines line up with the content of the item, which starts after the marker
+		content := strings.Join(blocks(child.children), "\n")
+		items = append(items, indent(content, marker+task, strings.Repeat(" ", len(marker))))
+	}
+	return strings.Join(items, "\n")
+}
+
+// table renders a table, with its first row as the header.
+func table(n *node) string {
+	var rows [][]string
+	var walk func(n *node)
+	walk = func(n *node) {
+		for _, child := range n.children {
+			switch child.name {
+			case "tr":
+				var row []string
+				for _, cell := range child.children {
+					if cell.name == "td" || cell.name == "th" {
+						text := strings.Join(blocks(cell.children), " ")
+						row = append(row, strings.ReplaceAll(oneLine(text), "|", `\|`))
+					}
+				}
+				rows = append(rows, row)
+			case "thead", "tbody", "tfoot":
+				walk(child)
+			}
+		}
+	}
+	walk(n)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	var b strings.Builder
+	writeRow := func(row []string) {
+		b.WriteString("|")
+		for i := range columns {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			b.WriteString(" " + cell + " |")
+		}
+		b.WriteString("\n")
+	}
+	writeRow(rows[0])
+	writeRow(strings.Split(strings.Repeat("---,", columns-1)+"---", ","))
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// codeBlock renders a fenced code block, with a fence that does not appear in the code.
+func codeBlock(code string) string {
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + "\n" + strings.Trim(code, "\n") + "\n" + fence
+}
+
+var spaceRegexp = regexp.MustCompile(`[ \t\r\n\f]+`)
+
+// inline renders inline nodes. Whitespace is collapsed like browsers do, and <br> is a hard line
+// break.
+func inline(nodes []*node) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		switch n.name {
+		case "":
+			b.WriteString(escape(spaceRegexp.ReplaceAllString(n.text, " ")))
+		case "br":
+			b.WriteString("\\\n")
+		case "b", "strong":
+			b.WriteString(wrap("**", inline(n.children)))
+		case "i", "em":
+			b.WriteString(wrap("*", inline(n.children)))
+		case "s", "strike", "del":
+			b.WriteString(wrap("~~", inline(n.children)))
+		case "code", "tt":
+			b.WriteString(code(textOf(n)))
+		case "a":
+			text := strings.TrimSpace(inline(n.children))
+			href := n.attrs["href"]
+			switch {
+			case href == "":
+				b.WriteString(text)
+			case text == escape(href):
+				b.WriteString("<" + href + ">")
+			default:
+				b.WriteString("[" + text + "](" + escapeURL(href) + ")")
+			}
+		case "img":
+			if src := n.attrs["src"]; src != "" {
+				b.WriteString("![" + escape(n.attrs["alt"]) + "](" + escapeURL(src) + ")")
+			}
+		default: // span, u, mark, sub, sup, and blocks nested in inline elements
+			b.WriteString(inline(n.children))
+		}
+	}
+	return b.String()
+}
+
+// wrap puts delim around s, keeping the spaces at its ends outside, as markdown needs.
+func wrap(delim, s string) string {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return s
+	}
+	start := s[:strings.Index(s, trimmed)]
+	end := s[len(start)+len(trimmed):]
+	return start + delim + trimmed + delim + end
+}
+
+// code renders inline code, with enough backticks around it.
+func code(s string) string {
+	s = oneLine(s)
+	if strings.TrimSpace(s) == "" {
+		return s
+	}
+	fence := "`"
+	for strings.Contains(s, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return fence + s + fence
+}
+
+// textOf returns the text of n and its descendants, with <br> as new lines.
+func textOf(n *node) string {
+	if n.name == "" {
+		return n.text
+	}
+	if n.name == "br" {
+		return "\n"
+	}
+	var b strings.Builder
+	for _, child := range n.children {
+		b.WriteString(textOf(child))
+		if isBlock(child) && n.name == "pre" {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// escaper escapes the characters that are markdown syntax anywhere in a line.
+var escaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "~", `\~`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// lineStartRegexp matches the beginnings of lines that would be read as a heading, a bullet list
+// item, or a setext heading underline. Quotes are escaped with the other > already.
+var lineStartRegexp = regexp.MustCompile(`^(#{1,6}(\s|$)|[-+](\s|$)|=+\s*$|-+\s*$)`)
+
+// orderedRegexp matches the beginnings of lines that would be read as an ordered list item.
+var orderedRegexp = regexp.MustCompile(`^(\d{1,9})([.)](\s|$))`)
+
+func escapeLineStart(line string) string {
+	if lineStartRegexp.MatchString(line) {
+		return `\` + line
+	}
+	return orderedRegexp.ReplaceAllString(line, `$1\$2`)
+}
+
+func escapeURL(u string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(u)
+}
+
+// oneLine joins the lines of s with spaces, for headings and table cells.
+func oneLine(s string) string {
+	s = strings.ReplaceAll(s, "\\\n", " ")
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// indent prefixes the first line of s with first and the others with rest. Empty lines are only
+// given the non-space part of rest, like the > of quotes.
+func indent(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		switch {
+		case i == 0:
+			lines[i] = first + line
+		case line == "":
+			lines[i] = strings.TrimRight(rest, " ")
+		default:
+			lines[i] = rest + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func hasClass(n *node, class string) bool {
+	for _, c := range strings.Fields(n.attrs["class"]) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
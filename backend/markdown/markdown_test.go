@@ -0,0 +1,55 @@
+package markdown
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden markdown files of testdata")
+
+// TestConvert converts every testdata/*.html body and compares the result with the markdown of
+// the .md file of the same name.
+func TestConvert(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no testdata")
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".html")
+		t.Run(name, func(t *testing.T) {
+			body, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := Convert(string(body))
+
+			golden := strings.TrimSuffix(file, ".html") + ".md"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("Convert(%s)\n got:\n%s\nwant:\n%s", file, got, want)
+			}
+		})
+	}
+}
+
+func TestConvertEmpty(t *testing.T) {
+	for _, body := range []string{"", "<div><br></div>", "<script>alert(1)</script>"} {
+		if got := Convert(body); got != "" {
+			t.Errorf("Convert(%q) = %q, want nothing", body, got)
+		}
+	}
+}
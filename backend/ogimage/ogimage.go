@@ -0,0 +1,216 @@
+// Package ogimage renders the Open Graph preview images of notes: cards with the title, an
+// excerpt and the author of a note, shown when a link to it is shared.
+package ogimage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Size of the images, the one Open Graph consumers expect.
+const (
+	Width  = 1200
+	Height = 630
+)
+
+const (
+	margin     = 80
+	avatarSize = 96
+)
+
+var (
+	background = color.RGBA{0xfb, 0xfb, 0xfa, 0xff}
+	accent     = color.RGBA{0xb8, 0x86, 0x0b, 0xff}
+	dark       = color.RGBA{0x1f, 0x1f, 0x1f, 0xff}
+	muted      = color.RGBA{0x6b, 0x6b, 0x6b, 0xff}
+)
+
+// Card is what an image shows.
+type Card struct {
+	Title    string
+	Excerpt  string
+	Author   string      // name of the author
+	Username string      // shown under the name
+	Avatar   image.Image // profile picture of the author, or nil for their initial
+}
+
+// faces are not safe for concurrent use, images are rendered one at a time
+var (
+	mu          sync.Mutex
+	titleFace   = newFace(gobold.TTF, 60)
+	authorFace  = newFace(gobold.TTF, 34)
+	handleFace  = newFace(goregular.TTF, 28)
+	excerptFace = newFace(goregular.TTF, 32)
+	initialFace = newFace(gobold.TTF, 48)
+)
+
+func newFace(ttf []byte, size float64) font.Face {
+	f, err := opentype.Parse(ttf)
+	if err != nil {
+		panic(fmt.Sprintf("parse font: %v", err))
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		panic(fmt.Sprintf("new font face: %v", err))
+	}
+	return face
+}
+
+// Render returns the PNG image of a card.
+func Render(card Card) ([]byte, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, 16, Height), image.NewUniform(accent), image.Point{}, draw.Src)
+
+	// author
+	drawAvatar(img, card)
+	textX := margin + avatarSize + 28
+	drawText(img, authorFace, dark, textX, margin+42, fit(authorFace, card.Author, Width-margin-textX))
+	if card.Username != "" {
+		drawText(img, handleFace, muted, textX, margin+84, fit(handleFace, "@"+card.Username, Width-margin-textX))
+	}
+
+	// title and excerpt
+	y := margin + avatarSize + 110
+	title := card.Title
+	if strings.TrimSpace(title) == "" {
+		title = "Untitled"
+	}
+	for _, line := range wrap(titleFace, title, Width-2*margin, 3) {
+		drawText(img, titleFace, dark, margin, y, line)
+		y += 72
+	}
+	y += 16
+	for _, line := range wrap(excerptFace, card.Excerpt, Width-2*margin, max(0, (Height-margin-y)/44+1)) {
+		drawText(img, excerptFace, muted, margin, y, line)
+		y += 44
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("encode png: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// drawAvatar draws the profile picture of the author in a circle, or their initial if there is
+// none.
+func drawAvatar(img *image.RGBA, card Card) {
+	r := image.Rect(margin, margin, margin+avatarSize, margin+avatarSize)
+	mask := circle{r}
+	if card.Avatar == nil {
+		draw.DrawMask(img, r, image.NewUniform(accent), image.Point{}, mask, r.Min, draw.Over)
+		initial, _ := utf8.DecodeRuneInString(strings.TrimSpace(card.Author))
+		if initial == utf8.RuneError {
+			return
+		}
+		s := string(unicode.ToUpper(initial))
+		w := font.MeasureString(initialFace, s).Round()
+		drawText(img, initialFace, background, r.Min.X+(avatarSize-w)/2, r.Min.Y+avatarSize/2+17, s)
+		return
+	}
+
+	// crop the middle square and scale it down
+	b := card.Avatar.Bounds()
+	side := min(b.Dx(), b.Dy())
+	src := image.Rect(0, 0, side, side).Add(b.Min).Add(image.Pt((b.Dx()-side)/2, (b.Dy()-side)/2))
+	scaled := image.NewRGBA(image.Rect(0, 0, avatarSize, avatarSize))
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), card.Avatar, src, draw.Src, nil)
+	draw.DrawMask(img, r, scaled, image.Point{}, mask, r.Min, draw.Over)
+}
+
+// circle is the mask of the circle inscribed in a square.
+type circle struct {
+	r image.Rectangle
+}
+
+func (c circle) ColorModel() color.Model { return color.AlphaModel }
+func (c circle) Bounds() image.Rectangle { return c.r }
+
+func (c circle) At(x, y int) color.Color {
+	radius := float64(c.r.Dx()) / 2
+	dx := float64(x-c.r.Min.X) + 0.5 - radius
+	dy := float64(y-c.r.Min.Y) + 0.5 - radius
+	if dx*dx+dy*dy <= radius*radius {
+		return color.Alpha{A: 0xff}
+	}
+	return color.Alpha{}
+}
+
+func drawText(img *image.RGBA, face font.Face, c color.Color, x, y int, s string) {
+	d := font.Drawer{Dst: img, Src: image.NewUniform(c), Face: face, Dot: fixed.P(x, y)}
+	d.DrawString(s)
+}
+
+// wrap breaks s into at most maxLines lines no wider than width. Words wider than a line are
+// broken anywhere, and text that does not fit ends with an ellipsis.
+func wrap(face font.Face, s string, width, maxLines int) []string {
+	if maxLines <= 0 {
+		return nil
+	}
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		for word != "" {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			switch {
+			case font.MeasureString(face, candidate).Round() <= width:
+				line, word = candidate, ""
+			case line != "":
+				lines = append(lines, line)
+				line = ""
+			default: // the word alone is wider than a line
+				runes := []rune(word)
+				n := 1
+				for n < len(runes) && font.MeasureString(face, string(runes[:n+1])).Round() <= width {
+					n++
+				}
+				lines = append(lines, string(runes[:n]))
+				word = string(runes[n:])
+			}
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		lines[maxLines-1] = fit(face, lines[maxLines-1]+"…", width)
+	}
+	return lines
+}
+
+// fit returns s, or its start with an ellipsis if it is wider than width.
+func fit(face font.Face, s string, width int) string {
+	if font.MeasureString(face, s).Round() <= width {
+		return s
+	}
+	runes := []rune(s)
+	for n := len(runes) - 1; n > 0; n-- {
+		cut := strings.TrimRight(string(runes[:n]), " ") + "…"
+		if font.MeasureString(face, cut).Round() <= width {
+			return cut
+		}
+	}
+	return ""
+}
@@ -0,0 +1,346 @@
+// Package sanitize cleans the html of note bodies before it is shown to readers. It keeps the
+// elements and attributes Apple Notes produces and drops everything that can run script or load
+// active content.
+package sanitize
+
+import (
+	"html"
+	"slices"
+	"strings"
+)
+
+// elements are the allowed elements, with their allowed attributes besides the global ones.
+var elements = map[string][]string{
+	"a": {"href", "title"}, "b": nil, "blockquote": nil, "br": nil, "code": nil, "del": nil,
+	"div": nil, "em": nil, "h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+	"hr": nil, "i": nil, "img": {"src", "alt", "width", "height"}, "li": {"class"}, "mark": nil, "ol": {"start", "type"},
+	"p": nil, "pre": nil, "s": nil, "span": nil, "strike": nil, "strong": nil, "sub": nil, "sup": nil,
+	"table": nil, "tbody": nil, "td": {"colspan", "rowspan"}, "tfoot": nil, "th": {"colspan", "rowspan"},
+	"thead": nil, "tr": nil, "tt": nil, "u": nil, "ul": {"class"},
+}
+
+// classes are the class names kept in class attributes, the ones of checklists and their checked
+// items.
+var classes = []string{"checklist", "checked"}
+
+// globalAttributes are allowed on every allowed element.
+var globalAttributes = []string{"dir", "style"}
+
+// voidElements have no content and no end tag.
+var voidElements = map[string]bool{"br": true, "hr": true, "img": true}
+
+// impliedEnd are the elements closed by the start tag of another one of the same kind, like the
+// <li> of a list that has no </li>.
+var impliedEnd = map[string]bool{"li": true, "p": true, "td": true, "th": true, "tr": true}
+
+// droppedElements are removed with their content. Other elements that are not allowed, like the
+// <object> Apple Notes wraps tables in, are removed but their content is kept.
+var droppedElements = map[string]bool{
+	"applet": true, "embed": true, "frame": true, "frameset": true, "head": true, "iframe": true,
+	"math": true, "noembed": true, "noframes": true, "noscript": true, "plaintext": true,
+	"script": true, "select": true, "style": true, "svg": true, "template": true, "textarea": true,
+	"title": true, "xmp": true,
+}
+
+// styleProperties are the css properties kept in style attributes.
+var styleProperties = []string{"background-color", "color", "font-family", "font-size", "font-style",
+	"font-weight", "text-align", "text-decoration", "vertical-align"}
+
+// HTML returns body with only the allowed elements, attributes, link and image urls and css
+// properties. Text is re-escaped and elements left open are closed, so the result can be embedded
+// in a page without changing the markup around it.
+func HTML(body string) string {
+	var out strings.Builder
+	var open []string // allowed elements not closed yet
+
+	for len(body) > 0 {
+		i := strings.IndexByte(body, '<')
+		if i < 0 {
+			writeText(&out, body)
+			break
+		}
+		writeText(&out, body[:i])
+		body = body[i:]
+
+		switch {
+		case strings.HasPrefix(body, "<!--"):
+			end := strings.Index(body[4:], "-->")
+			if end < 0 {
+				return closeAll(&out, open)
+			}
+			body = body[4+end+3:]
+
+		case len(body) > 1 && (body[1] == '!' || body[1] == '?'): // doctype, cdata, processing instruction
+			end := strings.IndexByte(body, '>')
+			if end < 0 {
+				return closeAll(&out, open)
+			}
+			body = body[end+1:]
+
+		case len(body) > 2 && body[1] == '/' && isLetter(body[2]):
+			t, rest, ok := parseTag(body[2:])
+			if !ok {
+				writeText(&out, body)
+				return closeAll(&out, open)
+			}
+			body = rest
+			// close the element and the ones left open inside it, ignore stray end tags
+			if j := lastIndex(open, t.name); j >= 0 {
+				for _, name := range slices.Backward(open[j:]) {
+					out.WriteString("</" + name + ">")
+				}
+				open = open[:j]
+			}
+
+		case len(body) > 1 && isLetter(body[1]):
+			t, rest, ok := parseTag(body[1:])
+			if !ok {
+				writeText(&out, body)
+				return closeAll(&out, open)
+			}
+			body = rest
+			if droppedElements[t.name] {
+				body = skipContent(body, t.name)
+				continue
+			}
+			if _, allowed := elements[t.name]; !allowed {
+				continue
+			}
+			if n := len(open); n > 0 && impliedEnd[t.name] && open[n-1] == t.name {
+				out.WriteString("</" + t.name + ">")
+				open = open[:n-1]
+			}
+			writeStartTag(&out, t)
+			if !voidElements[t.name] && !t.selfClosing {
+				open = append(open, t.name)
+			}
+
+		default: // a < that does not start a tag
+			out.WriteString("&lt;")
+			body = body[1:]
+		}
+	}
+	return closeAll(&out, open)
+}
+
+// tag is a parsed start or end tag.
+type tag struct {
+	name        string // lower case
+	attrs       [][2]string
+	selfClosing bool
+}
+
+// parseTag parses a tag from s, which starts at the tag name, and returns what follows it. It
+// returns false if the tag is not terminated.
+func parseTag(s string) (tag, string, bool) {
+	var t tag
+	i := 0
+	for i < len(s) && !isSpace(s[i]) && s[i] != '>' && s[i] != '/' {
+		i++
+	}
+	t.name = strings.ToLower(s[:i])
+
+	for {
+		for i < len(s) && (isSpace(s[i]) || s[i] == '/') {
+			t.selfClosing = s[i] == '/'
+			i++
+		}
+		if i >= len(s) {
+			return t, "", false
+		}
+		if s[i] == '>' {
+			return t, s[i+1:], true
+		}
+		t.selfClosing = false
+
+		start := i
+		for i < len(s) && !isSpace(s[i]) && s[i] != '>' && s[i] != '/' && s[i] != '=' {
+			i++
+		}
+		name := strings.ToLower(s[start:i])
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) || s[i] != '=' {
+			t.attrs = append(t.attrs, [2]string{name, ""})
+			continue
+		}
+		i++ // =
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			return t, "", false
+		}
+
+		var value string
+		if q := s[i]; q == '"' || q == '\'' {
+			end := strings.IndexByte(s[i+1:], q)
+			if end < 0 {
+				return t, "", false
+			}
+			value = s[i+1 : i+1+end]
+			i += end + 2
+		} else {
+			start := i
+			for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+				i++
+			}
+			value = s[start:i]
+		}
+		t.attrs = append(t.attrs, [2]string{name, html.UnescapeString(value)})
+	}
+}
+
+// skipContent returns s after the end tag of the element name, or nothing if it is not closed.
+func skipContent(s, name string) string {
+	lower := strings.ToLower(s)
+	for i := 0; ; {
+		j := strings.Index(lower[i:], "</"+name)
+		if j < 0 {
+			return ""
+		}
+		i += j + 2 + len(name)
+		if i == len(s) || isSpace(s[i]) || s[i] == '>' || s[i] == '/' {
+			end := strings.IndexByte(s[i:], '>')
+			if end < 0 {
+				return ""
+			}
+			return s[i+end+1:]
+		}
+	}
+}
+
+// writeStartTag writes an allowed start tag with its allowed attributes.
+func writeStartTag(out *strings.Builder, t tag) {
+	out.WriteString("<" + t.name)
+	seen := make(map[string]bool)
+	for _, attr := range t.attrs {
+		name, value := attr[0], attr[1]
+		if seen[name] || !(slices.Contains(elements[t.name], name) || slices.Contains(globalAttributes, name)) {
+			continue
+		}
+		seen[name] = true
+
+		switch name {
+		case "href":
+			if !safeURL(value, false) {
+				continue
+			}
+		case "src":
+			if !safeURL(value, true) {
+				continue
+			}
+		case "style":
+			if value = safeStyle(value); value == "" {
+				continue
+			}
+		case "class":
+			kept := slices.DeleteFunc(strings.Fields(value), func(c string) bool { return !slices.Contains(classes, c) })
+			if value = strings.Join(kept, " "); value == "" {
+				continue
+			}
+		}
+		out.WriteString(" " + name + `="` + html.EscapeString(value) + `"`)
+	}
+	if t.name == "a" {
+		out.WriteString(` rel="nofollow noopener noreferrer"`)
+	}
+	out.WriteString(">")
+}
+
+// safeURL reports whether u is a relative url or an absolute http, https or mailto one. Images
+// may also be embedded as data urls of raster images, like Apple Notes does.
+func safeURL(u string, image bool) bool {
+	// browsers ignore control characters and whitespace in the scheme
+	u = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, u)
+	scheme, _, ok := strings.Cut(u, ":")
+	if !ok || strings.ContainsAny(scheme, "/?#") {
+		return true // relative
+	}
+
+	switch strings.ToLower(scheme) {
+	case "http", "https":
+		return true
+	case "mailto":
+		return !image
+	case "data":
+		if !image {
+			return false
+		}
+		lower := strings.ToLower(u)
+		for _, prefix := range []string{"data:image/png;", "data:image/jpeg;", "data:image/gif;", "data:image/webp;"} {
+			if strings.HasPrefix(lower, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// safeStyle returns the declarations of a style attribute that set an allowed property to a value
+// that cannot load anything or run script.
+func safeStyle(style string) string {
+	var kept []string
+	for _, decl := range strings.Split(style, ";") {
+		prop, value, ok := strings.Cut(decl, ":")
+		if !ok {
+			continue
+		}
+		prop = strings.ToLower(strings.TrimSpace(prop))
+		value = strings.TrimSpace(value)
+		lower := strings.ToLower(value)
+		if !slices.Contains(styleProperties, prop) || value == "" ||
+			strings.ContainsAny(value, `\<>`) || strings.Contains(lower, "(") && !isColorFunction(lower) ||
+			strings.Contains(lower, "/*") || strings.Contains(lower, "expression") {
+			continue
+		}
+		kept = append(kept, prop+": "+value)
+	}
+	return strings.Join(kept, "; ")
+}
+
+// isColorFunction reports whether a css value is an rgb or hsl color, the only functions kept.
+func isColorFunction(value string) bool {
+	for _, fn := range []string{"rgb(", "rgba(", "hsl(", "hsla("} {
+		if strings.HasPrefix(value, fn) && strings.Count(value, "(") == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// writeText writes text escaped, so that stray < and > or broken entities cannot form markup.
+func writeText(out *strings.Builder, text string) {
+	out.WriteString(html.EscapeString(html.UnescapeString(text)))
+}
+
+// closeAll closes the elements left open and returns the output.
+func closeAll(out *strings.Builder, open []string) string {
+	for _, name := range slices.Backward(open) {
+		out.WriteString("</" + name + ">")
+	}
+	return out.String()
+}
+
+func lastIndex(s []string, v string) int {
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] == v {
+			return i
+		}
+	}
+	return -1
+}
+
+func isLetter(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
@@ -0,0 +1,104 @@
+package sanitize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTMLDropsActiveContent(t *testing.T) {
+	for _, tt := range []struct {
+		name, in, want string
+	}{
+		{"entity encoded javascript url", `<a href="&#106;avascript:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"hex entities and named colon", `<a href="&#x6A;&#x61;vascript&colon;alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"tab in scheme", `<a href="java&#09;script:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"mixed case scheme", `<a href=" JaVaScRiPt:alert(1)">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"entity encoded data link", `<a href="&#100;ata:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">x</a>`, `<a rel="nofollow noopener noreferrer">x</a>`},
+		{"html data image", `<img src="data:text/html;base64,PHNjcmlwdD4=">`, `<img>`},
+		{"entity encoded svg data image", `<img src="&#100;ata:image/svg+xml;base64,PHN2Zz4=">`, `<img>`},
+		{"image event handler", `<img src="x" onerror="alert(1)">`, `<img src="x">`},
+		{"event handlers", `<div onclick="alert(1)" ONMOUSEOVER=alert(1)>hi</div>`, `<div>hi</div>`},
+		{"svg script", `<svg><script>alert(1)</script></svg>after`, `after`},
+		{"unterminated svg", `<svg/onload=alert(1)>after`, ``},
+		{"math link", `<math><mi xlink:href="javascript:alert(1)">x</mi></math>after`, `after`},
+		{"css url", `<div style="background-image: url(javascript:alert(1)); color: red">x</div>`, `<div style="color: red">x</div>`},
+		{"entity encoded css url", `<div style="color: u&#114;l(https://evil.example/x)">x</div>`, `<div>x</div>`},
+		{"css expression", `<div style="font-family: &quot;a&quot;; background-color: expression(alert(1))">x</div>`, `<div style="font-family: &#34;a&#34;">x</div>`},
+		{"nested script tags", `<scr<script>ipt>alert(1)</script>`, `ipt&gt;alert(1)`},
+		{"link target and rel", `<a href="https://example.com" target="_blank" rel="opener">x</a>`, `<a href="https://example.com" rel="nofollow noopener noreferrer">x</a>`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTML(tt.in); got != tt.want {
+				t.Errorf("HTML(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTMLKeepsAppleNotesBodies(t *testing.T) {
+	for _, tt := range []struct {
+		name, in, want string // want is in if empty
+	}{
+		{
+			name: "formatted text",
+			in: `<div><h1>Trip to Lisbon</h1></div>
+<div><b>Bold</b>, <i>italic</i>, <u>underlined</u> and <strike>struck</strike> text</div>
+<div><span style="font-size: 24px; color: rgb(255, 0, 0)">Big and red</span></div>
+<div><tt>monospace</tt></div>
+<div><br></div>`,
+		},
+		{
+			name: "lists and checklists",
+			in: `<ul class="checklist">
+<li class="checked">Passport</li>
+<li>Charger</li>
+</ul>
+<ol start="3">
+<li>Third</li>
+<li>Fourth<ul>
+<li>Nested</li>
+</ul>
+</li>
+</ol>`,
+		},
+		{
+			name: "embedded image",
+			in:   `<div><img src="data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mP8z8BQDwAEhQGAhKmMIQAAAABJRU5ErkJggg==" width="320" height="240"></div>`,
+		},
+		{
+			name: "link",
+			in:   `<div><a href="https://www.visitlisboa.com/">Visit Lisboa</a> or <a href="mailto:me@example.com">mail me</a></div>`,
+			want: `<div><a href="https://www.visitlisboa.com/" rel="nofollow noopener noreferrer">Visit Lisboa</a> or <a href="mailto:me@example.com" rel="nofollow noopener noreferrer">mail me</a></div>`,
+		},
+		{
+			name: "table wrapped in an object",
+			in:   `<div><object><table cellspacing="0" cellpadding="0" style="border-collapse: collapse; direction: ltr"><tbody><tr><td valign="top" style="border-style: solid; border-width: 1.0px"><div>A</div></td><td><div>B &amp; C</div></td></tr></tbody></table></object><br></div>`,
+			want: `<div><table><tbody><tr><td><div>A</div></td><td><div>B &amp; C</div></td></tr></tbody></table><br></div>`,
+		},
+		{
+			name: "document wrapper",
+			in:   `<!DOCTYPE html><html><head><meta charset="utf-8"><style>body { color: red }</style></head><body><div>Hello &lt;world&gt;</div></body></html>`,
+			want: `<div>Hello &lt;world&gt;</div>`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			if want == "" {
+				want = tt.in
+			}
+			if got := HTML(tt.in); got != want {
+				t.Errorf("HTML(%q)\n got %q\nwant %q", tt.in, got, want)
+			}
+		})
+	}
+}
+
+func TestHTMLClosesOpenElements(t *testing.T) {
+	got := HTML(`<div><b>bold <i>and italic`)
+	if want := `<div><b>bold <i>and italic</i></b></div>`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := HTML(`<div>a</div></div></b>b`); strings.Count(got, "</div>") != 1 {
+		t.Errorf("got %q, want stray end tags dropped", got)
+	}
+}
@@ -0,0 +1,62 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shashwtd/webnotes/backend/env"
+)
+
+// NoteAccessClaims are the claims of a token giving access to a password protected note.
+type NoteAccessClaims struct {
+	NoteID   string `json:"note_id"`
+	Password string `json:"password"` // fingerprint of the password hash, so that new passwords revoke the tokens
+	jwt.RegisteredClaims
+}
+
+// passwordFingerprint returns a short hash of the password hash of a note.
+func passwordFingerprint(passwordHash string) string {
+	sum := sha256.Sum256([]byte(passwordHash))
+	return hex.EncodeToString(sum[:8])
+}
+
+// NewNoteAccessToken creates a JWT giving access to a password protected note until it expires or
+// the password of the note changes.
+func NewNoteAccessToken(noteID, passwordHash string, validityDuration time.Duration) (string, time.Time, error) {
+	expiresAt := time.Now().Add(validityDuration)
+	claims := &NoteAccessClaims{
+		NoteID:   noteID,
+		Password: passwordFingerprint(passwordHash),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "webnotes",
+			Subject:   "note_access",
+			Audience:  []string{"webnotes_reader"},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signedToken, err := token.SignedString(env.Default.JWTSigningKey)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("signing JWT token: %w", err)
+	}
+	return signedToken, expiresAt, nil
+}
+
+// ValidNoteAccessToken reports whether token gives access to a note with the given password hash.
+func ValidNoteAccessToken(token, noteID, passwordHash string) bool {
+	if token == "" {
+		return false
+	}
+	claims := &NoteAccessClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (any, error) {
+		return env.Default.JWTSigningKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithSubject("note_access"), jwt.WithAudience("webnotes_reader"))
+	if err != nil || !parsed.Valid {
+		return false
+	}
+	return claims.NoteID == noteID && claims.Password == passwordFingerprint(passwordHash)
+}
@@ -0,0 +1,47 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shashwtd/webnotes/backend/env"
+)
+
+func TestNoteAccessToken(t *testing.T) {
+	env.Default.JWTSigningKey = []byte("test signing key")
+	token, expiresAt, err := NewNoteAccessToken("note-1", "hash-1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(expiresAt); d <= 59*time.Minute || d > time.Hour {
+		t.Errorf("got token expiring in %s, want an hour", d)
+	}
+	expired, _, err := NewNoteAccessToken("note-1", "hash-1", -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name                string
+		token, noteID, hash string
+		want                bool
+	}{
+		{"valid", token, "note-1", "hash-1", true},
+		{"empty", "", "note-1", "hash-1", false},
+		{"garbage", "not a token", "note-1", "hash-1", false},
+		{"expired", expired, "note-1", "hash-1", false},
+		{"another note", token, "note-2", "hash-1", false},
+		{"password changed", token, "note-1", "hash-2", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidNoteAccessToken(tt.token, tt.noteID, tt.hash); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	env.Default.JWTSigningKey = []byte("another signing key")
+	if ValidNoteAccessToken(token, "note-1", "hash-1") {
+		t.Error("token signed with another key is valid")
+	}
+}
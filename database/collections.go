@@ -0,0 +1,182 @@
+package database
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Collection is a named selection of the notes of a user, in the order they chose, like a
+// series. Readers see its deployed public notes.
+type Collection struct {
+	ID          string   `json:"id"`
+	UserID      string   `json:"user_id"` // fk to users
+	Slug        string   `json:"slug"`    // unique per user
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	NoteIDs     []string `json:"note_ids"` // the notes of the collection, in order
+	CreatedAt   string   `json:"created_at,omitempty"`
+	UpdatedAt   string   `json:"updated_at,omitempty"`
+}
+
+// Normalize trims the name and description of the collection and slugifies its slug, made from
+// its name if it is empty. It returns why the collection is invalid, or an empty string.
+func (c *Collection) Normalize() string {
+	c.Name = strings.TrimSpace(c.Name)
+	c.Description = strings.TrimSpace(c.Description)
+	c.Slug = slugify(cmp.Or(strings.TrimSpace(c.Slug), c.Name))
+	switch {
+	case c.Name == "":
+		return "missing name"
+	case utf8.RuneCountInString(c.Name) > 100:
+		return "name too long, must be at most 100 characters"
+	case utf8.RuneCountInString(c.Description) > 1000:
+		return "description too long, must be at most 1000 characters"
+	case c.Slug == "":
+		return "invalid slug, must have letters or digits"
+	}
+	return ""
+}
+
+// collectionNote is a note of a collection, as embedded in a collectionRow.
+type collectionNote struct {
+	NoteID   string `json:"note_id"`
+	Position int    `json:"position"`
+}
+
+// collectionRow is a collection with its notes embedded, as selected by collectionColumns.
+type collectionRow struct {
+	Collection
+	Notes []collectionNote `json:"collection_notes"`
+}
+
+// collectionColumns are the columns selected for collections, with their notes.
+const collectionColumns = "id,user_id,slug,name,description,created_at,updated_at,collection_notes(note_id,position)"
+
+// collection returns the collection of the row with its notes in order.
+func (r *collectionRow) collection() Collection {
+	slices.SortFunc(r.Notes, func(a, b collectionNote) int { return cmp.Compare(a.Position, b.Position) })
+	c := r.Collection
+	c.NoteIDs = []string{}
+	for _, n := range r.Notes {
+		c.NoteIDs = append(c.NoteIDs, n.NoteID)
+	}
+	return c
+}
+
+// selectCollections returns the collections of a user matching the column filters, oldest first.
+func (db *DB) selectCollections(userID string, filters map[string]string) ([]Collection, error) {
+	query := db.client.From("collections").Select(collectionColumns, "", false).Eq("user_id", userID)
+	for column, value := range filters {
+		query = query.Eq(column, value)
+	}
+	var rows []collectionRow
+	if _, err := query.Order("created_at", nil).ExecuteTo(&rows); err != nil {
+		return nil, err
+	}
+	collections := []Collection{}
+	for i := range rows {
+		collections = append(collections, rows[i].collection())
+	}
+	return collections, nil
+}
+
+// ListCollections returns the collections of a user, oldest first.
+func (db *DB) ListCollections(userID string) ([]Collection, error) {
+	collections, err := db.selectCollections(userID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("list collections: %w", err)
+	}
+	return collections, nil
+}
+
+// GetCollectionBySlug returns the collection of a user with a slug.
+func (db *DB) GetCollectionBySlug(userID, slug string) (*Collection, error) {
+	collections, err := db.selectCollections(userID, map[string]string{"slug": slug})
+	if err != nil {
+		return nil, fmt.Errorf("get collection by slug: %w", err)
+	}
+	if len(collections) == 0 {
+		return nil, fmt.Errorf("get collection by slug: %w", ErrNoRows)
+	}
+	return &collections[0], nil
+}
+
+// InsertCollection adds an empty collection for collection.UserID. The ID and timestamps are
+// populated by the database.
+func (db *DB) InsertCollection(collection *Collection) error {
+	_, err := db.client.From("collections").Insert(map[string]any{
+		"user_id":     collection.UserID,
+		"slug":        collection.Slug,
+		"name":        collection.Name,
+		"description": collection.Description,
+	}, false, "", "", "").Single().ExecuteTo(collection)
+	if err != nil {
+		return fmt.Errorf("insert collection: %w", err)
+	}
+	collection.NoteIDs = []string{}
+	return nil
+}
+
+// UpdateCollection updates the slug, name and description of a collection of a user, and
+// populates its other fields. It returns an error wrapping ErrNoRows if the user has no such
+// collection.
+func (db *DB) UpdateCollection(collection *Collection) error {
+	_, count, err := db.client.From("collections").Update(map[string]any{
+		"slug":        collection.Slug,
+		"name":        collection.Name,
+		"description": collection.Description,
+		"updated_at":  time.Now().UTC().Format(time.RFC3339Nano),
+	}, "minimal", "exact").Eq("id", collection.ID).Eq("user_id", collection.UserID).Execute()
+	if err != nil {
+		return fmt.Errorf("update collection: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("update collection: %w", ErrNoRows)
+	}
+
+	updated, err := db.selectCollections(collection.UserID, map[string]string{"id": collection.ID})
+	if err != nil {
+		return fmt.Errorf("get updated collection: %w", err)
+	}
+	if len(updated) > 0 {
+		*collection = updated[0]
+	}
+	return nil
+}
+
+// DeleteCollection deletes a collection of a user, not its notes. It returns an error wrapping
+// ErrNoRows if the user has no such collection.
+func (db *DB) DeleteCollection(collectionID, userID string) error {
+	var deleted []Collection
+	_, err := db.client.From("collections").Delete("representation", "").Eq("id", collectionID).Eq("user_id", userID).ExecuteTo(&deleted)
+	if err != nil {
+		return fmt.Errorf("delete collection: %w", err)
+	}
+	if len(deleted) == 0 {
+		return fmt.Errorf("delete collection: %w", ErrNoRows)
+	}
+	return nil
+}
+
+// SetCollectionNotes replaces the notes of a collection of a user by noteIDs, in that order, using
+// the set_collection_notes function. Ids of notes the user does not own, and repeated ids, are
+// skipped. It returns an error wrapping ErrNoRows if the user has no such collection.
+func (db *DB) SetCollectionNotes(collectionID, userID string, noteIDs []string) error {
+	var found bool
+	err := db.rpc("set_collection_notes", map[string]any{
+		"uid":      userID,
+		"cid":      collectionID,
+		"note_ids": noteIDs,
+	}, &found)
+	if err != nil {
+		return fmt.Errorf("set collection notes: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("set collection notes: %w", ErrNoRows)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/supabase-community/supabase-go"
@@ -26,6 +27,10 @@ type User struct {
 	GithubUsername    string `json:"github_username,omitempty"`
 
 	HasConnectedClient bool `json:"has_connected_client"` // whether the user has connected the client app
+
+	Noindex bool `json:"noindex"` // whether search engines should not index the user's profile and notes
+
+	NotesSort string `json:"notes_sort,omitempty"` // how the notes on the profile are sorted by default
 }
 
 // Note represents a note in the database.
@@ -40,9 +45,67 @@ type Note struct {
 	Title            string `json:"title"`
 	Slug             string `json:"slug,omitempty"`
 	Body             string `json:"body,omitempty"`
+	ContentHash      string `json:"content_hash,omitempty"` // ContentHash of the title and body, set on sync
+
+	Deployed   bool   `json:"deployed"`             // whether readers can reach the note, false if it is VisibilityPrivate
+	Visibility string `json:"visibility,omitempty"` // who can read the note, kept in line with Deployed by the database
+	Views      int64  `json:"views"`
+	Noindex    bool   `json:"noindex"` // whether search engines should not index the note
+
+	Pinned     bool  `json:"pinned"`                // whether the note is listed first on the profile
+	ManualRank int64 `json:"manual_rank,omitempty"` // rank in the order the owner chose, higher first, 0 if unranked
+
+	DeletedAt string `json:"deleted_at,omitempty"` // set when the note was deleted in its source, purged after a grace period
+
+	FolderID   string `json:"folder_id,omitempty"`   // id of the folder of the note in its source
+	FolderName string `json:"folder_name,omitempty"` // name of that folder
 
-	Deployed bool  `json:"deployed"`
-	Views    int64 `json:"views"`
+	// what readers of a note deployed with PublishSnapshot see, frozen until the note is republished
+	PublishMode    string `json:"publish_mode,omitempty"` // PublishLive or PublishSnapshot
+	PublishedTitle string `json:"published_title,omitempty"`
+	PublishedBody  string `json:"published_body,omitempty"`
+	PublishedAt    string `json:"published_at,omitempty"`
+
+	// when the scheduler deploys the note and undeploys it, cleared once done
+	DeployAt  string `json:"deploy_at,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+// Visibilities of a note.
+const (
+	VisibilityPrivate   = "private"   // only the owner can read the note, it is not deployed
+	VisibilityPublic    = "public"    // the note is deployed and listed on the profile, feeds, sitemaps and searches
+	VisibilityUnlisted  = "unlisted"  // the note is deployed but only readable by direct link
+	VisibilityProtected = "protected" // the note is deployed but only readable with its password
+)
+
+// Publish modes of a note.
+const (
+	PublishLive     = "live"     // readers see the note as last synced
+	PublishSnapshot = "snapshot" // readers see the note as it was when last published
+)
+
+// Folder is a folder of a user's notes in their source, with how many of its notes there are and
+// how many are deployed.
+type Folder struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Notes    int64  `json:"notes"`
+	Deployed int64  `json:"deployed"`
+}
+
+// NoteRevision represents a version of the title and body of a note, recorded when a sync changes
+// them or when an older revision is restored.
+type NoteRevision struct {
+	ID           string `json:"id,omitempty"`
+	NoteID       string `json:"note_id"` // fk to notes
+	UserID       string `json:"user_id"` // fk to users
+	Title        string `json:"title"`
+	Body         string `json:"body,omitempty"`
+	UpdatedAt    string `json:"updated_at"` // updated_at of the note in its source
+	ContentHash  string `json:"content_hash"`
+	RestoredFrom string `json:"restored_from,omitempty"` // id of the restored revision, if made by a restore
+	CreatedAt    string `json:"created_at,omitempty"`
 }
 
 // Activity represents an activity in the database.
@@ -56,6 +119,10 @@ type Activity struct {
 	Timestamp string `json:"timestamp,omitempty"` // time of occurrence
 }
 
+// pageSize is the number of rows requested at once when reading every row of a table through
+// postgrest, which caps how many rows a single request returns.
+const pageSize = 1000
+
 // DB is a wrapper around the Supabase client for database operations.
 type DB struct {
 	client        *supabase.Client
@@ -78,3 +145,22 @@ func Database(sbURL, sbKey string) (*DB, error) {
 
 	return db, nil
 }
+
+// rpc calls a postgres function through postgrest and decodes its JSON result into out.
+func (db *DB) rpc(name string, params map[string]any, out any) error {
+	data := db.client.Rpc(name, "", params)
+
+	// postgrest errors are objects with a message, results of set returning functions are arrays
+	var rpcErr struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(data), &rpcErr); err == nil && rpcErr.Message != "" {
+		return fmt.Errorf("%s: %s (code %s)", name, rpcErr.Message, rpcErr.Code)
+	}
+
+	if err := json.Unmarshal([]byte(data), out); err != nil {
+		return fmt.Errorf("decode %s result: %w", name, err)
+	}
+	return nil
+}
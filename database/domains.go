@@ -0,0 +1,145 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CustomDomain is a domain a user serves their profile and deployed notes on. It is only used
+// once verified: the user proves they own it with a DNS TXT record holding Token.
+type CustomDomain struct {
+	UserID     string `json:"user_id"` // fk to users, a user has at most one domain
+	Domain     string `json:"domain"`
+	Token      string `json:"token"`
+	VerifiedAt string `json:"verified_at,omitempty"` // empty until verified
+	CreatedAt  string `json:"created_at,omitempty"`
+}
+
+// Verified reports whether the domain was verified.
+func (d *CustomDomain) Verified() bool {
+	return d.VerifiedAt != ""
+}
+
+// Normalize lower cases the domain, without a trailing dot. It returns why the domain is not a
+// valid host name, or an empty string.
+func (d *CustomDomain) Normalize() string {
+	d.Domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(d.Domain)), ".")
+	if d.Domain == "" {
+		return "missing domain"
+	}
+	if len(d.Domain) > 253 {
+		return "domain too long"
+	}
+	labels := strings.Split(d.Domain, ".")
+	if len(labels) < 2 {
+		return "invalid domain, must have a top level domain like example.com"
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return "invalid domain"
+		}
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+				return "invalid domain, internationalized domains must be punycode encoded"
+			}
+		}
+	}
+	return ""
+}
+
+// newDomainToken returns a new verification token, lower cased as DNS tools often show records.
+func newDomainToken() string {
+	return strings.ToLower(randomB32(20))
+}
+
+// GetCustomDomain returns the custom domain of a user, verified or not.
+func (db *DB) GetCustomDomain(userID string) (*CustomDomain, error) {
+	var domains []CustomDomain
+	_, err := db.client.From("custom_domains").Select("*", "", false).Eq("user_id", userID).ExecuteTo(&domains)
+	if err != nil {
+		return nil, fmt.Errorf("get custom domain: %w", err)
+	}
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("get custom domain: %w", ErrNoRows)
+	}
+	return &domains[0], nil
+}
+
+// SetCustomDomain sets domain.Domain as the custom domain of domain.UserID, replacing the one they
+// had. The domain has a new token and is not verified, the Token and CreatedAt fields are
+// populated.
+func (db *DB) SetCustomDomain(domain *CustomDomain) error {
+	domain.Token = newDomainToken()
+	domain.VerifiedAt = ""
+	var set []CustomDomain
+	_, err := db.client.From("custom_domains").Upsert(map[string]any{
+		"user_id":     domain.UserID,
+		"domain":      domain.Domain,
+		"token":       domain.Token,
+		"verified_at": nil,
+		"created_at":  time.Now().UTC().Format(time.RFC3339Nano),
+	}, "user_id", "representation", "").ExecuteTo(&set)
+	if err != nil {
+		return fmt.Errorf("set custom domain: %w", err)
+	}
+	if len(set) > 0 {
+		domain.CreatedAt = set[0].CreatedAt
+	}
+	return nil
+}
+
+// VerifyCustomDomain marks the custom domain of a user as verified. Other users who had the
+// domain verified lose it, as it changed hands.
+func (db *DB) VerifyCustomDomain(userID string) (*CustomDomain, error) {
+	domain, err := db.GetCustomDomain(userID)
+	if err != nil {
+		return nil, fmt.Errorf("verify custom domain: %w", err)
+	}
+	_, _, err = db.client.From("custom_domains").Delete("minimal", "").Eq("domain", domain.Domain).
+		Neq("user_id", userID).Not("verified_at", "is", "null").Execute()
+	if err != nil {
+		return nil, fmt.Errorf("verify custom domain: %w", err)
+	}
+	domain.VerifiedAt = time.Now().UTC().Format(time.RFC3339Nano)
+	_, _, err = db.client.From("custom_domains").Update(map[string]any{
+		"verified_at": domain.VerifiedAt,
+	}, "minimal", "").Eq("user_id", userID).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("verify custom domain: %w", err)
+	}
+	return domain, nil
+}
+
+// DeleteCustomDomain removes the custom domain of a user. It returns an error wrapping ErrNoRows
+// if the user has none.
+func (db *DB) DeleteCustomDomain(userID string) error {
+	var deleted []CustomDomain
+	_, err := db.client.From("custom_domains").Delete("representation", "").Eq("user_id", userID).ExecuteTo(&deleted)
+	if err != nil {
+		return fmt.Errorf("delete custom domain: %w", err)
+	}
+	if len(deleted) == 0 {
+		return fmt.Errorf("delete custom domain: %w", ErrNoRows)
+	}
+	return nil
+}
+
+// GetUserByCustomDomain returns the user who has domain verified. It returns an error wrapping
+// ErrNoRows if no one does.
+func (db *DB) GetUserByCustomDomain(domain string) (*User, error) {
+	var domains []CustomDomain
+	_, err := db.client.From("custom_domains").Select("user_id", "", false).Eq("domain", domain).
+		Not("verified_at", "is", "null").ExecuteTo(&domains)
+	if err != nil {
+		return nil, fmt.Errorf("get user by custom domain: %w", err)
+	}
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("get user by custom domain: %w", ErrNoRows)
+	}
+	user, err := db.GetUserByID(domains[0].UserID)
+	if err != nil {
+		return nil, fmt.Errorf("get user by custom domain: %w", err)
+	}
+	return user, nil
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// FoldersOf returns the folders of notes, by name, with how many of the notes are in each. Notes
+// without a folder are left out.
+func FoldersOf(notes []Note) []Folder {
+	folders := []Folder{}
+	index := make(map[string]int)
+	for _, note := range notes {
+		if note.FolderID == "" {
+			continue
+		}
+		i, ok := index[note.FolderID]
+		if !ok {
+			i = len(folders)
+			index[note.FolderID] = i
+			folders = append(folders, Folder{ID: note.FolderID, Name: note.FolderName})
+		}
+		folders[i].Notes++
+		if note.Deployed {
+			folders[i].Deployed++
+		}
+	}
+	slices.SortFunc(folders, func(a, b Folder) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+	})
+	return folders
+}
+
+// ListFolders returns the folders of a user's notes, by name, except the deleted notes.
+func (db *DB) ListFolders(userID string) ([]Folder, error) {
+	var notes []Note
+	for page := 0; ; page++ {
+		var output []Note
+		_, err := db.client.From("notes").Select("folder_id,folder_name,deployed", "", false).
+			Eq("user_id", userID).Is("deleted_at", "null").
+			Order("id", nil).Range(page*pageSize, (page+1)*pageSize-1, "").ExecuteTo(&output)
+		if err != nil {
+			return nil, fmt.Errorf("list folders: %w", err)
+		}
+		notes = append(notes, output...)
+		if len(output) < pageSize {
+			return FoldersOf(notes), nil
+		}
+	}
+}
+
+// DeployFolder deploys the notes of a user's folder that are not deployed yet, live. Notes already
+// deployed, live or as a snapshot, are left alone. It returns how many notes were deployed.
+func (db *DB) DeployFolder(folderID, userID string) (int64, error) {
+	_, count, err := db.client.From("notes").Update(map[string]any{"deployed": true, "publish_mode": PublishLive}, "minimal", "exact").
+		Eq("user_id", userID).Eq("folder_id", folderID).Eq("deployed", "false").Is("deleted_at", "null").Execute()
+	if err != nil {
+		return 0, fmt.Errorf("deploy folder: %w", err)
+	}
+	return count, nil
+}
+
+// UndeployFolder undeploys the deployed notes of a user's folder. It returns how many notes were
+// undeployed.
+func (db *DB) UndeployFolder(folderID, userID string) (int64, error) {
+	_, count, err := db.client.From("notes").Update(map[string]any{"deployed": false}, "minimal", "exact").
+		Eq("user_id", userID).Eq("folder_id", folderID).Eq("deployed", "true").Execute()
+	if err != nil {
+		return 0, fmt.Errorf("undeploy folder: %w", err)
+	}
+	return count, nil
+}
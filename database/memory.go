@@ -0,0 +1,52 @@
+package database
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryDB is an in-memory Store. It honours the same semantics as the supabase schema (unique
+// usernames and emails, unique slugs per user, upserts on user_id+source_identifier) so the
+// backend can run without a database and handlers can be exercised in tests. Nothing is persisted.
+type MemoryDB struct {
+	mu sync.RWMutex
+
+	users       []*User
+	notes       []*Note
+	revisions   []*NoteRevision
+	rules       []*SyncRule
+	activities  []*Activity
+	uploads     []*Upload
+	domains     []*CustomDomain
+	shares      []*memoryShare
+	collections []*Collection
+	chunks      map[string]map[int][]Note // chunks of the uploads by upload id and sequence number
+	pfps        map[string][]byte         // profile pictures by filename
+	ogImages    map[string]ogImage        // Open Graph images by note id
+	passwords   map[string]string         // password hashes of notes by note id
+	pfpsURL     string                    // base URL the profile pictures are served from
+}
+
+// Memory returns a new, empty MemoryDB. Profile picture URLs are built from pfpsURL, the URL of
+// the route serving GetProfilePicture.
+func Memory(pfpsURL string) *MemoryDB {
+	return &MemoryDB{
+		chunks:    make(map[string]map[int][]Note),
+		pfps:      make(map[string][]byte),
+		ogImages:  make(map[string]ogImage),
+		passwords: make(map[string]string),
+		pfpsURL:   pfpsURL,
+	}
+}
+
+// newID returns a new random row id, like the uuid default on the supabase tables.
+func newID() string {
+	return uuid.New().String()
+}
+
+// now returns the current time formatted like a postgres timestamptz column.
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
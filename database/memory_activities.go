@@ -0,0 +1,116 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+func (m *MemoryDB) GetActivities(userID string, loadTime time.Time, offset, limit int) ([]Activity, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var activities []Activity
+	for _, a := range m.activities {
+		if a.UserID != userID {
+			continue
+		}
+		// add timestamp filter if loadTime is set
+		if loadTime.UnixMilli() > 0 {
+			ts, err := time.Parse(time.RFC3339Nano, a.Timestamp)
+			if err != nil || !ts.Before(loadTime) {
+				continue
+			}
+		}
+		activities = append(activities, *a)
+	}
+
+	// newest first, then apply the range like postgrest does
+	slices.SortStableFunc(activities, func(a, b Activity) int {
+		return -compareTimestamps(a.Timestamp, b.Timestamp)
+	})
+	if offset >= len(activities) {
+		return []Activity{}, nil
+	}
+	end := min(offset+max(limit, 1), len(activities))
+	return activities[offset:end], nil
+}
+
+func (m *MemoryDB) GetLastActivityByType(userID, activityType string) (*Activity, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var last *Activity
+	for _, a := range m.activities {
+		if a.UserID != userID || a.Type != activityType {
+			continue
+		}
+		if last == nil || compareTimestamps(a.Timestamp, last.Timestamp) >= 0 {
+			last = a
+		}
+	}
+	if last == nil {
+		return nil, fmt.Errorf("get last activity by type: %w", ErrNoRows)
+	}
+	cp := *last
+	return &cp, nil
+}
+
+func (m *MemoryDB) InsertActivity(activity *Activity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	activity.ID = newID()
+	if activity.Timestamp == "" {
+		activity.Timestamp = now()
+	}
+	cp := *activity
+	m.activities = append(m.activities, &cp)
+	return nil
+}
+
+// GetUserStats returns the same JSON object as the get_user_stats RPC.
+func (m *MemoryDB) GetUserStats(userID string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var stats struct {
+		TotalViews    int64 `json:"total_views"`
+		TotalNotes    int64 `json:"total_notes"`
+		DeployedNotes int64 `json:"deployed_notes"`
+	}
+	for _, n := range m.notes {
+		if n.UserID != userID {
+			continue
+		}
+		stats.TotalNotes++
+		stats.TotalViews += n.Views
+		if n.Deployed {
+			stats.DeployedNotes++
+		}
+	}
+
+	b, err := json.Marshal(stats)
+	if err != nil {
+		return "", fmt.Errorf("marshal user stats: %w", err)
+	}
+	return string(b), nil
+}
+
+// compareTimestamps compares two RFC 3339 timestamps, falling back to string comparison if
+// either cannot be parsed.
+func compareTimestamps(a, b string) int {
+	ta, errA := time.Parse(time.RFC3339Nano, a)
+	tb, errB := time.Parse(time.RFC3339Nano, b)
+	if errA != nil || errB != nil {
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	}
+	return ta.Compare(tb)
+}
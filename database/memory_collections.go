@@ -0,0 +1,129 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+)
+
+// copyCollection returns a copy of a collection that does not share its notes.
+func copyCollection(c *Collection) Collection {
+	cp := *c
+	cp.NoteIDs = slices.Clone(c.NoteIDs)
+	return cp
+}
+
+// ListCollections returns the collections of a user, oldest first.
+func (m *MemoryDB) ListCollections(userID string) ([]Collection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	collections := []Collection{}
+	for _, c := range m.collections {
+		if c.UserID == userID {
+			collections = append(collections, copyCollection(c))
+		}
+	}
+	return collections, nil
+}
+
+// GetCollectionBySlug returns the collection of a user with a slug.
+func (m *MemoryDB) GetCollectionBySlug(userID, slug string) (*Collection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	i := slices.IndexFunc(m.collections, func(c *Collection) bool { return c.UserID == userID && c.Slug == slug })
+	if i < 0 {
+		return nil, fmt.Errorf("get collection by slug: %w", ErrNoRows)
+	}
+	collection := copyCollection(m.collections[i])
+	return &collection, nil
+}
+
+// slugTaken reports whether a user has a collection other than collectionID with slug.
+func (m *MemoryDB) slugTaken(userID, collectionID, slug string) bool {
+	return slices.ContainsFunc(m.collections, func(c *Collection) bool {
+		return c.UserID == userID && c.ID != collectionID && c.Slug == slug
+	})
+}
+
+// InsertCollection adds an empty collection for collection.UserID. The ID and timestamps are
+// populated by the database.
+func (m *MemoryDB) InsertCollection(collection *Collection) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// same constraint name as the migrations so api error patterns match
+	if m.slugTaken(collection.UserID, "", collection.Slug) {
+		return fmt.Errorf("insert collection: duplicate key value violates unique constraint \"collections_user_id_slug_key\"")
+	}
+
+	collection.ID = newID()
+	collection.NoteIDs = []string{}
+	collection.CreatedAt = now()
+	collection.UpdatedAt = collection.CreatedAt
+	cp := copyCollection(collection)
+	m.collections = append(m.collections, &cp)
+	return nil
+}
+
+// UpdateCollection updates the slug, name and description of a collection of a user, and
+// populates its other fields. It returns an error wrapping ErrNoRows if the user has no such
+// collection.
+func (m *MemoryDB) UpdateCollection(collection *Collection) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.collections, func(c *Collection) bool { return c.ID == collection.ID && c.UserID == collection.UserID })
+	if i < 0 {
+		return fmt.Errorf("update collection: %w", ErrNoRows)
+	}
+	if m.slugTaken(collection.UserID, collection.ID, collection.Slug) {
+		return fmt.Errorf("update collection: duplicate key value violates unique constraint \"collections_user_id_slug_key\"")
+	}
+
+	stored := m.collections[i]
+	stored.Slug = collection.Slug
+	stored.Name = collection.Name
+	stored.Description = collection.Description
+	stored.UpdatedAt = now()
+	*collection = copyCollection(stored)
+	return nil
+}
+
+// DeleteCollection deletes a collection of a user, not its notes. It returns an error wrapping
+// ErrNoRows if the user has no such collection.
+func (m *MemoryDB) DeleteCollection(collectionID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := len(m.collections)
+	m.collections = slices.DeleteFunc(m.collections, func(c *Collection) bool { return c.ID == collectionID && c.UserID == userID })
+	if len(m.collections) == before {
+		return fmt.Errorf("delete collection: %w", ErrNoRows)
+	}
+	return nil
+}
+
+// SetCollectionNotes replaces the notes of a collection of a user by noteIDs, in that order, like
+// the set_collection_notes function. Ids of notes the user does not own, and repeated ids, are
+// skipped. It returns an error wrapping ErrNoRows if the user has no such collection.
+func (m *MemoryDB) SetCollectionNotes(collectionID, userID string, noteIDs []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.collections, func(c *Collection) bool { return c.ID == collectionID && c.UserID == userID })
+	if i < 0 {
+		return fmt.Errorf("set collection notes: %w", ErrNoRows)
+	}
+
+	notes := []string{}
+	for _, id := range noteIDs {
+		note := m.findNote(func(n *Note) bool { return n.ID == id && n.UserID == userID })
+		if note != nil && !slices.Contains(notes, note.ID) {
+			notes = append(notes, note.ID)
+		}
+	}
+	m.collections[i].NoteIDs = notes
+	m.collections[i].UpdatedAt = now()
+	return nil
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+)
+
+// GetCustomDomain returns the custom domain of a user, verified or not.
+func (m *MemoryDB) GetCustomDomain(userID string) (*CustomDomain, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, d := range m.domains {
+		if d.UserID == userID {
+			cp := *d
+			return &cp, nil
+		}
+	}
+	return nil, fmt.Errorf("get custom domain: %w", ErrNoRows)
+}
+
+// SetCustomDomain sets domain.Domain as the custom domain of domain.UserID, replacing the one they
+// had. The domain has a new token and is not verified, the Token and CreatedAt fields are
+// populated.
+func (m *MemoryDB) SetCustomDomain(domain *CustomDomain) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	domain.Token = newDomainToken()
+	domain.VerifiedAt = ""
+	domain.CreatedAt = now()
+	cp := *domain
+	m.domains = slices.DeleteFunc(m.domains, func(d *CustomDomain) bool { return d.UserID == domain.UserID })
+	m.domains = append(m.domains, &cp)
+	return nil
+}
+
+// VerifyCustomDomain marks the custom domain of a user as verified. Other users who had the
+// domain verified lose it, as it changed hands.
+func (m *MemoryDB) VerifyCustomDomain(userID string) (*CustomDomain, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.domains, func(d *CustomDomain) bool { return d.UserID == userID })
+	if i < 0 {
+		return nil, fmt.Errorf("verify custom domain: %w", ErrNoRows)
+	}
+	domain := m.domains[i]
+	m.domains = slices.DeleteFunc(m.domains, func(d *CustomDomain) bool {
+		return d.Domain == domain.Domain && d.UserID != userID && d.Verified()
+	})
+	domain.VerifiedAt = now()
+	cp := *domain
+	return &cp, nil
+}
+
+// DeleteCustomDomain removes the custom domain of a user. It returns an error wrapping ErrNoRows
+// if the user has none.
+func (m *MemoryDB) DeleteCustomDomain(userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := len(m.domains)
+	m.domains = slices.DeleteFunc(m.domains, func(d *CustomDomain) bool { return d.UserID == userID })
+	if len(m.domains) == before {
+		return fmt.Errorf("delete custom domain: %w", ErrNoRows)
+	}
+	return nil
+}
+
+// GetUserByCustomDomain returns the user who has domain verified. It returns an error wrapping
+// ErrNoRows if no one does.
+func (m *MemoryDB) GetUserByCustomDomain(domain string) (*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, d := range m.domains {
+		if d.Domain != domain || !d.Verified() {
+			continue
+		}
+		if user := m.findUser(func(u *User) bool { return u.ID == d.UserID }); user != nil {
+			cp := *user
+			return &cp, nil
+		}
+	}
+	return nil, fmt.Errorf("get user by custom domain: %w", ErrNoRows)
+}
@@ -0,0 +1,39 @@
+package database
+
+// ListFolders returns the folders of a user's notes, by name, except the deleted notes.
+func (m *MemoryDB) ListFolders(userID string) ([]Folder, error) {
+	return FoldersOf(m.listNotes(func(n *Note) bool { return n.UserID == userID && n.DeletedAt == "" })), nil
+}
+
+// DeployFolder deploys the notes of a user's folder that are not deployed yet, live. Notes already
+// deployed, live or as a snapshot, are left alone. It returns how many notes were deployed.
+func (m *MemoryDB) DeployFolder(folderID, userID string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var count int64
+	for _, n := range m.notes {
+		if n.UserID == userID && n.FolderID == folderID && !n.Deployed && n.DeletedAt == "" {
+			setDeployed(n, true)
+			n.PublishMode = PublishLive
+			count++
+		}
+	}
+	return count, nil
+}
+
+// UndeployFolder undeploys the deployed notes of a user's folder. It returns how many notes were
+// undeployed.
+func (m *MemoryDB) UndeployFolder(folderID, userID string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var count int64
+	for _, n := range m.notes {
+		if n.UserID == userID && n.FolderID == folderID && n.Deployed {
+			setDeployed(n, false)
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,352 @@
+package database
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// findNote returns the first note matching f. The caller must hold the lock.
+func (m *MemoryDB) findNote(f func(n *Note) bool) *Note {
+	for _, n := range m.notes {
+		if f(n) {
+			return n
+		}
+	}
+	return nil
+}
+
+// listNotes returns copies of all notes matching f, without their bodies.
+func (m *MemoryDB) listNotes(f func(n *Note) bool) []Note {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var notes []Note
+	for _, n := range m.notes {
+		if f(n) {
+			cp := *n
+			cp.Body = ""
+			cp.PublishedBody = ""
+			notes = append(notes, cp)
+		}
+	}
+	return notes
+}
+
+func (m *MemoryDB) CountNotes(userID string) (int64, error) {
+	return int64(len(m.listNotes(func(n *Note) bool { return n.UserID == userID && n.DeletedAt == "" }))), nil
+}
+
+// GetSourceIdentifiersByUserID retrieves the source identifiers of the notes of a user that are
+// not deleted, so that a note deleted in its source is sent again if it comes back.
+func (m *MemoryDB) GetSourceIdentifiersByUserID(userID string) ([]string, error) {
+	var identifiers []string
+	for _, n := range m.listNotes(func(n *Note) bool { return n.UserID == userID && n.DeletedAt == "" }) {
+		identifiers = append(identifiers, n.SourceIdentifier)
+	}
+	return identifiers, nil
+}
+
+// GetNoteByID retrieves a note by its ID. It includes the body.
+func (m *MemoryDB) GetNoteByID(noteID string) (*Note, error) {
+	return m.getNote(func(n *Note) bool { return n.ID == noteID })
+}
+
+// GetNoteBySlug retrieves a note by its username and slug. It includes the body.
+func (m *MemoryDB) GetNoteBySlug(username, slug string) (*Note, error) {
+	id, err := m.GetUserIDByUsername(username)
+	if err != nil {
+		return nil, fmt.Errorf("get user ID by username: %w", err)
+	}
+	return m.getNote(func(n *Note) bool { return n.UserID == id && n.Slug == slug })
+}
+
+func (m *MemoryDB) getNote(f func(n *Note) bool) (*Note, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	note := m.findNote(f)
+	if note == nil {
+		return nil, ErrNoRows
+	}
+	cp := *note
+	return &cp, nil
+}
+
+// ListNotes returns all notes in the database for a specific user, except the deleted ones. It only provides the body of the notes if opts.Bodies is set.
+func (m *MemoryDB) ListNotes(userID string, opts ListOptions) ([]Note, error) {
+	return m.bodies(opts, opts.sorted(m.listNotes(func(n *Note) bool { return n.UserID == userID && n.DeletedAt == "" && opts.hasTag(n, false) }), false)), nil
+}
+
+// ListDeployedNotes returns the public notes of a user: the deployed notes that are not unlisted
+// or password protected. It only provides the body of the notes if opts.Bodies is set.
+func (m *MemoryDB) ListDeployedNotes(userID string, opts ListOptions) ([]Note, error) {
+	return m.bodies(opts, opts.sorted(m.listNotes(func(n *Note) bool { return n.UserID == userID && n.Visibility == VisibilityPublic && opts.hasTag(n, true) }), true)), nil
+}
+
+// bodies puts back the bodies left out by listNotes in notes if opts asks for them.
+func (m *MemoryDB) bodies(opts ListOptions, notes []Note) []Note {
+	if !opts.Bodies {
+		return notes
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for i := range notes {
+		if n := m.findNote(func(n *Note) bool { return n.ID == notes[i].ID }); n != nil {
+			notes[i].Body = n.Body
+			notes[i].PublishedBody = n.PublishedBody
+		}
+	}
+	return notes
+}
+
+func (m *MemoryDB) IncrementNoteViews(noteID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if note := m.findNote(func(n *Note) bool { return n.ID == noteID }); note != nil {
+		note.Views++
+	}
+	return nil
+}
+
+// InsertNote inserts a new note into the database and returns the inserted note with its ID. If
+// a note with the same user_id, source and source_identifier exists, it is replaced (upsert). Slug
+// collisions are resolved by adding a random suffix, like DB.InsertNote.
+func (m *MemoryDB) InsertNote(note *Note) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.insertNote(note)
+}
+
+// insertNote is InsertNote without locking. The caller must hold the write lock.
+func (m *MemoryDB) insertNote(note *Note) error {
+	setDeployed(note, note.Deployed)
+	if note.Slug == "" { // if slug is not set, generate one
+		note.Slug = slugify(note.Title)
+	}
+	if note.Slug == "" { // if slug is still empty, generate a random slug
+		note.Slug = randomB32(5)
+	}
+
+	existing := m.findNote(func(n *Note) bool {
+		return n.UserID == note.UserID && keyOf(n) == keyOf(note)
+	})
+
+	// unique_user_slug: the slug must not be used by any other note of this user
+	for m.findNote(func(n *Note) bool {
+		return n != existing && n.UserID == note.UserID && n.Slug == note.Slug
+	}) != nil {
+		note.Slug = fmt.Sprintf("%s-%s", note.Slug, randomB32(5))
+	}
+
+	if existing != nil {
+		note.ID = existing.ID
+		note.InsertedAt = existing.InsertedAt
+		*existing = *note
+		return nil
+	}
+
+	note.ID = newID()
+	note.InsertedAt = now()
+	note.PublishMode = cmp.Or(note.PublishMode, PublishLive)
+	cp := *note
+	m.notes = append(m.notes, &cp)
+	return nil
+}
+
+func (m *MemoryDB) DeployNote(noteID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if note := m.findNote(func(n *Note) bool { return n.ID == noteID && n.UserID == userID }); note != nil {
+		setDeployed(note, true)
+		note.PublishMode = PublishLive
+	}
+	return nil
+}
+
+// PublishNote deploys a note as a snapshot of its current title and body. Publishing a note
+// already deployed as a snapshot replaces the snapshot. It returns an error wrapping ErrNoRows if
+// the user owns no such note.
+func (m *MemoryDB) PublishNote(noteID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	note := m.findNote(func(n *Note) bool { return n.ID == noteID && n.UserID == userID && n.DeletedAt == "" })
+	if note == nil {
+		return fmt.Errorf("publish note: %w", ErrNoRows)
+	}
+	setDeployed(note, true)
+	note.PublishMode = PublishSnapshot
+	note.PublishedTitle = note.Title
+	note.PublishedBody = note.Body
+	note.PublishedAt = now()
+	return nil
+}
+
+func (m *MemoryDB) UndeployNote(noteID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if note := m.findNote(func(n *Note) bool { return n.ID == noteID && n.UserID == userID }); note != nil {
+		setDeployed(note, false)
+	}
+	return nil
+}
+
+// UpdateNote updates an existing note in the database by its user ID, source and source
+// identifier. It returns an error wrapping ErrNoRows if the user owns no such note.
+func (m *MemoryDB) UpdateNote(note *Note) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored := m.findNote(func(n *Note) bool { return n.UserID == note.UserID && keyOf(n) == keyOf(note) })
+	if stored == nil {
+		return fmt.Errorf("update note: %w", ErrNoRows)
+	}
+	stored.CreatedAt = note.CreatedAt
+	stored.UpdatedAt = note.UpdatedAt
+	stored.Title = note.Title
+	stored.Body = note.Body
+	*note = *stored
+	return nil
+}
+
+// InsertNotesForUser inserts or updates the given notes of a user. It returns one result per
+// note, in the same order. Notes that fail validation are reported as failed and do not stop the
+// others from being synced.
+//
+// Stored notes of the sources in live that are missing from their list are marked as deleted and
+// undeployed, and reported after the given notes.
+func (m *MemoryDB) InsertNotesForUser(userID string, notes []Note, live map[string][]string) ([]SyncResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing := make(map[syncKey]string)
+	for _, n := range m.notes {
+		if n.UserID == userID {
+			existing[keyOf(n)] = n.Slug
+		}
+	}
+
+	batch := newSyncBatch(userID, notes, existing, m.syncRules(userID))
+	for _, note := range batch.notes {
+		stored := m.findNote(func(n *Note) bool { return n.UserID == userID && keyOf(n) == keyOf(&note) })
+
+		switch {
+		case stored == nil:
+			note.ID = newID()
+			note.InsertedAt = now()
+			note.CreatedAt = cmp.Or(note.CreatedAt, note.InsertedAt)
+			note.UpdatedAt = cmp.Or(note.UpdatedAt, note.InsertedAt)
+			setDeployed(&note, false)
+			note.Views = 0
+			note.PublishMode = PublishLive
+			note.Pinned, note.ManualRank = false, 0
+			m.notes = append(m.notes, &note)
+			m.addRevision(&note)
+			batch.set(keyOf(&note), note.ID, SyncInserted)
+		case stored.CreatedAt == note.CreatedAt && stored.UpdatedAt == note.UpdatedAt &&
+			stored.ContentHash == note.ContentHash && stored.DeletedAt == "" &&
+			(note.FolderID == "" || stored.FolderID == note.FolderID && stored.FolderName == note.FolderName):
+			batch.set(keyOf(&note), stored.ID, SyncUnchanged)
+		default:
+			changed := stored.ContentHash != note.ContentHash
+			stored.CreatedAt = cmp.Or(note.CreatedAt, now())
+			stored.UpdatedAt = cmp.Or(note.UpdatedAt, now())
+			stored.Title = note.Title
+			stored.Body = note.Body
+			stored.ContentHash = note.ContentHash
+			stored.DeletedAt = "" // restored in the source app
+			// notes synced without a folder keep theirs
+			if note.FolderID != "" {
+				stored.FolderID = note.FolderID
+				stored.FolderName = note.FolderName
+			}
+			if changed {
+				m.addRevision(stored)
+			}
+			batch.set(keyOf(&note), stored.ID, SyncUpdated)
+		}
+	}
+
+	// deploy or undeploy the notes matching a rule
+	for _, note := range batch.notes {
+		deploy := batch.ruleDeploy(keyOf(&note))
+		stored := m.findNote(func(n *Note) bool { return n.UserID == userID && keyOf(n) == keyOf(&note) })
+		if deploy == nil || stored.Deployed == *deploy {
+			continue
+		}
+		setDeployed(stored, *deploy)
+		if *deploy {
+			stored.PublishMode = PublishLive
+		}
+		batch.ruled(keyOf(&note), *deploy)
+	}
+
+	// mark the notes missing from the live sources as deleted
+	alive := liveSet(batch.live(live))
+	for _, note := range batch.notes {
+		alive[keyOf(&note)] = true
+	}
+	for _, n := range m.notes {
+		if n.UserID != userID || n.DeletedAt != "" || alive[keyOf(n)] {
+			continue
+		}
+		if _, listed := live[n.Source]; !listed {
+			continue
+		}
+		undeployed := n.Deployed
+		n.DeletedAt = now()
+		setDeployed(n, false)
+		batch.deleted(keyOf(n), n.ID, undeployed)
+	}
+
+	return batch.results, nil
+}
+
+// PurgeDeletedNotes permanently deletes the notes that were marked as deleted before deletedBefore.
+// It returns how many notes were deleted.
+func (m *MemoryDB) PurgeDeletedNotes(deletedBefore time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := len(m.notes)
+	purged := make(map[string]bool)
+	m.notes = slices.DeleteFunc(m.notes, func(n *Note) bool {
+		if n.DeletedAt == "" || compareTimestamps(n.DeletedAt, deletedBefore.Format(time.RFC3339Nano)) >= 0 {
+			return false
+		}
+		purged[n.ID] = true
+		return true
+	})
+	m.revisions = slices.DeleteFunc(m.revisions, func(r *NoteRevision) bool { return purged[r.NoteID] })
+	m.shares = slices.DeleteFunc(m.shares, func(s *memoryShare) bool { return purged[s.NoteID] })
+	for _, c := range m.collections {
+		c.NoteIDs = slices.DeleteFunc(c.NoteIDs, func(id string) bool { return purged[id] })
+	}
+	for id := range purged {
+		delete(m.ogImages, id)
+		delete(m.passwords, id)
+	}
+	return int64(before - len(m.notes)), nil
+}
+
+// GetNoteManifest returns the manifest entries of a user's notes from source, except the deleted ones.
+func (m *MemoryDB) GetNoteManifest(userID, source string) ([]ManifestEntry, error) {
+	manifest := []ManifestEntry{}
+	for _, n := range m.listNotes(func(n *Note) bool { return n.UserID == userID && n.Source == source && n.DeletedAt == "" }) {
+		manifest = append(manifest, ManifestEntry{
+			Source:           n.Source,
+			SourceIdentifier: n.SourceIdentifier,
+			UpdatedAt:        n.UpdatedAt,
+			ContentHash:      n.ContentHash,
+			FolderID:         n.FolderID,
+			FolderName:       n.FolderName,
+		})
+	}
+	return manifest, nil
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// compareSortKeys compares two values of the sort column of notes.
+func compareSortKeys(sort, a, b string) int {
+	if sort == SortViews || sort == SortManual {
+		x, _ := strconv.ParseInt(a, 10, 64)
+		y, _ := strconv.ParseInt(b, 10, 64)
+		return cmp.Compare(x, y)
+	}
+	return compareTimestamps(a, b)
+}
+
+// compareListed compares the positions of two notes in the sort of opts, given as the cursors of
+// the notes: pinned notes first, then by sort column and id.
+func (opts ListOptions) compareListed(a, b *ListCursor) int {
+	if a.Pinned != b.Pinned {
+		if a.Pinned {
+			return -1
+		}
+		return 1
+	}
+	c := cmp.Or(compareSortKeys(opts.Sort, a.Key, b.Key), cmp.Compare(a.ID, b.ID))
+	if !opts.Ascending {
+		c = -c
+	}
+	return c
+}
+
+// sorted sorts notes like the order of the other stores, keeping the ones after the cursor of opts
+// up to its limit. public is set for public notes, sorted as their readers see them.
+func (opts ListOptions) sorted(notes []Note, public bool) []Note {
+	if opts.Sort == "" {
+		return notes
+	}
+	cursor := func(n *Note) *ListCursor {
+		return &ListCursor{Pinned: n.Pinned, Key: sortKey(opts.Sort, n, public), ID: n.ID}
+	}
+	slices.SortFunc(notes, func(a, b Note) int { return opts.compareListed(cursor(&a), cursor(&b)) })
+	if opts.After != nil {
+		notes = slices.DeleteFunc(notes, func(n Note) bool { return opts.compareListed(cursor(&n), opts.After) <= 0 })
+	}
+	if opts.Limit > 0 && len(notes) > opts.Limit {
+		notes = notes[:opts.Limit]
+	}
+	return notes
+}
+
+// SetNotePinned pins a note to the top of the profile of its owner, or unpins it. It returns an
+// error wrapping ErrNoRows if the user owns no such note.
+func (m *MemoryDB) SetNotePinned(noteID, userID string, pinned bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	note := m.findNote(func(n *Note) bool { return n.ID == noteID && n.UserID == userID })
+	if note == nil {
+		return fmt.Errorf("set note pinned: %w", ErrNoRows)
+	}
+	note.Pinned = pinned
+	return nil
+}
+
+// SetNotesManualOrder ranks the notes of a user in the order of noteIDs for SortManual, and
+// unranks the others, like the set_notes_manual_order function. Ids of notes the user does not
+// own are skipped.
+func (m *MemoryDB) SetNotesManualOrder(userID string, noteIDs []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, note := range m.notes {
+		if note.UserID != userID {
+			continue
+		}
+		note.ManualRank = 0
+		if i := slices.Index(noteIDs, note.ID); i >= 0 {
+			note.ManualRank = int64(len(noteIDs) - i)
+		}
+	}
+	return nil
+}
+
+// SetUserNotesSort sets how the notes on the profile of a user are sorted by default.
+func (m *MemoryDB) SetUserNotesSort(userID, sort string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if user := m.findUser(func(u *User) bool { return u.ID == userID }); user != nil {
+		user.NotesSort = sort
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMemoryListDeployedNotesSortsSnapshotsByPublishedAt(t *testing.T) {
+	m := Memory("")
+	note := func(id, updatedAt string) Note {
+		return Note{Source: "apple-notes", SourceIdentifier: id, Title: id, Body: id,
+			CreatedAt: "2026-01-01T00:00:00Z", UpdatedAt: updatedAt}
+	}
+	results, err := m.InsertNotesForUser("alice", []Note{note("live", "2026-01-15T00:00:00Z"), note("snapshot", "2026-01-10T00:00:00Z")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeployNote(results[0].ID, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.PublishNote(results[1].ID, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	m.notes[1].PublishedAt = "2026-01-12T00:00:00Z"
+
+	// the working copy of the snapshot is edited after the live note, readers do not see it
+	edited := note("snapshot", "2026-02-01T00:00:00Z")
+	edited.Body = "draft"
+	if _, err := m.InsertNotesForUser("alice", []Note{edited}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := ListOptions{Sort: SortUpdated, Limit: 1}
+	var titles []string
+	for range 3 {
+		notes, err := m.ListDeployedNotes("alice", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(notes) == 0 {
+			break
+		}
+		titles = append(titles, notes[0].Title)
+		cursor, err := ParseCursor(opts.Cursor(&notes[0], true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.After = cursor
+	}
+	if want := []string{"live", "snapshot"}; !slices.Equal(titles, want) {
+		t.Errorf("got pages %v, want %v", titles, want)
+	}
+}
+
+func TestMemoryListDeployedNotesBodies(t *testing.T) {
+	m := Memory("")
+	results, err := m.InsertNotesForUser("alice", []Note{{Source: "apple-notes", SourceIdentifier: "x1", Title: "x1", Body: "body",
+		CreatedAt: "2026-01-01T00:00:00Z", UpdatedAt: "2026-01-01T00:00:00Z"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.PublishNote(results[0].ID, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		bodies              bool
+		body, publishedBody string
+	}{
+		{false, "", ""},
+		{true, "body", "body"},
+	} {
+		notes, err := m.ListDeployedNotes("alice", ListOptions{Sort: SortUpdated, Bodies: tt.bodies})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(notes) != 1 || notes[0].Body != tt.body || notes[0].PublishedBody != tt.publishedBody {
+			t.Errorf("bodies %v: got %v, want body %q and published body %q", tt.bodies, notes, tt.body, tt.publishedBody)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+)
+
+// addRevision records the current title and body of note as a revision. The caller must hold the
+// write lock.
+func (m *MemoryDB) addRevision(note *Note) {
+	m.revisions = append(m.revisions, &NoteRevision{
+		ID:          newID(),
+		NoteID:      note.ID,
+		UserID:      note.UserID,
+		Title:       note.Title,
+		Body:        note.Body,
+		UpdatedAt:   note.UpdatedAt,
+		ContentHash: note.ContentHash,
+		CreatedAt:   now(),
+	})
+}
+
+// ListNoteRevisions returns the revisions of a user's note, newest first. It does not provide the
+// body of the revisions.
+func (m *MemoryDB) ListNoteRevisions(noteID, userID string) ([]NoteRevision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	revisions := []NoteRevision{}
+	for _, r := range slices.Backward(m.revisions) { // appended in order of creation
+		if r.NoteID == noteID && r.UserID == userID {
+			cp := *r
+			cp.Body = ""
+			revisions = append(revisions, cp)
+		}
+	}
+	return revisions, nil
+}
+
+// GetNoteRevision retrieves a revision of a user's note. It includes the body.
+func (m *MemoryDB) GetNoteRevision(revisionID, noteID, userID string) (*NoteRevision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, r := range m.revisions {
+		if r.ID == revisionID && r.NoteID == noteID && r.UserID == userID {
+			cp := *r
+			return &cp, nil
+		}
+	}
+	return nil, fmt.Errorf("get note revision: %w", ErrNoRows)
+}
+
+// RestoreNoteRevision makes the title and body of a revision the current ones of its note, or its
+// published snapshot if it is deployed as one, and returns the revision recording the restore. The
+// content hash of the note is left alone, so the next sync only replaces the restored version if
+// the note was edited in its source since.
+func (m *MemoryDB) RestoreNoteRevision(revisionID, noteID, userID string) (*NoteRevision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.revisions, func(r *NoteRevision) bool {
+		return r.ID == revisionID && r.NoteID == noteID && r.UserID == userID
+	})
+	note := m.findNote(func(n *Note) bool { return n.ID == noteID && n.UserID == userID && n.DeletedAt == "" })
+	if i < 0 || note == nil {
+		return nil, fmt.Errorf("restore note revision: %w", ErrNoRows)
+	}
+	old := m.revisions[i]
+
+	if note.Deployed && note.PublishMode == PublishSnapshot { // readers see the restored version straight away
+		note.PublishedTitle = old.Title
+		note.PublishedBody = old.Body
+		note.PublishedAt = now()
+	} else {
+		note.Title = old.Title
+		note.Body = old.Body
+	}
+	revision := &NoteRevision{
+		ID:           newID(),
+		NoteID:       noteID,
+		UserID:       userID,
+		Title:        old.Title,
+		Body:         old.Body,
+		UpdatedAt:    now(),
+		ContentHash:  old.ContentHash,
+		RestoredFrom: old.ID,
+		CreatedAt:    now(),
+	}
+	m.revisions = append(m.revisions, revision)
+	cp := *revision
+	return &cp, nil
+}
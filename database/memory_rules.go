@@ -0,0 +1,59 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+)
+
+// syncRules returns copies of the sync rules of a user, oldest first. The caller must hold the
+// lock.
+func (m *MemoryDB) syncRules(userID string) []SyncRule {
+	rules := []SyncRule{}
+	for _, r := range m.rules {
+		if r.UserID == userID {
+			rules = append(rules, *r)
+		}
+	}
+	return rules
+}
+
+// ListSyncRules returns the sync rules of a user, oldest first.
+func (m *MemoryDB) ListSyncRules(userID string) ([]SyncRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.syncRules(userID), nil
+}
+
+// InsertSyncRule adds a sync rule for rule.UserID. The ID and created_at fields are populated by
+// the database.
+func (m *MemoryDB) InsertSyncRule(rule *SyncRule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// same constraint name as the migrations so api error patterns match
+	if slices.ContainsFunc(m.rules, func(r *SyncRule) bool {
+		return r.UserID == rule.UserID && r.Action == rule.Action && r.Match == rule.Match && r.Value == rule.Value
+	}) {
+		return fmt.Errorf("insert sync rule: duplicate key value violates unique constraint \"sync_rules_user_id_action_match_value_key\"")
+	}
+
+	rule.ID = newID()
+	rule.CreatedAt = now()
+	cp := *rule
+	m.rules = append(m.rules, &cp)
+	return nil
+}
+
+// DeleteSyncRule deletes a sync rule of a user. It returns an error wrapping ErrNoRows if the user
+// has no such rule.
+func (m *MemoryDB) DeleteSyncRule(ruleID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := len(m.rules)
+	m.rules = slices.DeleteFunc(m.rules, func(r *SyncRule) bool { return r.ID == ruleID && r.UserID == userID })
+	if len(m.rules) == before {
+		return fmt.Errorf("delete sync rule: %w", ErrNoRows)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// memoryScheduleTime returns t as a timestamp to store, empty if it is zero.
+func memoryScheduleTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// SetNoteSchedule replaces the schedule of a note. It returns an error wrapping ErrNoRows if the
+// user owns no such note.
+func (m *MemoryDB) SetNoteSchedule(noteID, userID string, schedule NoteSchedule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	note := m.findNote(func(n *Note) bool { return n.ID == noteID && n.UserID == userID })
+	if note == nil {
+		return fmt.Errorf("set note schedule: %w", ErrNoRows)
+	}
+	note.DeployAt = memoryScheduleTime(schedule.DeployAt)
+	note.ExpiresAt = memoryScheduleTime(schedule.ExpiresAt)
+	return nil
+}
+
+// RunNoteSchedules deploys the notes scheduled to be deployed by now and undeploys the ones
+// expired by then, like the run_note_schedules function. It returns the notes it deployed or
+// undeployed.
+func (m *MemoryDB) RunNoteSchedules(now time.Time) ([]ScheduledChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	until := now.UTC().Format(time.RFC3339Nano)
+	due := func(t string) bool { return t != "" && compareTimestamps(t, until) <= 0 }
+
+	var changes []ScheduledChange
+	for _, note := range m.notes {
+		expired, deploy := due(note.ExpiresAt), due(note.DeployAt)
+		if !expired && !deploy {
+			continue
+		}
+
+		wasDeployed := note.Deployed
+		switch {
+		case expired:
+			setDeployed(note, false)
+			note.ExpiresAt = ""
+		case note.DeletedAt == "" && !note.Deployed:
+			setDeployed(note, true)
+			note.PublishMode = PublishLive
+		}
+		note.DeployAt = ""
+
+		switch {
+		case note.Deployed && !wasDeployed:
+			changes = append(changes, ScheduledChange{NoteID: note.ID, UserID: note.UserID, Action: ScheduleDeployed})
+		case !note.Deployed && wasDeployed:
+			changes = append(changes, ScheduledChange{NoteID: note.ID, UserID: note.UserID, Action: ScheduleUndeployed})
+		}
+	}
+	return changes, nil
+}
+
+// NextNoteSchedule returns when the next schedule of a note is due. It returns the zero time if no
+// note is scheduled.
+func (m *MemoryDB) NextNoteSchedule() (time.Time, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var next time.Time
+	for _, note := range m.notes {
+		for _, at := range []string{note.DeployAt, note.ExpiresAt} {
+			if at == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339Nano, at)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("parse note schedule: %w", err)
+			}
+			if next.IsZero() || t.Before(next) {
+				next = t
+			}
+		}
+	}
+	return next, nil
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// compareChanges orders scheduled changes by action and note.
+func compareChanges(a, b ScheduledChange) int {
+	return cmp.Or(strings.Compare(a.Action, b.Action), strings.Compare(a.NoteID, b.NoteID))
+}
+
+func TestMemoryRunNoteSchedules(t *testing.T) {
+	m := Memory("")
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	note := func(id string) Note {
+		return Note{Source: "apple-notes", SourceIdentifier: id, Title: id, Body: id,
+			CreatedAt: "2026-01-01T00:00:00Z", UpdatedAt: "2026-01-01T00:00:00Z"}
+	}
+	results, err := m.InsertNotesForUser("alice", []Note{note("scheduled"), note("both"), note("expiring"), note("deleted")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduled, both, expiring, deleted := results[0].ID, results[1].ID, results[2].ID, results[3].ID
+	if err := m.DeployNote(expiring, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	// deleted in Apple Notes after it was scheduled
+	if _, err := m.InsertNotesForUser("alice", nil, map[string][]string{"apple-notes": {"scheduled", "both", "expiring"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, schedule := range map[string]NoteSchedule{
+		scheduled: {DeployAt: start.Add(time.Hour)},
+		both:      {DeployAt: start.Add(time.Hour), ExpiresAt: start.Add(2 * time.Hour)},
+		expiring:  {ExpiresAt: start.Add(time.Hour)},
+		deleted:   {DeployAt: start.Add(time.Hour)},
+	} {
+		if err := m.SetNoteSchedule(id, "alice", schedule); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(now time.Time) []ScheduledChange {
+		t.Helper()
+		changes, err := m.RunNoteSchedules(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.SortFunc(changes, compareChanges)
+		return changes
+	}
+	deployed := func(id string) bool {
+		t.Helper()
+		n, err := m.GetNoteByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n.Deployed
+	}
+
+	if changes := run(start); len(changes) != 0 {
+		t.Errorf("got changes %v before any schedule is due", changes)
+	}
+	if next, err := m.NextNoteSchedule(); err != nil || !next.Equal(start.Add(time.Hour)) {
+		t.Errorf("got next schedule %v (%v), want %v", next, err, start.Add(time.Hour))
+	}
+
+	// the backend was down from before the schedules were due until after they all were: they run
+	// on the first tick after it starts, the expiry winning over the deploy it follows
+	changes := run(start.Add(3 * time.Hour))
+	want := []ScheduledChange{
+		{NoteID: scheduled, UserID: "alice", Action: ScheduleDeployed},
+		{NoteID: expiring, UserID: "alice", Action: ScheduleUndeployed},
+	}
+	slices.SortFunc(want, compareChanges)
+	if !slices.Equal(changes, want) {
+		t.Errorf("got changes %v, want %v", changes, want)
+	}
+	for id, want := range map[string]bool{scheduled: true, both: false, expiring: false, deleted: false} {
+		if got := deployed(id); got != want {
+			t.Errorf("note %s got deployed %v, want %v", id, got, want)
+		}
+	}
+
+	// schedules run once
+	if changes := run(start.Add(4 * time.Hour)); len(changes) != 0 {
+		t.Errorf("got changes %v after the schedules ran", changes)
+	}
+	if next, err := m.NextNoteSchedule(); err != nil || !next.IsZero() {
+		t.Errorf("got next schedule %v (%v), want none", next, err)
+	}
+}
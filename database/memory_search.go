@@ -0,0 +1,225 @@
+package database
+
+import (
+	"cmp"
+	"html"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// searchTerm is a word or a quoted phrase of a search query, as lower case words.
+type searchTerm []string
+
+// searchClause is satisfied when any of its terms is found.
+type searchClause struct {
+	terms   []searchTerm
+	exclude bool
+}
+
+// parseSearchQuery parses a web search style query like websearch_to_tsquery: every clause must
+// be satisfied, "or" joins the terms around it in one clause and a leading - excludes a term.
+func parseSearchQuery(query string) []searchClause {
+	var clauses []searchClause
+	or := false
+	for query = strings.TrimSpace(query); query != ""; query = strings.TrimSpace(query) {
+		exclude := false
+		if query[0] == '-' {
+			exclude = true
+			query = query[1:]
+		}
+
+		var raw string
+		if query != "" && query[0] == '"' {
+			raw, query, _ = strings.Cut(query[1:], `"`)
+		} else {
+			raw, query, _ = strings.Cut(query, " ")
+		}
+		if !exclude && strings.EqualFold(raw, "or") && len(clauses) > 0 {
+			or = true
+			continue
+		}
+
+		term := searchTerm(searchWords(strings.ToLower(raw)))
+		if len(term) == 0 {
+			continue
+		}
+		if or && !exclude && !clauses[len(clauses)-1].exclude {
+			clauses[len(clauses)-1].terms = append(clauses[len(clauses)-1].terms, term)
+		} else {
+			clauses = append(clauses, searchClause{terms: []searchTerm{term}, exclude: exclude})
+		}
+		or = false
+	}
+	return clauses
+}
+
+// searchWords returns the words of s, runs of letters and digits, like the simple text search
+// configuration.
+func searchWords(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return !isAlphanumeric(r) })
+}
+
+// wordSpans returns the byte offsets of the words of s.
+func wordSpans(s string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range s {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if word && start < 0 {
+			start = i
+		} else if !word && start >= 0 {
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(s)})
+	}
+	return spans
+}
+
+// matchTerm returns the index of each word of words starting an occurrence of term.
+func matchTerm(words []string, term searchTerm) []int {
+	var at []int
+	for i := 0; i+len(term) <= len(words); i++ {
+		if slices.Equal(words[i:i+len(term)], term) {
+			at = append(at, i)
+		}
+	}
+	return at
+}
+
+// highlight wraps the words of s matched by the clauses in <mark>. With window set, it only
+// returns about window words around the first match.
+func highlight(s string, clauses []searchClause, window int) string {
+	spans := wordSpans(s)
+	words := make([]string, len(spans))
+	for i, sp := range spans {
+		words[i] = strings.ToLower(s[sp[0]:sp[1]])
+	}
+
+	marked := make([]bool, len(words))
+	first := -1
+	for _, c := range clauses {
+		if c.exclude {
+			continue
+		}
+		for _, t := range c.terms {
+			for _, i := range matchTerm(words, t) {
+				for j := i; j < i+len(t); j++ {
+					marked[j] = true
+				}
+				if first < 0 || i < first {
+					first = i
+				}
+			}
+		}
+	}
+
+	from, to := 0, len(spans)
+	if window > 0 {
+		from = max(first-window/3, 0)
+		to = min(from+window, len(spans))
+	}
+
+	var b strings.Builder
+	pos := 0
+	if from > 0 {
+		pos = spans[from][0]
+		b.WriteString("… ")
+	}
+	for i := from; i < to; i++ {
+		if !marked[i] {
+			continue
+		}
+		b.WriteString(s[pos:spans[i][0]])
+		b.WriteString("<mark>")
+		b.WriteString(s[spans[i][0]:spans[i][1]])
+		b.WriteString("</mark>")
+		pos = spans[i][1]
+	}
+	end := len(s)
+	if to < len(spans) {
+		end = spans[to-1][1]
+	}
+	if pos < end {
+		b.WriteString(s[pos:end])
+	}
+	if to < len(spans) {
+		b.WriteString(" …")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// SearchNotes returns the notes of a user matching a full-text search over their title and body,
+// best first. Matches in the title rank higher than matches in the body.
+func (m *MemoryDB) SearchNotes(userID string, opts SearchOptions) ([]SearchResult, error) {
+	clauses := parseSearchQuery(opts.Query)
+	if !slices.ContainsFunc(clauses, func(c searchClause) bool { return !c.exclude }) {
+		return []SearchResult{}, nil // like an empty tsquery, which matches nothing
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	results := []SearchResult{}
+	for _, n := range m.notes {
+		if n.UserID != userID || n.DeletedAt != "" || (opts.Public && n.Visibility != VisibilityPublic) ||
+			(opts.Deployed != nil && n.Deployed != *opts.Deployed) {
+			continue
+		}
+
+		title, body, updatedAt := n.Title, n.Body, n.UpdatedAt
+		if opts.Public && n.PublishMode == PublishSnapshot {
+			title, body, updatedAt = n.PublishedTitle, n.PublishedBody, n.PublishedAt
+		}
+		if t, err := time.Parse(time.RFC3339Nano, updatedAt); err == nil {
+			if (!opts.UpdatedAfter.IsZero() && t.Before(opts.UpdatedAfter)) ||
+				(!opts.UpdatedBefore.IsZero() && !t.Before(opts.UpdatedBefore)) {
+				continue
+			}
+		}
+
+		text := stripHTML(body)
+		titleWords, bodyWords := searchWords(strings.ToLower(title)), searchWords(strings.ToLower(text))
+		var rank float64
+		matched := true
+		for _, c := range clauses {
+			found := false
+			for _, t := range c.terms {
+				inTitle, inBody := len(matchTerm(titleWords, t)), len(matchTerm(bodyWords, t))
+				if inTitle+inBody > 0 {
+					found = true
+					rank += float64(inTitle) + 0.4*float64(inBody)
+				}
+			}
+			if found == c.exclude {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		results = append(results, SearchResult{
+			ID:             n.ID,
+			Title:          title,
+			Slug:           n.Slug,
+			Deployed:       n.Deployed,
+			UpdatedAt:      updatedAt,
+			Rank:           rank / float64(1+len(titleWords)+len(bodyWords)) * 10,
+			TitleHighlight: highlight(html.EscapeString(title), clauses, 0),
+			Snippet:        highlight(text, clauses, 35),
+		})
+	}
+
+	slices.SortFunc(results, func(a, b SearchResult) int {
+		return cmp.Or(cmp.Compare(b.Rank, a.Rank), strings.Compare(b.UpdatedAt, a.UpdatedAt), strings.Compare(a.ID, b.ID))
+	})
+
+	results = results[min(opts.Offset, len(results)):]
+	return results[:min(opts.Limit, len(results))], nil
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// memoryShare is a NoteShare with the hash of its token.
+type memoryShare struct {
+	NoteShare
+	tokenHash string
+}
+
+// ListNoteShares returns the share links of a note of a user, newest first, without their tokens.
+func (m *MemoryDB) ListNoteShares(noteID, userID string) ([]NoteShare, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	shares := []NoteShare{}
+	for _, s := range slices.Backward(m.shares) {
+		if s.NoteID == noteID && s.UserID == userID {
+			shares = append(shares, s.NoteShare)
+		}
+	}
+	return shares, nil
+}
+
+// InsertNoteShare adds a share link to share.NoteID. Its token, ID and created_at fields are
+// generated.
+func (m *MemoryDB) InsertNoteShare(share *NoteShare) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.findNote(func(n *Note) bool { return n.ID == share.NoteID }) == nil {
+		return fmt.Errorf("insert note share: %w", ErrNoRows)
+	}
+	share.Token = newShareToken()
+	share.ID = newID()
+	share.Views = 0
+	share.CreatedAt = now()
+	stored := &memoryShare{NoteShare: *share, tokenHash: shareTokenHash(share.Token)}
+	stored.Token = ""
+	m.shares = append(m.shares, stored)
+	return nil
+}
+
+// RevokeNoteShare revokes a share link of a note of a user. It returns an error wrapping ErrNoRows
+// if the user has no such share, or if it is already revoked.
+func (m *MemoryDB) RevokeNoteShare(shareID, noteID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.shares {
+		if s.ID == shareID && s.NoteID == noteID && s.UserID == userID && s.RevokedAt == "" {
+			s.RevokedAt = now()
+			return nil
+		}
+	}
+	return fmt.Errorf("revoke note share: %w", ErrNoRows)
+}
+
+// UseNoteShare counts a view of the share link with token and returns it, like the use_note_share
+// function. It returns an error wrapping ErrNoRows if there is no such share, or if it is revoked,
+// expired or out of views.
+func (m *MemoryDB) UseNoteShare(token string) (*NoteShare, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hash := shareTokenHash(token)
+	for _, s := range m.shares {
+		if s.tokenHash != hash {
+			continue
+		}
+		if s.RevokedAt != "" || compareTimestamps(s.ExpiresAt, time.Now().UTC().Format(time.RFC3339Nano)) <= 0 ||
+			(s.MaxViews > 0 && s.Views >= s.MaxViews) {
+			break
+		}
+		s.Views++
+		share := s.NoteShare
+		return &share, nil
+	}
+	return nil, fmt.Errorf("use note share: %w", ErrNoRows)
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ListSitemapUsers returns the users that are not noindex and have public notes that are not
+// noindex, by username.
+func (m *MemoryDB) ListSitemapUsers() ([]SitemapUser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	users := []SitemapUser{}
+	for _, u := range m.users {
+		entry := SitemapUser{Username: u.Username}
+		for _, n := range m.notes {
+			if n.UserID != u.ID || n.Visibility != VisibilityPublic || n.Noindex {
+				continue
+			}
+			entry.Notes++
+			modified := n.PublicUpdatedAt()
+			if entry.LastModified == "" || compareTimestamps(modified, entry.LastModified) > 0 {
+				entry.LastModified = modified
+			}
+		}
+		if !u.Noindex && entry.Notes > 0 {
+			users = append(users, entry)
+		}
+	}
+	slices.SortFunc(users, func(a, b SitemapUser) int { return strings.Compare(a.Username, b.Username) })
+	return users, nil
+}
+
+// SetUserNoindex sets whether search engines should not index the profile and notes of a user.
+func (m *MemoryDB) SetUserNoindex(userID string, noindex bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if user := m.findUser(func(u *User) bool { return u.ID == userID }); user != nil {
+		user.Noindex = noindex
+	}
+	return nil
+}
+
+// SetNoteNoindex sets whether search engines should not index a note. It returns an error wrapping
+// ErrNoRows if the user owns no such note.
+func (m *MemoryDB) SetNoteNoindex(noteID, userID string, noindex bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	note := m.findNote(func(n *Note) bool { return n.ID == noteID && n.UserID == userID })
+	if note == nil {
+		return fmt.Errorf("set note noindex: %w", ErrNoRows)
+	}
+	note.Noindex = noindex
+	return nil
+}
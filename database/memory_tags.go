@@ -0,0 +1,47 @@
+package database
+
+import (
+	"cmp"
+	"slices"
+)
+
+// ListTags returns the tags of a user's notes, most used first. Deleted notes are not counted, and
+// neither are notes that are not public if deployedOnly is set, which counts the tags readers see.
+func (m *MemoryDB) ListTags(userID string, deployedOnly bool) ([]Tag, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[string]int64)
+	for _, n := range m.notes {
+		if n.UserID != userID || n.DeletedAt != "" || (deployedOnly && n.Visibility != VisibilityPublic) {
+			continue
+		}
+		for _, tag := range noteTags(n, deployedOnly) {
+			counts[tag]++
+		}
+	}
+
+	tags := []Tag{}
+	for name, count := range counts {
+		tags = append(tags, Tag{Name: name, Notes: count})
+	}
+	slices.SortFunc(tags, func(a, b Tag) int {
+		return cmp.Or(cmp.Compare(b.Notes, a.Notes), cmp.Compare(a.Name, b.Name))
+	})
+	return tags, nil
+}
+
+// noteTags returns the tags of n, the ones its readers see if public is set: the tags of the
+// published body of notes deployed as a snapshot, like the public_tags column.
+func noteTags(n *Note, public bool) []string {
+	if public && n.PublishMode == PublishSnapshot {
+		return ExtractTags(n.PublishedBody)
+	}
+	return ExtractTags(n.Body)
+}
+
+// hasTag reports whether n has the tag of opts, as its readers see it if public is set, or
+// whether opts has no tag.
+func (opts ListOptions) hasTag(n *Note, public bool) bool {
+	return opts.Tag == "" || slices.Contains(noteTags(n, public), opts.Tag)
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestMemoryPublicTagsOfSnapshots(t *testing.T) {
+	m := Memory("")
+	note := Note{Source: "apple-notes", SourceIdentifier: "x1", Title: "Hello", Body: "<div>#public</div>",
+		CreatedAt: "2026-01-01T00:00:00Z", UpdatedAt: "2026-01-01T00:00:00Z"}
+	results, err := m.InsertNotesForUser("alice", []Note{note}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.PublishNote(results[0].ID, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a tag added to the working copy after publishing
+	note.Body, note.UpdatedAt = "<div>#public #topsecret</div>", "2026-02-01T00:00:00Z"
+	if _, err := m.InsertNotesForUser("alice", []Note{note}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err := m.ListTags("alice", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0].Name != "public" {
+		t.Errorf("got public tags %v, want only public", tags)
+	}
+	if tags, _ := m.ListTags("alice", false); len(tags) != 2 {
+		t.Errorf("got tags %v, want public and topsecret for the owner", tags)
+	}
+
+	if notes, _ := m.ListDeployedNotes("alice", ListOptions{Tag: "topsecret"}); len(notes) != 0 {
+		t.Errorf("got %d deployed notes tagged topsecret, want none", len(notes))
+	}
+	if notes, _ := m.ListDeployedNotes("alice", ListOptions{Tag: "public"}); len(notes) != 1 {
+		t.Errorf("got %d deployed notes tagged public, want 1", len(notes))
+	}
+	if notes, _ := m.ListNotes("alice", ListOptions{Tag: "topsecret"}); len(notes) != 1 {
+		t.Errorf("got %d notes tagged topsecret for the owner, want 1", len(notes))
+	}
+}
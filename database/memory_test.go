@@ -0,0 +1,178 @@
+package database
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryInsertNoteUpserts(t *testing.T) {
+	m := Memory("")
+
+	first := &Note{UserID: "u1", Source: "apple-notes", SourceIdentifier: "x1", Title: "Hello", Body: "one"}
+	if err := m.InsertNote(first); err != nil {
+		t.Fatal(err)
+	}
+	second := &Note{UserID: "u1", Source: "apple-notes", SourceIdentifier: "x1", Title: "Hello", Body: "two"}
+	if err := m.InsertNote(second); err != nil {
+		t.Fatal(err)
+	}
+	if second.ID != first.ID {
+		t.Errorf("upsert got id %s, want %s", second.ID, first.ID)
+	}
+	if count, _ := m.CountNotes("u1"); count != 1 {
+		t.Errorf("got %d notes, want 1", count)
+	}
+	got, err := m.GetNoteByID(first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Body != "two" {
+		t.Errorf("got body %q, want %q", got.Body, "two")
+	}
+
+	// another user or another source is another note
+	for _, note := range []*Note{
+		{UserID: "u2", Source: "apple-notes", SourceIdentifier: "x1", Title: "Hello"},
+		{UserID: "u1", Source: "other", SourceIdentifier: "x1", Title: "Hello"},
+	} {
+		if err := m.InsertNote(note); err != nil {
+			t.Fatal(err)
+		}
+		if note.ID == first.ID {
+			t.Errorf("note of %s from %s replaced the note of u1 from apple-notes", note.UserID, note.Source)
+		}
+	}
+}
+
+func TestMemoryInsertNoteUniqueSlugs(t *testing.T) {
+	m := Memory("")
+
+	slugs := make(map[string]bool)
+	for _, id := range []string{"x1", "x2", "x3"} {
+		note := &Note{UserID: "u1", Source: "apple-notes", SourceIdentifier: id, Title: "Hello World"}
+		if err := m.InsertNote(note); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(note.Slug, "hello-world") {
+			t.Errorf("got slug %q, want it to start with hello-world", note.Slug)
+		}
+		if slugs[note.Slug] {
+			t.Errorf("slug %q used twice", note.Slug)
+		}
+		slugs[note.Slug] = true
+	}
+	if !slugs["hello-world"] {
+		t.Errorf("got slugs %v, want the first one without a suffix", slugs)
+	}
+
+	// slugs are only unique per user
+	note := &Note{UserID: "u2", Source: "apple-notes", SourceIdentifier: "x1", Title: "Hello World"}
+	if err := m.InsertNote(note); err != nil {
+		t.Fatal(err)
+	}
+	if note.Slug != "hello-world" {
+		t.Errorf("got slug %q for another user, want hello-world", note.Slug)
+	}
+}
+
+func TestMemoryInsertNotesForUser(t *testing.T) {
+	m := Memory("")
+	sync := func(notes []Note, live map[string][]string) []SyncResult {
+		t.Helper()
+		results, err := m.InsertNotesForUser("u1", notes, live)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+	statuses := func(results []SyncResult) []SyncStatus {
+		var s []SyncStatus
+		for _, r := range results {
+			s = append(s, r.Status)
+		}
+		return s
+	}
+	note := func(id, body string) Note {
+		return Note{Source: "apple-notes", SourceIdentifier: id, Title: id, Body: body,
+			CreatedAt: "2026-01-01T00:00:00Z", UpdatedAt: "2026-01-02T00:00:00Z"}
+	}
+
+	results := sync([]Note{note("x1", "a"), note("x2", "b"), {Source: "apple-notes"}}, nil)
+	if got, want := statuses(results), []SyncStatus{SyncInserted, SyncInserted, SyncFailed}; !slices.Equal(got, want) {
+		t.Fatalf("first sync got %v, want %v", got, want)
+	}
+	if results[2].Reason != "missing source_identifier" {
+		t.Errorf("got reason %q, want missing source_identifier", results[2].Reason)
+	}
+
+	changed := note("x2", "c")
+	changed.UpdatedAt = "2026-01-03T00:00:00Z"
+	results = sync([]Note{note("x1", "a"), changed}, map[string][]string{"apple-notes": {"x1", "x2"}})
+	if got, want := statuses(results), []SyncStatus{SyncUnchanged, SyncUpdated}; !slices.Equal(got, want) {
+		t.Fatalf("second sync got %v, want %v", got, want)
+	}
+
+	results = sync([]Note{note("x1", "a")}, map[string][]string{"apple-notes": {"x1"}})
+	if got, want := statuses(results), []SyncStatus{SyncUnchanged, SyncDeleted}; !slices.Equal(got, want) {
+		t.Fatalf("third sync got %v, want %v", got, want)
+	}
+	if results[1].SourceIdentifier != "x2" {
+		t.Errorf("got %s deleted, want x2", results[1].SourceIdentifier)
+	}
+	if deleted, err := m.GetNoteByID(results[1].ID); err != nil || deleted.DeletedAt == "" {
+		t.Errorf("got note %v (%v), want x2 marked as deleted", deleted, err)
+	}
+	listed, _ := m.ListNotes("u1", ListOptions{})
+	if len(listed) != 1 || listed[0].SourceIdentifier != "x1" {
+		t.Errorf("got %d listed notes, want only x1", len(listed))
+	}
+	if identifiers, _ := m.GetSourceIdentifiersByUserID("u1"); !slices.Equal(identifiers, []string{"x1"}) {
+		t.Errorf("got source identifiers %v, want only x1", identifiers)
+	}
+}
+
+func TestMemoryGetActivities(t *testing.T) {
+	m := Memory("")
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, desc := range []string{"first", "second", "third", "fourth"} {
+		err := m.InsertActivity(&Activity{UserID: "u1", Type: "test", Description: desc,
+			Timestamp: start.Add(time.Duration(i) * time.Hour).Format(time.RFC3339Nano)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.InsertActivity(&Activity{UserID: "u2", Type: "test", Description: "other"}); err != nil {
+		t.Fatal(err)
+	}
+	descriptions := func(activities []Activity) []string {
+		var s []string
+		for _, a := range activities {
+			s = append(s, a.Description)
+		}
+		return s
+	}
+
+	for _, tt := range []struct {
+		name          string
+		loadTime      time.Time
+		offset, limit int
+		want          []string
+	}{
+		{"newest first", time.Time{}, 0, 10, []string{"fourth", "third", "second", "first"}},
+		{"page", time.Time{}, 1, 2, []string{"third", "second"}},
+		{"past the end", time.Time{}, 4, 10, nil},
+		{"before load time", start.Add(2 * time.Hour), 0, 10, []string{"second", "first"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			activities, err := m.GetActivities("u1", tt.loadTime, tt.offset, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := descriptions(activities); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
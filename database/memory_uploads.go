@@ -0,0 +1,111 @@
+package database
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// findUpload returns an upload of a user that has not expired. The caller must hold the lock.
+func (m *MemoryDB) findUpload(uploadID, userID string) *Upload {
+	for _, u := range m.uploads {
+		if u.ID == uploadID && u.UserID == userID && compareTimestamps(u.ExpiresAt, now()) > 0 {
+			return u
+		}
+	}
+	return nil
+}
+
+// CreateUpload starts a chunked upload for upload.UserID, with upload.Live and upload.ExpiresAt
+// set. The ID and created_at fields are populated by the database.
+func (m *MemoryDB) CreateUpload(upload *Upload) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	upload.ID = newID()
+	upload.CreatedAt = now()
+	upload.Chunks = []int{}
+	cp := *upload
+	m.uploads = append(m.uploads, &cp)
+	m.chunks[upload.ID] = make(map[int][]Note)
+	return nil
+}
+
+// GetUpload returns an upload of a user that has not expired, with the sequence numbers of its
+// received chunks.
+func (m *MemoryDB) GetUpload(uploadID, userID string) (*Upload, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	upload := m.findUpload(uploadID, userID)
+	if upload == nil {
+		return nil, fmt.Errorf("get upload: %w", ErrNoRows)
+	}
+	cp := *upload
+	cp.Chunks = slices.Sorted(maps.Keys(m.chunks[uploadID]))
+	if cp.Chunks == nil {
+		cp.Chunks = []int{}
+	}
+	return &cp, nil
+}
+
+// PutUploadChunk stores the notes of chunk seq of an upload, replacing the chunk if it was already
+// received.
+func (m *MemoryDB) PutUploadChunk(uploadID, userID string, seq int, notes []Note) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.findUpload(uploadID, userID) == nil {
+		return fmt.Errorf("put upload chunk: %w", ErrNoRows)
+	}
+	m.chunks[uploadID][seq] = slices.Clone(notes)
+	return nil
+}
+
+// GetUploadNotes returns the notes of every received chunk of an upload, in chunk order.
+func (m *MemoryDB) GetUploadNotes(uploadID, userID string) ([]Note, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.findUpload(uploadID, userID) == nil {
+		return nil, fmt.Errorf("get upload notes: %w", ErrNoRows)
+	}
+	var notes []Note
+	for _, seq := range slices.Sorted(maps.Keys(m.chunks[uploadID])) {
+		notes = append(notes, m.chunks[uploadID][seq]...)
+	}
+	return notes, nil
+}
+
+// DeleteUpload deletes an upload of a user and its chunks.
+func (m *MemoryDB) DeleteUpload(uploadID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.uploads = slices.DeleteFunc(m.uploads, func(u *Upload) bool {
+		if u.ID != uploadID || u.UserID != userID {
+			return false
+		}
+		delete(m.chunks, u.ID)
+		return true
+	})
+	return nil
+}
+
+// PurgeExpiredUploads deletes the uploads that expired before expiredBefore, with their chunks.
+// It returns how many uploads were deleted.
+func (m *MemoryDB) PurgeExpiredUploads(expiredBefore time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := len(m.uploads)
+	m.uploads = slices.DeleteFunc(m.uploads, func(u *Upload) bool {
+		if compareTimestamps(u.ExpiresAt, expiredBefore.Format(time.RFC3339Nano)) >= 0 {
+			return false
+		}
+		delete(m.chunks, u.ID)
+		return true
+	})
+	return int64(before - len(m.uploads)), nil
+}
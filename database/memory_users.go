@@ -0,0 +1,165 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+)
+
+// getting stuff
+
+// findUser returns the first user matching f. The caller must hold the lock.
+func (m *MemoryDB) findUser(f func(u *User) bool) *User {
+	for _, u := range m.users {
+		if f(u) {
+			return u
+		}
+	}
+	return nil
+}
+
+// GetUserIDByUsername retrieves the user ID by their username.
+func (m *MemoryDB) GetUserIDByUsername(username string) (string, error) {
+	user, err := m.GetUserByUsername(username)
+	if err != nil {
+		return "", err
+	}
+	return user.ID, nil
+}
+
+// GetUserByID retrieves a user by their ID.
+func (m *MemoryDB) GetUserByID(userID string) (*User, error) {
+	return m.getUser(func(u *User) bool { return u.ID == userID })
+}
+
+// GetUserByEmail retrieves a user by their email address.
+func (m *MemoryDB) GetUserByEmail(email string) (*User, error) {
+	return m.getUser(func(u *User) bool { return u.Email == email })
+}
+
+// GetUserByUsername retrieves a user by their username.
+func (m *MemoryDB) GetUserByUsername(username string) (*User, error) {
+	return m.getUser(func(u *User) bool { return u.Username == username })
+}
+
+func (m *MemoryDB) getUser(f func(u *User) bool) (*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user := m.findUser(f)
+	if user == nil {
+		return nil, ErrNoRows
+	}
+	cp := *user
+	return &cp, nil
+}
+
+// UsernameExists checks if a username already exists in the database.
+func (m *MemoryDB) UsernameExists(username string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.findUser(func(u *User) bool { return u.Username == username }) != nil, nil
+}
+
+// profile stuff
+
+// updateUser applies f to the user with the given ID. Like an UPDATE statement, it is not an
+// error if no user matches.
+func (m *MemoryDB) updateUser(userID string, f func(u *User)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if user := m.findUser(func(u *User) bool { return u.ID == userID }); user != nil {
+		f(user)
+	}
+	return nil
+}
+
+// UpdateName updates the name of a user in the database. It expects the user to have the new name set.
+func (m *MemoryDB) UpdateName(user *User) error {
+	return m.updateUser(user.ID, func(u *User) { u.Name = user.Name })
+}
+
+// UpdateUserDescription updates the description of a user in the database. It expects the user
+// to have the new description set.
+func (m *MemoryDB) UpdateUserDescription(user *User) error {
+	return m.updateUser(user.ID, func(u *User) { u.Description = user.Description })
+}
+
+// UpdateUserProfilePictureURL updates the profile picture URL of a user in the database.
+// It expects the user to have the new profile picture URL set.
+func (m *MemoryDB) UpdateUserProfilePictureURL(user *User) error {
+	return m.updateUser(user.ID, func(u *User) { u.ProfilePictureURL = user.ProfilePictureURL })
+}
+
+func (m *MemoryDB) UpdateUserSocials(user *User) error {
+	return m.updateUser(user.ID, func(u *User) {
+		u.TwitterUsername = user.TwitterUsername
+		u.InstagramUsername = user.InstagramUsername
+		u.GithubUsername = user.GithubUsername
+	})
+}
+
+func (m *MemoryDB) SetHasConnectedClient(userID string, hasConnected bool) error {
+	return m.updateUser(userID, func(u *User) { u.HasConnectedClient = hasConnected })
+}
+
+// SaveProfilePicture keeps the profile picture in memory and returns its URL.
+func (m *MemoryDB) SaveProfilePicture(file io.Reader, name string) (string, error) {
+	ext := filepath.Ext(name)
+	if ext != ".png" && ext != ".jpg" && ext != ".jpeg" {
+		return "", fmt.Errorf("unsupported file type: %s", ext)
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, file); err != nil {
+		return "", fmt.Errorf("save profile picture: %w", err)
+	}
+
+	filename := newID() + ext
+	m.mu.Lock()
+	m.pfps[filename] = buf.Bytes()
+	m.mu.Unlock()
+
+	return m.pfpsURL + "/" + filename, nil
+}
+
+// GetProfilePicture returns the contents and content type of a profile picture saved with
+// SaveProfilePicture.
+func (m *MemoryDB) GetProfilePicture(filename string) ([]byte, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data, ok := m.pfps[filename]
+	if !ok {
+		return nil, "", fmt.Errorf("get profile picture: %w", ErrNoRows)
+	}
+	return data, mime.TypeByExtension(filepath.Ext(filename)), nil
+}
+
+// insert stuff
+
+// InsertUser inserts a new user into the database and populated the given user with its ID.
+func (m *MemoryDB) InsertUser(user *User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// same constraint names as the supabase schema so api error patterns match
+	if m.findUser(func(u *User) bool { return u.Email == user.Email }) != nil {
+		return fmt.Errorf("duplicate key value violates unique constraint \"users_email_address_key\"")
+	}
+	if m.findUser(func(u *User) bool { return u.Username == user.Username }) != nil {
+		return fmt.Errorf("duplicate key value violates unique constraint \"users_username_key\"")
+	}
+
+	cp := *user
+	cp.ID = newID()
+	cp.CreatedAt = now()
+	cp.NotesSort = SortUpdated
+	m.users = append(m.users, &cp)
+
+	*user = cp
+	return nil
+}
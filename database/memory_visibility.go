@@ -0,0 +1,51 @@
+package database
+
+import (
+	"fmt"
+)
+
+// setDeployed deploys or undeploys a note and keeps its visibility in line, like the
+// set_note_visibility trigger: undeployed notes are private, and private notes that are deployed
+// become public.
+func setDeployed(n *Note, deployed bool) {
+	n.Deployed = deployed
+	switch {
+	case !deployed:
+		n.Visibility = VisibilityPrivate
+	case !ValidVisibility(n.Visibility) || n.Visibility == VisibilityPrivate:
+		n.Visibility = VisibilityPublic
+	}
+}
+
+// SetNoteVisibility sets the visibility of a note, deploying it live if it was private and
+// undeploying it if it becomes private. If passwordHash is not empty, it replaces the password of
+// the note. It returns an error wrapping ErrNoRows if the user owns no such note.
+func (m *MemoryDB) SetNoteVisibility(noteID, userID, visibility, passwordHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	note := m.findNote(func(n *Note) bool { return n.ID == noteID && n.UserID == userID })
+	if note == nil {
+		return fmt.Errorf("set note visibility: %w", ErrNoRows)
+	}
+	if passwordHash != "" {
+		m.passwords[note.ID] = passwordHash
+	}
+	if !note.Deployed {
+		note.PublishMode = PublishLive
+	}
+	note.Visibility = visibility
+	note.Deployed = visibility != VisibilityPrivate
+	return nil
+}
+
+// GetNotePasswordHash returns the hash of the password of a note, empty if it never had one.
+func (m *MemoryDB) GetNotePasswordHash(noteID string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.findNote(func(n *Note) bool { return n.ID == noteID }) == nil {
+		return "", fmt.Errorf("get note password hash: %w", ErrNoRows)
+	}
+	return m.passwords[noteID], nil
+}
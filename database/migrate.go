@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// migrations holds the versioned schema. Files are named NNNN_description.sql and are applied in
+// order of their version number, each in its own transaction. Applied migrations must never be
+// edited, add a new one instead.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationLockID is the postgres advisory lock key held while migrating, so that several backend
+// instances starting at once do not apply the same migration twice.
+const migrationLockID = 0x7765626e6f746573 // "webnotes"
+
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// loadMigrations reads the embedded migrations sorted by version.
+func loadMigrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrations, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	var ms []migration
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		rawVersion, _, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: name must be NNNN_description.sql", entry.Name())
+		}
+		version, err := strconv.Atoi(rawVersion)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: bad version: %w", entry.Name(), err)
+		}
+
+		b, err := migrations.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), err)
+		}
+		ms = append(ms, migration{version: version, name: name, sql: string(b)})
+	}
+
+	slices.SortFunc(ms, func(a, b migration) int { return a.version - b.version })
+	for i := 1; i < len(ms); i++ {
+		if ms[i].version == ms[i-1].version {
+			return nil, fmt.Errorf("duplicate migration version %d", ms[i].version)
+		}
+	}
+	return ms, nil
+}
+
+// LatestSchemaVersion returns the version of the newest embedded migration, the schema version
+// the store implementations expect.
+func LatestSchemaVersion() (int, error) {
+	ms, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if len(ms) == 0 {
+		return 0, nil
+	}
+	return ms[len(ms)-1].version, nil
+}
+
+// SchemaVersion returns the version of the newest migration recorded in the schema_migrations
+// table of the supabase database. The supabase driver cannot apply migrations through postgrest,
+// so they are applied with a direct connection (see the README) and only checked here.
+func (db *DB) SchemaVersion() (int, error) {
+	var rows []struct {
+		Version int `json:"version"`
+	}
+	_, err := db.client.From("schema_migrations").Select("version", "", false).Order("version", &postgrest.OrderOpts{
+		Ascending: false,
+	}).Limit(1, "").ExecuteTo(&rows)
+	if err != nil {
+		return 0, fmt.Errorf("get schema version: %w", err)
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	return rows[0].Version, nil
+}
+
+// Migrate applies all migrations that have not been applied to the database yet. It returns the
+// names of the migrations it applied.
+func (db *PostgresDB) Migrate() ([]string, error) {
+	ms, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	conn, err := db.pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "select pg_advisory_lock($1)", migrationLockID); err != nil {
+		return nil, fmt.Errorf("lock migrations: %w", err)
+	}
+	defer conn.Exec(ctx, "select pg_advisory_unlock($1)", migrationLockID)
+
+	_, err = conn.Exec(ctx, `create table if not exists schema_migrations (
+		version    integer primary key,
+		name       text not null,
+		applied_at timestamptz not null default now()
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var current int
+	err = conn.QueryRow(ctx, "select coalesce(max(version), 0) from schema_migrations").Scan(&current)
+	if err != nil {
+		return nil, fmt.Errorf("get schema version: %w", err)
+	}
+
+	var applied []string
+	for _, m := range ms {
+		if m.version <= current {
+			continue
+		}
+
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return applied, fmt.Errorf("begin migration %s: %w", m.name, err)
+		}
+		if _, err := tx.Exec(ctx, m.sql); err != nil {
+			tx.Rollback(ctx)
+			return applied, fmt.Errorf("apply migration %s: %w", m.name, err)
+		}
+		_, err = tx.Exec(ctx, "insert into schema_migrations (version, name) values ($1, $2)", m.version, m.name)
+		if err != nil {
+			tx.Rollback(ctx)
+			return applied, fmt.Errorf("record migration %s: %w", m.name, err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return applied, fmt.Errorf("commit migration %s: %w", m.name, err)
+		}
+		applied = append(applied, m.name)
+	}
+
+	return applied, nil
+}
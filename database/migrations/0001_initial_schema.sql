@@ -0,0 +1,72 @@
+-- initial schema, matching the tables, constraints and rpc functions of the supabase project
+
+create extension if not exists pgcrypto; -- gen_random_uuid() on postgres < 13
+
+create table users (
+    id                   uuid primary key default gen_random_uuid(),
+    created_at           timestamptz not null default now(),
+    username             text not null,
+    email_address        text not null,
+    password_b64_hash    text not null,
+    name                 text not null default '',
+    description          text not null default '',
+    profile_picture_url  text not null default '',
+    twitter_username     text not null default '',
+    instagram_username   text not null default '',
+    github_username      text not null default '',
+    has_connected_client boolean not null default false,
+
+    constraint users_username_key unique (username),
+    constraint users_email_address_key unique (email_address)
+);
+
+create table notes (
+    id                uuid primary key default gen_random_uuid(),
+    user_id           uuid not null references users (id) on delete cascade,
+    source            text not null default '',
+    source_identifier text not null,
+    created_at        timestamptz not null default now(),
+    updated_at        timestamptz not null default now(),
+    inserted_at       timestamptz not null default now(),
+    title             text not null default '',
+    slug              text not null,
+    body              text not null default '',
+    deployed          boolean not null default false,
+    views             bigint not null default 0,
+
+    constraint notes_user_id_source_identifier_key unique (user_id, source_identifier),
+    constraint unique_user_slug unique (user_id, slug)
+);
+
+create table activities (
+    id          uuid primary key default gen_random_uuid(),
+    user_id     uuid not null references users (id) on delete cascade,
+    type        text not null,
+    description text not null default '',
+    timestamp   timestamptz not null default now()
+);
+
+create index activities_user_id_timestamp_idx on activities (user_id, timestamp desc);
+
+-- profile pictures are kept in the database when not using supabase storage
+create table profile_pictures (
+    filename     text primary key,
+    content_type text not null,
+    data         bytea not null,
+    created_at   timestamptz not null default now()
+);
+
+create function increment_notes_view_counter(row_id uuid) returns void
+language sql as $$
+    update notes set views = views + 1 where id = row_id;
+$$;
+
+create function get_user_stats(uid uuid)
+returns table (total_views bigint, total_notes bigint, deployed_notes bigint)
+language sql stable as $$
+    select coalesce(sum(views), 0)::bigint,
+           count(*),
+           count(*) filter (where deployed)
+    from notes
+    where user_id = uid;
+$$;
@@ -0,0 +1,43 @@
+-- sync_notes upserts a batch of notes for a user in one statement and reports, for every note of
+-- the batch, whether it was inserted, updated or unchanged. payload is a json array of objects with
+-- the source, source_identifier, created_at, updated_at, title, slug and body of each note. Slugs
+-- are only used for new notes and must already be unique for the user.
+
+create function sync_notes(uid uuid, payload jsonb)
+returns table (note_id uuid, note_source_identifier text, sync_status text)
+language plpgsql as $$
+begin
+    -- one sync at a time per user
+    perform pg_advisory_xact_lock(hashtext(uid::text));
+
+    return query
+    with input as (
+        select *
+        from jsonb_to_recordset(payload) as x (
+            source text, source_identifier text, created_at timestamptz, updated_at timestamptz,
+            title text, slug text, body text
+        )
+    ), upserted as (
+        insert into notes as n (user_id, source, source_identifier, created_at, updated_at, title, slug, body)
+        select uid, coalesce(i.source, ''), i.source_identifier, coalesce(i.created_at, now()),
+               coalesce(i.updated_at, now()), coalesce(i.title, ''), i.slug, coalesce(i.body, '')
+        from input i
+        on conflict (user_id, source_identifier) do update set
+            source = excluded.source,
+            created_at = excluded.created_at,
+            updated_at = excluded.updated_at,
+            title = excluded.title,
+            body = excluded.body
+        where (n.source, n.created_at, n.updated_at, n.title, n.body)
+            is distinct from (excluded.source, excluded.created_at, excluded.updated_at, excluded.title, excluded.body)
+        returning n.id, n.source_identifier, case when n.xmax = 0 then 'inserted' else 'updated' end as status
+    )
+    select u.id, u.source_identifier, u.status from upserted u
+    union all
+    select n.id, n.source_identifier, 'unchanged'
+    from notes n
+    join input i on i.source_identifier = n.source_identifier
+    where n.user_id = uid
+      and not exists (select 1 from upserted u where u.source_identifier = n.source_identifier);
+end;
+$$;
@@ -0,0 +1,48 @@
+-- notes are identified by their owner, source app and identifier in that app, so that identifiers
+-- colliding across accounts or sources can never address each other's notes
+
+alter table notes drop constraint if exists notes_user_id_source_identifier_key;
+alter table notes add constraint notes_user_id_source_source_identifier_key unique (user_id, source, source_identifier);
+
+-- sync_notes now also returns the source of each note
+drop function sync_notes(uuid, jsonb);
+
+create function sync_notes(uid uuid, payload jsonb)
+returns table (note_id uuid, note_source text, note_source_identifier text, sync_status text)
+language plpgsql as $$
+begin
+    -- one sync at a time per user
+    perform pg_advisory_xact_lock(hashtext(uid::text));
+
+    return query
+    with input as (
+        select *
+        from jsonb_to_recordset(payload) as x (
+            source text, source_identifier text, created_at timestamptz, updated_at timestamptz,
+            title text, slug text, body text
+        )
+    ), upserted as (
+        insert into notes as n (user_id, source, source_identifier, created_at, updated_at, title, slug, body)
+        select uid, i.source, i.source_identifier, coalesce(i.created_at, now()),
+               coalesce(i.updated_at, now()), coalesce(i.title, ''), i.slug, coalesce(i.body, '')
+        from input i
+        on conflict (user_id, source, source_identifier) do update set
+            created_at = excluded.created_at,
+            updated_at = excluded.updated_at,
+            title = excluded.title,
+            body = excluded.body
+        where (n.created_at, n.updated_at, n.title, n.body)
+            is distinct from (excluded.created_at, excluded.updated_at, excluded.title, excluded.body)
+        returning n.id, n.source, n.source_identifier, case when n.xmax = 0 then 'inserted' else 'updated' end as status
+    )
+    select u.id, u.source, u.source_identifier, u.status from upserted u
+    union all
+    select n.id, n.source, n.source_identifier, 'unchanged'
+    from notes n
+    join input i on i.source = n.source and i.source_identifier = n.source_identifier
+    where n.user_id = uid
+      and not exists (
+          select 1 from upserted u where u.source = n.source and u.source_identifier = n.source_identifier
+      );
+end;
+$$;
@@ -0,0 +1,82 @@
+-- notes deleted in their source app are marked as deleted (and undeployed) by sync_notes, and are
+-- removed for good once their grace period is over
+
+alter table notes add column deleted_at timestamptz;
+
+create index notes_deleted_at_idx on notes (deleted_at) where deleted_at is not null;
+
+-- live is an optional json object mapping a source to the array of source identifiers of every
+-- note that still exists in it. Stored notes of those sources missing from their array are marked
+-- as deleted. Notes of sources that are not in live are left alone.
+drop function sync_notes(uuid, jsonb);
+
+create function sync_notes(uid uuid, payload jsonb, live jsonb default null)
+returns table (note_id uuid, note_source text, note_source_identifier text, sync_status text, note_undeployed boolean)
+language plpgsql as $$
+begin
+    -- one sync at a time per user
+    perform pg_advisory_xact_lock(hashtext(uid::text));
+
+    return query
+    with input as (
+        select *
+        from jsonb_to_recordset(payload) as x (
+            source text, source_identifier text, created_at timestamptz, updated_at timestamptz,
+            title text, slug text, body text
+        )
+    ), live_notes as (
+        select l.key as source, jsonb_array_elements_text(l.value) as source_identifier
+        from jsonb_each(case when jsonb_typeof(live) = 'object' then live else '{}'::jsonb end) l
+    ), upserted as (
+        insert into notes as n (user_id, source, source_identifier, created_at, updated_at, title, slug, body)
+        select uid, i.source, i.source_identifier, coalesce(i.created_at, now()),
+               coalesce(i.updated_at, now()), coalesce(i.title, ''), i.slug, coalesce(i.body, '')
+        from input i
+        on conflict (user_id, source, source_identifier) do update set
+            created_at = excluded.created_at,
+            updated_at = excluded.updated_at,
+            title = excluded.title,
+            body = excluded.body,
+            deleted_at = null -- restored in the source app
+        where (n.created_at, n.updated_at, n.title, n.body, n.deleted_at)
+            is distinct from (excluded.created_at, excluded.updated_at, excluded.title, excluded.body, excluded.deleted_at)
+        returning n.id, n.source, n.source_identifier, case when n.xmax = 0 then 'inserted' else 'updated' end as status
+    ), deleted as (
+        update notes n set deleted_at = now(), deployed = false
+        from notes o
+        where o.id = n.id
+          and n.user_id = uid
+          and n.deleted_at is null
+          and live ? n.source
+          and not exists (
+              select 1 from live_notes l where l.source = n.source and l.source_identifier = n.source_identifier
+          )
+          and not exists (
+              select 1 from input i where i.source = n.source and i.source_identifier = n.source_identifier
+          )
+        returning n.id, n.source, n.source_identifier, o.deployed as was_deployed
+    )
+    select u.id, u.source, u.source_identifier, u.status, false from upserted u
+    union all
+    select n.id, n.source, n.source_identifier, 'unchanged', false
+    from notes n
+    join input i on i.source = n.source and i.source_identifier = n.source_identifier
+    where n.user_id = uid
+      and not exists (
+          select 1 from upserted u where u.source = n.source and u.source_identifier = n.source_identifier
+      )
+    union all
+    select d.id, d.source, d.source_identifier, 'deleted', d.was_deployed from deleted d;
+end;
+$$;
+
+-- deleted notes no longer count towards the stats
+create or replace function get_user_stats(uid uuid)
+returns table (total_views bigint, total_notes bigint, deployed_notes bigint)
+language sql stable as $$
+    select coalesce(sum(views), 0)::bigint,
+           count(*),
+           count(*) filter (where deployed)
+    from notes
+    where user_id = uid and deleted_at is null;
+$$;
@@ -0,0 +1,19 @@
+-- chunked syncs: the notes of a sync are uploaded in several chunks, which are synced all at once
+-- when the upload is committed. A chunk that failed to upload can be sent again on its own.
+
+create table sync_uploads (
+    id uuid primary key default gen_random_uuid(),
+    user_id uuid not null references users (id) on delete cascade,
+    live jsonb,
+    created_at timestamptz not null default now(),
+    expires_at timestamptz not null
+);
+
+create index sync_uploads_expires_at_idx on sync_uploads (expires_at);
+
+create table sync_upload_chunks (
+    upload_id uuid not null references sync_uploads (id) on delete cascade,
+    seq integer not null check (seq >= 0),
+    notes jsonb not null,
+    primary key (upload_id, seq)
+);
@@ -0,0 +1,121 @@
+-- every version of a note's title and body seen by a sync is kept as a revision, so that older
+-- versions can be compared and restored
+
+create table note_revisions (
+    id            uuid primary key default gen_random_uuid(),
+    note_id       uuid not null references notes (id) on delete cascade,
+    user_id       uuid not null references users (id) on delete cascade,
+    title         text not null,
+    body          text not null,
+    updated_at    timestamptz not null,           -- updated_at of the note in its source
+    content_hash  text not null,
+    restored_from uuid references note_revisions (id) on delete set null, -- set if made by a restore
+    created_at    timestamptz not null default now()
+);
+
+create index note_revisions_note_id_created_at_idx on note_revisions (note_id, created_at desc);
+
+-- the current version of the existing notes is their first revision
+insert into note_revisions (note_id, user_id, title, body, updated_at, content_hash)
+select id, user_id, title, body, updated_at, content_hash from notes;
+
+create or replace function sync_notes(uid uuid, payload jsonb, live jsonb default null)
+returns table (note_id uuid, note_source text, note_source_identifier text, sync_status text, note_undeployed boolean)
+language plpgsql as $$
+begin
+    -- one sync at a time per user
+    perform pg_advisory_xact_lock(hashtext(uid::text));
+
+    return query
+    with input as (
+        select *
+        from jsonb_to_recordset(payload) as x (
+            source text, source_identifier text, created_at timestamptz, updated_at timestamptz,
+            title text, slug text, body text, content_hash text
+        )
+    ), live_notes as (
+        select l.key as source, jsonb_array_elements_text(l.value) as source_identifier
+        from jsonb_each(case when jsonb_typeof(live) = 'object' then live else '{}'::jsonb end) l
+    ), upserted as (
+        insert into notes as n (user_id, source, source_identifier, created_at, updated_at, title, slug, body, content_hash)
+        select uid, i.source, i.source_identifier, coalesce(i.created_at, now()),
+               coalesce(i.updated_at, now()), coalesce(i.title, ''), i.slug, coalesce(i.body, ''), i.content_hash
+        from input i
+        on conflict (user_id, source, source_identifier) do update set
+            created_at = excluded.created_at,
+            updated_at = excluded.updated_at,
+            title = excluded.title,
+            body = excluded.body,
+            content_hash = excluded.content_hash,
+            deleted_at = null -- restored in the source app
+        where (n.created_at, n.updated_at, n.content_hash, n.deleted_at)
+            is distinct from (excluded.created_at, excluded.updated_at, excluded.content_hash, excluded.deleted_at)
+        returning n.id, n.source, n.source_identifier, n.title, n.body, n.updated_at, n.content_hash,
+                  case when n.xmax = 0 then 'inserted' else 'updated' end as status
+    ), revised as (
+        -- notes reads the rows as they were before the upsert
+        insert into note_revisions (note_id, user_id, title, body, updated_at, content_hash)
+        select u.id, uid, u.title, u.body, u.updated_at, u.content_hash
+        from upserted u
+        left join notes p on p.id = u.id
+        where p.id is null or p.content_hash is distinct from u.content_hash
+    ), deleted as (
+        update notes n set deleted_at = now(), deployed = false
+        from notes o
+        where o.id = n.id
+          and n.user_id = uid
+          and n.deleted_at is null
+          and live ? n.source
+          and not exists (
+              select 1 from live_notes l where l.source = n.source and l.source_identifier = n.source_identifier
+          )
+          and not exists (
+              select 1 from input i where i.source = n.source and i.source_identifier = n.source_identifier
+          )
+        returning n.id, n.source, n.source_identifier, o.deployed as was_deployed
+    )
+    select u.id, u.source, u.source_identifier, u.status, false from upserted u
+    union all
+    select n.id, n.source, n.source_identifier, 'unchanged', false
+    from notes n
+    join input i on i.source = n.source and i.source_identifier = n.source_identifier
+    where n.user_id = uid
+      and not exists (
+          select 1 from upserted u where u.source = n.source and u.source_identifier = n.source_identifier
+      )
+    union all
+    select d.id, d.source, d.source_identifier, 'deleted', d.was_deployed from deleted d;
+end;
+$$;
+
+-- restore_note_revision makes the title and body of a revision the current ones of its note, and
+-- records that as a new revision, which it returns the id of. It returns null if the user has no
+-- such revision or the note was deleted. The content hash of the note is left alone: it is the
+-- hash of what the source last sent, so the restored version stays until the note is edited there.
+create function restore_note_revision(uid uuid, nid uuid, rid uuid)
+returns uuid
+language plpgsql as $$
+declare
+    rev note_revisions;
+    restored uuid;
+begin
+    -- same lock as sync_notes, so a restore does not interleave with a sync of the note
+    perform pg_advisory_xact_lock(hashtext(uid::text));
+
+    select * into rev from note_revisions r where r.id = rid and r.note_id = nid and r.user_id = uid;
+    if not found then
+        return null;
+    end if;
+
+    update notes n set title = rev.title, body = rev.body
+    where n.id = nid and n.user_id = uid and n.deleted_at is null;
+    if not found then
+        return null;
+    end if;
+
+    insert into note_revisions (note_id, user_id, title, body, updated_at, content_hash, restored_from)
+    values (nid, uid, rev.title, rev.body, now(), rev.content_hash, rid)
+    returning id into restored;
+    return restored;
+end;
+$$;
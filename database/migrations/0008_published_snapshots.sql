@@ -0,0 +1,60 @@
+-- notes can be deployed as a snapshot: readers see the title and body the note had when it was
+-- published, while syncs keep updating the private working copy until the owner republishes it
+
+alter table notes
+    add column publish_mode text not null default 'live' check (publish_mode in ('live', 'snapshot')),
+    add column published_title text not null default '',
+    add column published_body text not null default '',
+    add column published_at timestamptz;
+
+-- publish_note deploys a note as a snapshot of its current title and body, or replaces the
+-- snapshot of a note already deployed that way. It returns whether the user has such a note.
+create function publish_note(uid uuid, nid uuid)
+returns boolean
+language plpgsql as $$
+begin
+    update notes n set
+        deployed = true,
+        publish_mode = 'snapshot',
+        published_title = n.title,
+        published_body = n.body,
+        published_at = now()
+    where n.id = nid and n.user_id = uid and n.deleted_at is null;
+    return found;
+end;
+$$;
+
+-- restoring a revision of a note deployed as a snapshot replaces the snapshot, so readers see the
+-- restored version straight away; the working copy is left alone
+create or replace function restore_note_revision(uid uuid, nid uuid, rid uuid)
+returns uuid
+language plpgsql as $$
+declare
+    rev note_revisions;
+    restored uuid;
+begin
+    -- same lock as sync_notes, so a restore does not interleave with a sync of the note
+    perform pg_advisory_xact_lock(hashtext(uid::text));
+
+    select * into rev from note_revisions r where r.id = rid and r.note_id = nid and r.user_id = uid;
+    if not found then
+        return null;
+    end if;
+
+    update notes n set
+        title = case when n.deployed and n.publish_mode = 'snapshot' then n.title else rev.title end,
+        body = case when n.deployed and n.publish_mode = 'snapshot' then n.body else rev.body end,
+        published_title = case when n.deployed and n.publish_mode = 'snapshot' then rev.title else n.published_title end,
+        published_body = case when n.deployed and n.publish_mode = 'snapshot' then rev.body else n.published_body end,
+        published_at = case when n.deployed and n.publish_mode = 'snapshot' then now() else n.published_at end
+    where n.id = nid and n.user_id = uid and n.deleted_at is null;
+    if not found then
+        return null;
+    end if;
+
+    insert into note_revisions (note_id, user_id, title, body, updated_at, content_hash, restored_from)
+    values (nid, uid, rev.title, rev.body, now(), rev.content_hash, rid)
+    returning id into restored;
+    return restored;
+end;
+$$;
@@ -0,0 +1,79 @@
+-- full-text search over the title and body of notes. The search vectors are generated columns, so
+-- they are kept up to date by every write, including syncs. Notes deployed as a snapshot have a
+-- second vector for their published version, which is what public searches look at.
+
+-- strip_html returns the text of an html body, with entities other than &nbsp; left encoded so
+-- that it can still be shown as html. It must match stripHTML in the database package.
+create function strip_html(html text)
+returns text
+language sql immutable parallel safe as $$
+    select replace(
+        regexp_replace(
+            regexp_replace(html, '<(br|/p|/div|/li|/h[1-6])(\s[^>]*)?/?>', ' ', 'gi'),
+            '<[^>]*>', '', 'g'
+        ),
+        '&nbsp;', ' '
+    );
+$$;
+
+alter table notes
+    add column search_vector tsvector generated always as (
+        setweight(to_tsvector('simple', title), 'A') || setweight(to_tsvector('simple', strip_html(body)), 'B')
+    ) stored,
+    add column published_search_vector tsvector generated always as (
+        setweight(to_tsvector('simple', published_title), 'A') || setweight(to_tsvector('simple', strip_html(published_body)), 'B')
+    ) stored;
+
+create index notes_search_vector_idx on notes using gin (search_vector);
+create index notes_published_search_vector_idx on notes using gin (published_search_vector)
+    where publish_mode = 'snapshot';
+
+-- search_notes returns the notes of a user matching a web search style query (words, "phrases",
+-- or, -excluded), best first, with the matches highlighted with <mark> in the title and in
+-- snippets of the body. With for_public set, only deployed notes are searched, as their readers
+-- see them. The other filters are optional.
+create function search_notes(
+    uid uuid,
+    query text,
+    for_public boolean default false,
+    only_deployed boolean default null,
+    updated_after timestamptz default null,
+    updated_before timestamptz default null,
+    page_limit integer default 20,
+    page_offset integer default 0
+)
+returns table (
+    id uuid, title text, slug text, deployed boolean, updated_at timestamptz,
+    rank real, title_highlight text, snippet text
+)
+language sql stable as $$
+    with q as (
+        select websearch_to_tsquery('simple', query) as tsq
+    ), matches as (
+        select n.id, n.slug, n.deployed,
+               case when p.published then n.published_title else n.title end as title,
+               case when p.published then n.published_body else n.body end as body,
+               case when p.published then n.published_at else n.updated_at end as updated_at,
+               case when p.published then n.published_search_vector else n.search_vector end as vector
+        from notes n
+        cross join q
+        cross join lateral (select for_public and n.publish_mode = 'snapshot' as published) p
+        where n.user_id = uid
+          and n.deleted_at is null
+          and (not for_public or n.deployed)
+          and (only_deployed is null or n.deployed = only_deployed)
+          and ((p.published and n.published_search_vector @@ q.tsq) or (not p.published and n.search_vector @@ q.tsq))
+    )
+    select m.id, m.title, m.slug, m.deployed, m.updated_at,
+           ts_rank_cd(m.vector, q.tsq),
+           ts_headline('simple', replace(replace(replace(m.title, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), q.tsq,
+                       'HighlightAll=true, StartSel=<mark>, StopSel=</mark>'),
+           ts_headline('simple', strip_html(m.body), q.tsq,
+                       'StartSel=<mark>, StopSel=</mark>, MinWords=15, MaxWords=35, MaxFragments=2, FragmentDelimiter=" … "')
+    from matches m
+    cross join q
+    where (updated_after is null or m.updated_at >= updated_after)
+      and (updated_before is null or m.updated_at < updated_before)
+    order by 6 desc, m.updated_at desc, m.id
+    limit page_limit offset page_offset;
+$$;
@@ -0,0 +1,83 @@
+-- #hashtags in the body of notes are their tags. They are kept in tags and note_tags by a trigger,
+-- so every write of a body, by a sync or a restore, updates them.
+
+create table tags (
+    id      uuid primary key default gen_random_uuid(),
+    user_id uuid not null references users (id) on delete cascade,
+    name    text not null,
+
+    constraint tags_user_id_name_key unique (user_id, name)
+);
+
+create table note_tags (
+    note_id uuid not null references notes (id) on delete cascade,
+    tag_id  uuid not null references tags (id) on delete cascade,
+
+    primary key (note_id, tag_id)
+);
+
+create index note_tags_tag_id_idx on note_tags (tag_id);
+
+-- note_hashtags returns the lower case tags of an html body: words of letters, digits, _ and -
+-- after a # that does not follow a word, an & (entities), a / (links) or another #. Tags must
+-- have a letter. It must match ExtractTags in the database package.
+create function note_hashtags(html text)
+returns text[]
+language sql immutable parallel safe as $$
+    select coalesce(array_agg(distinct t.tag order by t.tag), '{}')
+    from (
+        select left(lower(rtrim(m[1], '-')), 64) as tag
+        from regexp_matches(strip_html(html), '(?:^|[^[:alnum:]_&/#])#([[:alnum:]_][[:alnum:]_-]*)', 'g') as m
+    ) t
+    where t.tag ~ '[[:alpha:]]';
+$$;
+
+-- set_note_tags makes the tags of a note the hashtags of its body, and deletes the tags of the
+-- user no note has anymore
+create function set_note_tags()
+returns trigger
+language plpgsql as $$
+declare
+    names text[] := note_hashtags(new.body);
+begin
+    insert into tags (user_id, name)
+    select new.user_id, unnest(names)
+    on conflict (user_id, name) do nothing;
+
+    delete from note_tags nt
+    using tags t
+    where nt.note_id = new.id and t.id = nt.tag_id and not t.name = any (names);
+
+    insert into note_tags (note_id, tag_id)
+    select new.id, t.id from tags t where t.user_id = new.user_id and t.name = any (names)
+    on conflict do nothing;
+
+    delete from tags t
+    where t.user_id = new.user_id
+      and not exists (select 1 from note_tags nt where nt.tag_id = t.id);
+    return null;
+end;
+$$;
+
+create trigger notes_set_tags
+after insert or update of body on notes
+for each row execute function set_note_tags();
+
+-- tag the existing notes
+update notes set body = body;
+
+-- list_tags returns the tags of a user with how many of their notes have each, except the
+-- deleted notes. With only_deployed set, only deployed notes are counted.
+create function list_tags(uid uuid, only_deployed boolean default false)
+returns table (name text, notes bigint)
+language sql stable as $$
+    select t.name, count(*)
+    from tags t
+    join note_tags nt on nt.tag_id = t.id
+    join notes n on n.id = nt.note_id
+    where t.user_id = uid
+      and n.deleted_at is null
+      and (not only_deployed or n.deployed)
+    group by t.name
+    order by count(*) desc, t.name;
+$$;
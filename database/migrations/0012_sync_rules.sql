@@ -0,0 +1,107 @@
+-- per-user sync rules, matching notes by tag or folder. Notes matched by an exclude rule are not
+-- synced. The others are deployed or undeployed by the deploy and undeploy rules they match every
+-- time they are synced; the backend works out which and sends it as rule_deploy.
+
+create table sync_rules (
+    id         uuid primary key default gen_random_uuid(),
+    user_id    uuid not null references users (id) on delete cascade,
+    action     text not null check (action in ('deploy', 'undeploy', 'exclude')),
+    match      text not null check (match in ('tag', 'folder')),
+    value      text not null,
+    created_at timestamptz not null default now(),
+
+    constraint sync_rules_user_id_action_match_value_key unique (user_id, action, match, value)
+);
+
+create or replace function sync_notes(uid uuid, payload jsonb, live jsonb default null)
+returns table (note_id uuid, note_source text, note_source_identifier text, sync_status text, note_undeployed boolean)
+language plpgsql as $$
+begin
+    -- one sync at a time per user
+    perform pg_advisory_xact_lock(hashtext(uid::text));
+
+    return query
+    with input as (
+        select *
+        from jsonb_to_recordset(payload) as x (
+            source text, source_identifier text, created_at timestamptz, updated_at timestamptz,
+            title text, slug text, body text, content_hash text, folder_id text, folder_name text
+        )
+    ), live_notes as (
+        select l.key as source, jsonb_array_elements_text(l.value) as source_identifier
+        from jsonb_each(case when jsonb_typeof(live) = 'object' then live else '{}'::jsonb end) l
+    ), upserted as (
+        insert into notes as n (user_id, source, source_identifier, created_at, updated_at, title, slug, body, content_hash,
+                                folder_id, folder_name)
+        select uid, i.source, i.source_identifier, coalesce(i.created_at, now()),
+               coalesce(i.updated_at, now()), coalesce(i.title, ''), i.slug, coalesce(i.body, ''), i.content_hash,
+               coalesce(i.folder_id, ''), coalesce(i.folder_name, '')
+        from input i
+        on conflict (user_id, source, source_identifier) do update set
+            created_at = excluded.created_at,
+            updated_at = excluded.updated_at,
+            title = excluded.title,
+            body = excluded.body,
+            content_hash = excluded.content_hash,
+            folder_id = case when excluded.folder_id = '' then n.folder_id else excluded.folder_id end,
+            folder_name = case when excluded.folder_id = '' then n.folder_name else excluded.folder_name end,
+            deleted_at = null -- restored in the source app
+        where (n.created_at, n.updated_at, n.content_hash, n.deleted_at)
+            is distinct from (excluded.created_at, excluded.updated_at, excluded.content_hash, excluded.deleted_at)
+           or (excluded.folder_id <> '' and (n.folder_id, n.folder_name) is distinct from (excluded.folder_id, excluded.folder_name))
+        returning n.id, n.source, n.source_identifier, n.title, n.body, n.updated_at, n.content_hash,
+                  case when n.xmax = 0 then 'inserted' else 'updated' end as status
+    ), revised as (
+        -- notes reads the rows as they were before the upsert
+        insert into note_revisions (note_id, user_id, title, body, updated_at, content_hash)
+        select u.id, uid, u.title, u.body, u.updated_at, u.content_hash
+        from upserted u
+        left join notes p on p.id = u.id
+        where p.id is null or p.content_hash is distinct from u.content_hash
+    ), deleted as (
+        update notes n set deleted_at = now(), deployed = false
+        from notes o
+        where o.id = n.id
+          and n.user_id = uid
+          and n.deleted_at is null
+          and live ? n.source
+          and not exists (
+              select 1 from live_notes l where l.source = n.source and l.source_identifier = n.source_identifier
+          )
+          and not exists (
+              select 1 from input i where i.source = n.source and i.source_identifier = n.source_identifier
+          )
+        returning n.id, n.source, n.source_identifier, o.deployed as was_deployed
+    )
+    select u.id, u.source, u.source_identifier, u.status, false from upserted u
+    union all
+    select n.id, n.source, n.source_identifier, 'unchanged', false
+    from notes n
+    join input i on i.source = n.source and i.source_identifier = n.source_identifier
+    where n.user_id = uid
+      and not exists (
+          select 1 from upserted u where u.source = n.source and u.source_identifier = n.source_identifier
+      )
+    union all
+    select d.id, d.source, d.source_identifier, 'deleted', d.was_deployed from deleted d;
+
+    -- then deploy or undeploy the notes as their rule_deploy says, a statement of its own so that
+    -- it sees the notes the upsert wrote
+    return query
+    with ruled as (
+        update notes n set
+            deployed = r.rule_deploy,
+            publish_mode = case when r.rule_deploy then 'live' else n.publish_mode end
+        from jsonb_to_recordset(payload) as r (source text, source_identifier text, rule_deploy boolean)
+        where n.user_id = uid
+          and n.source = r.source
+          and n.source_identifier = r.source_identifier
+          and n.deleted_at is null
+          and n.deployed <> r.rule_deploy
+        returning n.id, n.source, n.source_identifier, r.rule_deploy
+    )
+    select r.id, r.source, r.source_identifier,
+           case when r.rule_deploy then 'rule_deployed' else 'rule_undeployed' end, false
+    from ruled r;
+end;
+$$;
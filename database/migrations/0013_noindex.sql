@@ -0,0 +1,21 @@
+-- users and notes can opt out of search engines. Noindex notes, and every note of noindex users,
+-- are left out of sitemaps and served with a noindex robots directive.
+
+alter table users add column noindex boolean not null default false;
+alter table notes add column noindex boolean not null default false;
+
+-- sitemap_users returns the users with deployed notes that can be indexed, with how many there
+-- are and when the last one changed for its readers.
+create function sitemap_users()
+returns table (username text, notes bigint, last_modified timestamptz)
+language sql stable as $$
+    select u.username, count(*),
+           max(case when n.publish_mode = 'snapshot' then coalesce(n.published_at, n.updated_at) else n.updated_at end)
+    from users u
+    join notes n on n.user_id = u.id
+    where not u.noindex
+      and n.deployed
+      and not n.noindex
+    group by u.username
+    order by u.username;
+$$;
@@ -0,0 +1,9 @@
+-- rendered Open Graph images of deployed notes. cache_key identifies what an image shows, so an
+-- image whose note or author changed is rendered again.
+
+create table og_images (
+    note_id    uuid primary key references notes (id) on delete cascade,
+    cache_key  text not null,
+    data       bytea not null,
+    created_at timestamptz not null default now()
+);
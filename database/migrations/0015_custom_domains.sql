@@ -0,0 +1,13 @@
+-- custom domains users serve their profile and deployed notes on. Anyone can claim a domain, but
+-- it is only used once verified with a DNS TXT record holding its token, and only one user can
+-- have it verified.
+
+create table custom_domains (
+    user_id     uuid primary key references users (id) on delete cascade,
+    domain      text not null,
+    token       text not null,
+    verified_at timestamptz,
+    created_at  timestamptz not null default now()
+);
+
+create unique index custom_domains_verified_domain_key on custom_domains (domain) where verified_at is not null;
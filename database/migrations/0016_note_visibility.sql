@@ -0,0 +1,107 @@
+-- notes have a visibility: private (not deployed), public (deployed and listed), unlisted
+-- (deployed and readable by direct link, but left out of lists, feeds, sitemaps and searches) or
+-- protected (deployed and readable with a password, exchanged for an access token). deployed is
+-- kept as whether readers can reach a note at all, and set_note_visibility keeps both in line so
+-- that everything deploying or undeploying notes works as before: undeployed notes are private,
+-- and private notes that are deployed become public.
+
+alter table notes add column visibility text not null default 'private'
+    constraint notes_visibility_check check (visibility in ('private', 'public', 'unlisted', 'protected'));
+alter table notes add column password_hash text not null default ''; -- bcrypt hash of the password of protected notes
+
+update notes set visibility = 'public' where deployed;
+
+create function set_note_visibility()
+returns trigger
+language plpgsql as $$
+begin
+    if tg_op = 'UPDATE' and new.visibility is distinct from old.visibility then
+        new.deployed := new.visibility <> 'private';
+    elsif not new.deployed then
+        new.visibility := 'private';
+    elsif new.visibility not in ('public', 'unlisted', 'protected') then
+        new.visibility := 'public';
+    end if;
+    return new;
+end;
+$$;
+
+create trigger notes_set_visibility
+before insert or update of deployed, visibility on notes
+for each row execute function set_note_visibility();
+
+-- only public notes are listed to readers
+
+create or replace function search_notes(
+    uid uuid,
+    query text,
+    for_public boolean default false,
+    only_deployed boolean default null,
+    updated_after timestamptz default null,
+    updated_before timestamptz default null,
+    page_limit integer default 20,
+    page_offset integer default 0
+)
+returns table (
+    id uuid, title text, slug text, deployed boolean, updated_at timestamptz,
+    rank real, title_highlight text, snippet text
+)
+language sql stable as $$
+    with q as (
+        select websearch_to_tsquery('simple', query) as tsq
+    ), matches as (
+        select n.id, n.slug, n.deployed,
+               case when p.published then n.published_title else n.title end as title,
+               case when p.published then n.published_body else n.body end as body,
+               case when p.published then n.published_at else n.updated_at end as updated_at,
+               case when p.published then n.published_search_vector else n.search_vector end as vector
+        from notes n
+        cross join q
+        cross join lateral (select for_public and n.publish_mode = 'snapshot' as published) p
+        where n.user_id = uid
+          and n.deleted_at is null
+          and (not for_public or n.visibility = 'public')
+          and (only_deployed is null or n.deployed = only_deployed)
+          and ((p.published and n.published_search_vector @@ q.tsq) or (not p.published and n.search_vector @@ q.tsq))
+    )
+    select m.id, m.title, m.slug, m.deployed, m.updated_at,
+           ts_rank_cd(m.vector, q.tsq),
+           ts_headline('simple', replace(replace(replace(m.title, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), q.tsq,
+                       'HighlightAll=true, StartSel=<mark>, StopSel=</mark>'),
+           ts_headline('simple', strip_html(m.body), q.tsq,
+                       'StartSel=<mark>, StopSel=</mark>, MinWords=15, MaxWords=35, MaxFragments=2, FragmentDelimiter=" … "')
+    from matches m
+    cross join q
+    where (updated_after is null or m.updated_at >= updated_after)
+      and (updated_before is null or m.updated_at < updated_before)
+    order by 6 desc, m.updated_at desc, m.id
+    limit page_limit offset page_offset;
+$$;
+
+create or replace function list_tags(uid uuid, only_deployed boolean default false)
+returns table (name text, notes bigint)
+language sql stable as $$
+    select t.name, count(*)
+    from tags t
+    join note_tags nt on nt.tag_id = t.id
+    join notes n on n.id = nt.note_id
+    where t.user_id = uid
+      and n.deleted_at is null
+      and (not only_deployed or n.visibility = 'public')
+    group by t.name
+    order by count(*) desc, t.name;
+$$;
+
+create or replace function sitemap_users()
+returns table (username text, notes bigint, last_modified timestamptz)
+language sql stable as $$
+    select u.username, count(*),
+           max(case when n.publish_mode = 'snapshot' then coalesce(n.published_at, n.updated_at) else n.updated_at end)
+    from users u
+    join notes n on n.user_id = u.id
+    where not u.noindex
+      and n.visibility = 'public'
+      and not n.noindex
+    group by u.username
+    order by u.username;
+$$;
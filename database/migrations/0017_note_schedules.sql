@@ -0,0 +1,49 @@
+-- notes can be scheduled to be deployed at a future time (deploy_at) and to be undeployed once
+-- they expire (expires_at). The schedules are kept with the notes so that they survive restarts of
+-- the backend, whose scheduler runs the due ones with run_note_schedules.
+
+alter table notes add column deploy_at timestamptz;
+alter table notes add column expires_at timestamptz;
+
+create index notes_deploy_at_idx on notes (deploy_at) where deploy_at is not null;
+create index notes_expires_at_idx on notes (expires_at) where expires_at is not null;
+
+-- run_note_schedules deploys live the notes whose deploy_at is due and undeploys the ones whose
+-- expires_at is due, clearing the schedules it ran. Expiries win over deploys due at the same run,
+-- and deleted notes are not deployed. It returns the notes that were deployed or undeployed, with
+-- action set to 'deployed' or 'undeployed'; the rows are locked so that concurrent runs do not
+-- report the same change twice.
+create function run_note_schedules(run_until timestamptz)
+returns table (note_id uuid, user_id uuid, action text)
+language sql volatile as $$
+    with due as (
+        select n.id, n.deployed as was_deployed,
+               coalesce(n.expires_at <= run_until, false) as expired
+        from notes n
+        where n.deploy_at <= run_until or n.expires_at <= run_until
+        for update
+    ), ran as (
+        update notes n
+        set deployed = case
+                when d.expired then false
+                when n.deleted_at is not null then n.deployed
+                else true
+            end,
+            publish_mode = case when d.expired or d.was_deployed or n.deleted_at is not null then n.publish_mode else 'live' end,
+            deploy_at = null,
+            expires_at = case when d.expired then null else n.expires_at end
+        from due d
+        where n.id = d.id
+        returning n.id, n.user_id, d.was_deployed, n.deployed
+    )
+    select r.id, r.user_id, case when r.deployed then 'deployed' else 'undeployed' end
+    from ran r
+    where r.was_deployed <> r.deployed;
+$$;
+
+-- next_note_schedule returns when the next schedule of a note is due, null if there is none.
+create function next_note_schedule()
+returns timestamptz
+language sql stable as $$
+    select least((select min(deploy_at) from notes), (select min(expires_at) from notes));
+$$;
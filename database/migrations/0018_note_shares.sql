@@ -0,0 +1,30 @@
+-- share links showing a note to whoever has their token, even if it is not deployed. They expire,
+-- can be limited to a number of views and revoked. Only the sha256 of the token is stored.
+
+create table note_shares (
+    id         uuid primary key default gen_random_uuid(),
+    note_id    uuid not null references notes (id) on delete cascade,
+    user_id    uuid not null references users (id) on delete cascade,
+    token_hash text not null unique,
+    expires_at timestamptz not null,
+    max_views  integer not null default 0 check (max_views >= 0), -- 0 for no limit
+    views      integer not null default 0,
+    revoked_at timestamptz,
+    created_at timestamptz not null default now()
+);
+
+create index note_shares_note_id_idx on note_shares (note_id);
+
+-- use_note_share counts a view of the share with the given token hash and returns it, if it is
+-- not revoked, expired or out of views. It returns nothing otherwise.
+create function use_note_share(hash text)
+returns setof note_shares
+language sql volatile as $$
+    update note_shares
+    set views = views + 1
+    where token_hash = hash
+      and revoked_at is null
+      and expires_at > now()
+      and (max_views = 0 or views < max_views)
+    returning *;
+$$;
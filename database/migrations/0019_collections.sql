@@ -0,0 +1,49 @@
+-- collections are named selections of the notes of a user, in an order they choose, like series.
+-- They are public at /:username/c/:slug, where readers see their deployed public notes in order
+-- and can go from one to the next.
+
+create table collections (
+    id          uuid primary key default gen_random_uuid(),
+    user_id     uuid not null references users (id) on delete cascade,
+    slug        text not null,
+    name        text not null,
+    description text not null default '',
+    created_at  timestamptz not null default now(),
+    updated_at  timestamptz not null default now(),
+
+    constraint collections_user_id_slug_key unique (user_id, slug)
+);
+
+create table collection_notes (
+    collection_id uuid not null references collections (id) on delete cascade,
+    note_id       uuid not null references notes (id) on delete cascade,
+    position      integer not null,
+
+    primary key (collection_id, note_id)
+);
+
+create index collection_notes_note_id_idx on collection_notes (note_id);
+
+-- set_collection_notes replaces the notes of a collection of a user by note_ids, in that order.
+-- Ids of notes the user does not own, and repeated ids, are skipped. It returns whether the user
+-- owns the collection.
+create function set_collection_notes(uid uuid, cid uuid, note_ids uuid[])
+returns boolean
+language plpgsql as $$
+begin
+    perform 1 from collections where id = cid and user_id = uid for update;
+    if not found then
+        return false;
+    end if;
+
+    delete from collection_notes where collection_id = cid;
+    insert into collection_notes (collection_id, note_id, position)
+    select cid, ids.note_id, min(ids.position)
+    from unnest(note_ids) with ordinality as ids (note_id, position)
+    join notes n on n.id = ids.note_id and n.user_id = uid
+    group by ids.note_id;
+
+    update collections set updated_at = now() where id = cid;
+    return true;
+end;
+$$;
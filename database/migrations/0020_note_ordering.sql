@@ -0,0 +1,25 @@
+-- owners pin notes to the top of their profile and choose how the others are sorted: by last
+-- update, creation, views, or in an order of their own (manual_rank, higher first, 0 for the notes
+-- they did not order).
+
+alter table notes add column pinned boolean not null default false;
+alter table notes add column manual_rank integer not null default 0;
+
+alter table users add column notes_sort text not null default 'updated'
+    constraint users_notes_sort_check check (notes_sort in ('updated', 'created', 'views', 'manual'));
+
+create index notes_user_id_listing_idx on notes (user_id, pinned desc, updated_at desc, id desc);
+
+-- set_notes_manual_order ranks the notes of a user in the order of note_ids, first highest, and
+-- unranks the others. Ids of notes the user does not own are skipped.
+create function set_notes_manual_order(uid uuid, note_ids uuid[])
+returns void
+language sql as $$
+    update notes n
+    set manual_rank = coalesce((
+        select cardinality(note_ids) - min(ids.position) + 1
+        from unnest(note_ids) with ordinality as ids (note_id, position)
+        where ids.note_id = n.id
+    ), 0)
+    where n.user_id = uid and (n.manual_rank <> 0 or n.id = any (note_ids));
+$$;
@@ -0,0 +1,34 @@
+-- the tags readers see are the hashtags of the published body of notes deployed as a snapshot,
+-- so that tags only added to the working copy are not listed until the note is republished.
+-- note_tags keeps the tags of the working copy, for the owner.
+
+alter table notes add column public_tags text[] generated always as (
+    note_hashtags(case when publish_mode = 'snapshot' then coalesce(published_body, body) else body end)
+) stored;
+
+create index notes_public_tags_idx on notes using gin (public_tags) where visibility = 'public';
+
+-- list_tags returns the tags of a user with how many of their notes have each, except the
+-- deleted notes. With only_deployed set, only public notes are counted, with the tags their
+-- readers see.
+create or replace function list_tags(uid uuid, only_deployed boolean default false)
+returns table (name text, notes bigint)
+language sql stable as $$
+    select t.name, count(*) as notes
+    from tags t
+    join note_tags nt on nt.tag_id = t.id
+    join notes n on n.id = nt.note_id
+    where not only_deployed
+      and t.user_id = uid
+      and n.deleted_at is null
+    group by t.name
+    union all
+    select tag, count(*)
+    from notes n, unnest(n.public_tags) as tag
+    where only_deployed
+      and n.user_id = uid
+      and n.deleted_at is null
+      and n.visibility = 'public'
+    group by tag
+    order by notes desc, name;
+$$;
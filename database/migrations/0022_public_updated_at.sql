@@ -0,0 +1,10 @@
+-- readers sort notes deployed as a snapshot by when the snapshot was published, not by when the
+-- working copy they do not see was last updated. It must match Note.PublicUpdatedAt.
+
+alter table notes add column public_updated_at timestamptz generated always as (
+    case when publish_mode = 'snapshot' then coalesce(published_at, updated_at) else updated_at end
+) stored;
+
+drop index notes_user_id_listing_idx;
+create index notes_user_id_listing_idx on notes (user_id, pinned desc, public_updated_at desc, id desc)
+    where visibility = 'public';
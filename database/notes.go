@@ -2,26 +2,57 @@ package database
 
 import (
 	"fmt"
-	"slices"
 	"strings"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
 )
 
+// noteListColumns are the columns of the notes listed by ListNotes and ListDeployedNotes.
+const noteListColumns = "id,user_id,source,source_identifier,created_at,updated_at,inserted_at,title,slug,deployed,visibility,views,noindex,publish_mode,published_title,published_at,folder_id,folder_name,deploy_at,expires_at,pinned,manual_rank"
+
+// columns returns the columns to select to list notes with opts, with their bodies if opts asks
+// for them, embedding their tags if opts filters on one, unless the notes are public and filtered
+// on their public_tags.
+func (opts ListOptions) columns(columns string, public bool) string {
+	if opts.Bodies {
+		columns += ",body,published_body"
+	}
+	if opts.Tag == "" || public {
+		return columns
+	}
+	return columns + ",note_tags!inner(tags!inner(name))"
+}
+
+// filter adds the filters, sort and limit of opts to a notes query selecting opts.columns. The
+// tags of public notes are the ones their readers see, in the public_tags column.
+func (opts ListOptions) filter(query *postgrest.FilterBuilder, public bool) *postgrest.FilterBuilder {
+	switch {
+	case opts.Tag != "" && public:
+		query = query.Filter("public_tags", "cs", `{"`+strings.ReplaceAll(opts.Tag, `"`, "")+`"}`)
+	case opts.Tag != "":
+		query = query.Eq("note_tags.tags.name", opts.Tag)
+	}
+	return opts.order(query, public)
+}
+
 func (db *DB) CountNotes(userID string) (int64, error) {
-	_, count, err := db.client.From("notes").Select("*", "exact", true).Eq("user_id", userID).Limit(1, "").Execute()
+	_, count, err := db.client.From("notes").Select("*", "exact", true).Eq("user_id", userID).Is("deleted_at", "null").Limit(1, "").Execute()
 	if err != nil {
 		return 0, fmt.Errorf("count notes: %w", err)
 	}
 	return count, nil
 }
 
-// GetSourceIdentifiersByUserID retrieves all source identifiers for a specific user.
+// GetSourceIdentifiersByUserID retrieves the source identifiers of the notes of a user that are
+// not deleted, so that a note deleted in its source is sent again if it comes back.
 func (db *DB) GetSourceIdentifiersByUserID(userID string) ([]string, error) {
 	var identifiers []string
 	var output []struct {
 		SourceIdentifier string `json:"source_identifier"`
 	}
 
-	_, err := db.client.From("notes").Select("source_identifier", "", false).Eq("user_id", userID).ExecuteTo(&output)
+	_, err := db.client.From("notes").Select("source_identifier", "", false).Eq("user_id", userID).Is("deleted_at", "null").ExecuteTo(&output)
 	if err != nil {
 		return nil, err
 	}
@@ -59,20 +90,21 @@ func (db *DB) GetNoteBySlug(username, slug string) (*Note, error) {
 	return &note, nil
 }
 
-// ListNotes returns all notes in the database for a specific user. It does not provide the body of the notes.
-func (db *DB) ListNotes(userID string) ([]Note, error) {
+// ListNotes returns all notes in the database for a specific user, except the deleted ones. It only provides the body of the notes if opts.Bodies is set.
+func (db *DB) ListNotes(userID string, opts ListOptions) ([]Note, error) {
 	var notes []Note
-	_, err := db.client.From("notes").Select("id,user_id,source,source_identifier,created_at,updated_at,inserted_at,title,slug,deployed,views", "", false).Eq("user_id", userID).ExecuteTo(&notes)
+	_, err := opts.filter(db.client.From("notes").Select(opts.columns(noteListColumns, false), "", false), false).Eq("user_id", userID).Is("deleted_at", "null").ExecuteTo(&notes)
 	if err != nil {
 		return nil, err
 	}
 	return notes, nil
 }
 
-// ListDeployedNotes returns all notes marked as deployed for a specific user. It does not provide the body of the notes.
-func (db *DB) ListDeployedNotes(userID string) ([]Note, error) {
+// ListDeployedNotes returns the public notes of a user: the deployed notes that are not unlisted
+// or password protected. It only provides the body of the notes if opts.Bodies is set.
+func (db *DB) ListDeployedNotes(userID string, opts ListOptions) ([]Note, error) {
 	var notes []Note
-	_, err := db.client.From("notes").Select("id,user_id,source,source_identifier,created_at,updated_at,inserted_at,title,slug,deployed,views", "", false).Eq("user_id", userID).Eq("deployed", "true").ExecuteTo(&notes)
+	_, err := opts.filter(db.client.From("notes").Select(opts.columns(noteListColumns, true), "", false), true).Eq("user_id", userID).Eq("visibility", VisibilityPublic).ExecuteTo(&notes)
 	if err != nil {
 		return nil, fmt.Errorf("list deployed notes: %w", err)
 	}
@@ -99,7 +131,7 @@ func (db *DB) InsertNote(note *Note) error {
 		note.Slug = randomB32(5)
 	}
 
-	_, err := db.client.From("notes").Insert(note, true, "user_id,source_identifier", "", "").Single().ExecuteTo(note)
+	_, err := db.client.From("notes").Insert(note, true, "user_id,source,source_identifier", "", "").Single().ExecuteTo(note)
 	if err == nil { // exit early if no error
 		return nil
 	}
@@ -122,13 +154,31 @@ func (db *DB) InsertNote(note *Note) error {
 
 func (db *DB) DeployNote(noteID, userID string) error {
 	// update the note to set deployed to true
-	_, _, err := db.client.From("notes").Update(map[string]any{"deployed": true}, "", "").Eq("id", noteID).Eq("user_id", userID).Execute()
+	_, _, err := db.client.From("notes").Update(map[string]any{"deployed": true, "publish_mode": PublishLive}, "", "").Eq("id", noteID).Eq("user_id", userID).Execute()
 	if err != nil {
 		return fmt.Errorf("deploy note: %w", err)
 	}
 	return nil
 }
 
+// PublishNote deploys a note as a snapshot of its current title and body, using the publish_note
+// function. Publishing a note already deployed as a snapshot replaces the snapshot. It returns an
+// error wrapping ErrNoRows if the user owns no such note.
+func (db *DB) PublishNote(noteID, userID string) error {
+	var found bool
+	err := db.rpc("publish_note", map[string]any{
+		"uid": userID,
+		"nid": noteID,
+	}, &found)
+	if err != nil {
+		return fmt.Errorf("publish note: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("publish note: %w", ErrNoRows)
+	}
+	return nil
+}
+
 func (db *DB) UndeployNote(noteID, userID string) error {
 	// update the note to set deployed to true
 	_, _, err := db.client.From("notes").Update(map[string]any{"deployed": false}, "", "").Eq("id", noteID).Eq("user_id", userID).Execute()
@@ -138,37 +188,115 @@ func (db *DB) UndeployNote(noteID, userID string) error {
 	return nil
 }
 
-// UpdateNote updates an existing note in the database by its source identifier.
+// UpdateNote updates an existing note in the database by its user ID, source and source
+// identifier. It returns an error wrapping ErrNoRows if the user owns no such note.
 func (db *DB) UpdateNote(note *Note) error {
-	db.client.From("notes").Update(map[string]any{
+	var updated []Note
+	_, err := db.client.From("notes").Update(map[string]any{
 		"created_at": note.CreatedAt,
 		"updated_at": note.UpdatedAt,
 		"title":      note.Title,
 		"body":       note.Body,
-	}, "", "").Eq("source_identifier", note.SourceIdentifier).Single().ExecuteTo(note)
+	}, "", "").Eq("user_id", note.UserID).Eq("source", note.Source).Eq("source_identifier", note.SourceIdentifier).ExecuteTo(&updated)
+	if err != nil {
+		return fmt.Errorf("update note: %w", err)
+	}
+	if len(updated) == 0 {
+		return fmt.Errorf("update note: %w", ErrNoRows)
+	}
+	*note = updated[0]
 	return nil
 }
 
-func (db *DB) InsertNotesForUser(userID string, notes []Note) error {
-	identifiers, err := db.GetSourceIdentifiersByUserID(userID)
+// getExistingSlugs maps the source and source identifier of a user's notes to their slugs.
+func (db *DB) getExistingSlugs(userID string) (map[syncKey]string, error) {
+	var output []Note
+	for page := 0; ; page++ {
+		var notes []Note
+		_, err := db.client.From("notes").Select("source,source_identifier,slug", "", false).Eq("user_id", userID).
+			Order("id", nil).Range(page*pageSize, (page+1)*pageSize-1, "").ExecuteTo(&notes)
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, notes...)
+		if len(notes) < pageSize {
+			break
+		}
+	}
+
+	slugs := make(map[syncKey]string, len(output))
+	for _, note := range output {
+		slugs[keyOf(&note)] = note.Slug
+	}
+	return slugs, nil
+}
+
+// InsertNotesForUser inserts or updates the given notes of a user in a single transaction, using
+// the sync_notes function. It returns one result per note, in the same order. Notes that fail
+// validation are reported as failed and do not stop the others from being synced, but if the
+// database write fails nothing is written and an error is returned.
+//
+// Stored notes of the sources in live that are missing from their list are marked as deleted and
+// undeployed in the same transaction, and reported after the given notes.
+func (db *DB) InsertNotesForUser(userID string, notes []Note, live map[string][]string) ([]SyncResult, error) {
+	rules, err := db.ListSyncRules(userID)
 	if err != nil {
-		return fmt.Errorf("get source identifiers: %w", err)
-	}
-
-	for _, note := range notes {
-		note.UserID = userID
-		if slices.Contains(identifiers, note.SourceIdentifier) {
-			// note already exists in the database, update it
-			err = db.UpdateNote(&note)
-			continue
-		} else {
-			// note does not exist, insert it
-			err = db.InsertNote(&note)
+		return nil, err
+	}
+
+	for range 3 { // a concurrent sync may take one of our new slugs, try again with fresh ones
+		var existing map[syncKey]string
+		existing, err = db.getExistingSlugs(userID)
+		if err != nil {
+			return nil, fmt.Errorf("get source identifiers: %w", err)
+		}
+
+		batch := newSyncBatch(userID, notes, existing, rules)
+		if len(batch.notes) == 0 && live == nil {
+			return batch.results, nil
+		}
+
+		var rows []syncRow
+		err = db.rpc("sync_notes", map[string]any{
+			"uid":     userID,
+			"payload": batch.payload(),
+			"live":    batch.live(live),
+		}, &rows)
+		if err == nil {
+			batch.apply(rows)
+			return batch.results, nil
 		}
-		if err != nil { // true if slug attempt failed or any other error
-			return err
+		if !strings.Contains(err.Error(), "unique_user_slug") {
+			break
 		}
 	}
+	return nil, fmt.Errorf("sync notes: %w", err)
+}
 
-	return nil
+// PurgeDeletedNotes permanently deletes the notes that were marked as deleted before deletedBefore.
+// It returns how many notes were deleted.
+func (db *DB) PurgeDeletedNotes(deletedBefore time.Time) (int64, error) {
+	_, count, err := db.client.From("notes").Delete("minimal", "exact").Lt("deleted_at", deletedBefore.Format(time.RFC3339)).Execute()
+	if err != nil {
+		return 0, fmt.Errorf("purge deleted notes: %w", err)
+	}
+	return count, nil
+}
+
+// GetNoteManifest returns the manifest entries of a user's notes from source, except the deleted ones.
+func (db *DB) GetNoteManifest(userID, source string) ([]ManifestEntry, error) {
+	manifest := []ManifestEntry{}
+	for page := 0; ; page++ {
+		var entries []ManifestEntry
+		_, err := db.client.From("notes").Select("source,source_identifier,updated_at,content_hash,folder_id,folder_name", "", false).
+			Eq("user_id", userID).Eq("source", source).Is("deleted_at", "null").
+			Order("id", nil).Range(page*pageSize, (page+1)*pageSize-1, "").ExecuteTo(&entries)
+		if err != nil {
+			return nil, fmt.Errorf("get note manifest: %w", err)
+		}
+		manifest = append(manifest, entries...)
+		if len(entries) < pageSize {
+			return manifest, nil
+		}
+	}
 }
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+
+	storage_go "github.com/supabase-community/storage-go"
+)
+
+// ogImagesBucket is the storage bucket of the Open Graph images of notes.
+const ogImagesBucket = "og-images"
+
+// GetOGImage returns the Open Graph image of a note stored with key. It returns an error if there
+// is none, or if the stored image has another key.
+func (db *DB) GetOGImage(noteID, key string) ([]byte, error) {
+	data, err := db.client.Storage.DownloadFile(ogImagesBucket, noteID+"/"+key+".png")
+	if err != nil {
+		return nil, fmt.Errorf("get og image: %w", err)
+	}
+	return data, nil
+}
+
+// SaveOGImage stores the Open Graph image of a note with key, replacing the one it had.
+func (db *DB) SaveOGImage(noteID, key string, data []byte) error {
+	ct := "image/png"
+	_, err := db.client.Storage.UpdateFile(ogImagesBucket, noteID+"/"+key+".png", bytes.NewReader(data), storage_go.FileOptions{
+		ContentType: &ct,
+	})
+	if err != nil {
+		return fmt.Errorf("save og image: %w", err)
+	}
+
+	// remove the images of the previous keys
+	files, err := db.client.Storage.ListFiles(ogImagesBucket, noteID, storage_go.FileSearchOptions{})
+	if err != nil {
+		return fmt.Errorf("list og images: %w", err)
+	}
+	var stale []string
+	for _, file := range files {
+		if file.Name != key+".png" {
+			stale = append(stale, noteID+"/"+file.Name)
+		}
+	}
+	if len(stale) > 0 {
+		if _, err := db.client.Storage.RemoveFile(ogImagesBucket, stale); err != nil {
+			return fmt.Errorf("remove og images: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// Sorts of the notes listed with ListOptions, and defaults of the profiles of users.
+const (
+	SortUpdated = "updated" // last updated first
+	SortCreated = "created" // last created first
+	SortViews   = "views"   // most viewed first
+	SortManual  = "manual"  // in the order chosen by the owner, the other notes after
+)
+
+// sortColumns are the notes columns notes are sorted by, by sort.
+var sortColumns = map[string]string{
+	SortUpdated: "updated_at",
+	SortCreated: "created_at",
+	SortViews:   "views",
+	SortManual:  "manual_rank",
+}
+
+// sortColumn returns the notes column notes are sorted by. Public notes are sorted by when what
+// their readers see last changed.
+func sortColumn(sort string, public bool) string {
+	if sort == SortUpdated && public {
+		return "public_updated_at"
+	}
+	return sortColumns[sort]
+}
+
+// PublicUpdatedAt returns when what readers see of a note last changed: when its snapshot was
+// published if it is deployed as one, or else when it was last updated, like the
+// public_updated_at column.
+func (n *Note) PublicUpdatedAt() string {
+	if n.PublishMode == PublishSnapshot && n.PublishedAt != "" {
+		return n.PublishedAt
+	}
+	return n.UpdatedAt
+}
+
+// ValidSort reports whether sort is one of the sorts of notes.
+func ValidSort(sort string) bool {
+	_, ok := sortColumns[sort]
+	return ok
+}
+
+// ListCursor is the last note of a page of sorted notes. The next page lists the notes after it.
+type ListCursor struct {
+	Sort      string `json:"s"`
+	Ascending bool   `json:"a,omitempty"`
+	Pinned    bool   `json:"p,omitempty"`
+	Key       string `json:"k"` // value of the sort column of the note
+	ID        string `json:"i"`
+}
+
+// ErrInvalidCursor is returned by ParseCursor for cursors that were not returned by
+// ListOptions.Cursor.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// sortKey returns the value of the sort column of a note, as listed to its readers if public is
+// set.
+func sortKey(sort string, note *Note, public bool) string {
+	switch sort {
+	case SortUpdated:
+		if public {
+			return note.PublicUpdatedAt()
+		}
+	case SortCreated:
+		return note.CreatedAt
+	case SortViews:
+		return strconv.FormatInt(note.Views, 10)
+	case SortManual:
+		return strconv.FormatInt(note.ManualRank, 10)
+	}
+	return note.UpdatedAt
+}
+
+// Cursor returns the opaque cursor listing the notes after note with opts. public is set for the
+// notes of ListDeployedNotes, sorted as their readers see them.
+func (opts ListOptions) Cursor(note *Note, public bool) string {
+	data, _ := json.Marshal(ListCursor{
+		Sort:      opts.Sort,
+		Ascending: opts.Ascending,
+		Pinned:    note.Pinned,
+		Key:       sortKey(opts.Sort, note, public),
+		ID:        note.ID,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseCursor decodes a cursor returned by ListOptions.Cursor. It returns an error wrapping
+// ErrInvalidCursor if it is not one.
+func ParseCursor(cursor string) (*ListCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	var c ListCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	if !ValidSort(c.Sort) || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort == SortViews || c.Sort == SortManual {
+		if _, err := strconv.ParseInt(c.Key, 10, 64); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+		}
+	}
+	return &c, nil
+}
+
+// order adds the sort, cursor and limit of opts to a notes query, of public notes if public is
+// set.
+func (opts ListOptions) order(query *postgrest.FilterBuilder, public bool) *postgrest.FilterBuilder {
+	if opts.Sort == "" {
+		return query
+	}
+	column := sortColumn(opts.Sort, public)
+	if opts.After != nil {
+		// after the cursor: pinned notes first, then by column and id in the order of the sort
+		op := "lt"
+		if opts.Ascending {
+			op = "gt"
+		}
+		key := `"` + strings.ReplaceAll(opts.After.Key, `"`, "") + `"`
+		query = query.Or(fmt.Sprintf("pinned.lt.%t,and(pinned.eq.%t,or(%s.%s.%s,and(%s.eq.%s,id.%s.%s)))",
+			opts.After.Pinned, opts.After.Pinned, column, op, key, column, key, op, opts.After.ID), "")
+	}
+	query = query.Order("pinned", &postgrest.OrderOpts{Ascending: false}).
+		Order(column, &postgrest.OrderOpts{Ascending: opts.Ascending}).
+		Order("id", &postgrest.OrderOpts{Ascending: opts.Ascending})
+	if opts.Limit > 0 {
+		query = query.Limit(opts.Limit, "")
+	}
+	return query
+}
+
+// SetNotePinned pins a note to the top of the profile of its owner, or unpins it. It returns an
+// error wrapping ErrNoRows if the user owns no such note.
+func (db *DB) SetNotePinned(noteID, userID string, pinned bool) error {
+	_, count, err := db.client.From("notes").Update(map[string]bool{
+		"pinned": pinned,
+	}, "minimal", "exact").Eq("id", noteID).Eq("user_id", userID).Execute()
+	if err != nil {
+		return fmt.Errorf("set note pinned: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("set note pinned: %w", ErrNoRows)
+	}
+	return nil
+}
+
+// SetNotesManualOrder ranks the notes of a user in the order of noteIDs for SortManual, and
+// unranks the others, using the set_notes_manual_order function. Ids of notes the user does not
+// own are skipped.
+func (db *DB) SetNotesManualOrder(userID string, noteIDs []string) error {
+	var out any
+	err := db.rpc("set_notes_manual_order", map[string]any{
+		"uid":      userID,
+		"note_ids": noteIDs,
+	}, &out)
+	if err != nil {
+		return fmt.Errorf("set notes manual order: %w", err)
+	}
+	return nil
+}
+
+// SetUserNotesSort sets how the notes on the profile of a user are sorted by default.
+func (db *DB) SetUserNotesSort(userID, sort string) error {
+	_, _, err := db.client.From("users").Update(map[string]string{
+		"notes_sort": sort,
+	}, "minimal", "").Eq("id", userID).Execute()
+	if err != nil {
+		return fmt.Errorf("set user notes sort: %w", err)
+	}
+	return nil
+}
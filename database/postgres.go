@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresDB is a Store backed by a plain PostgreSQL database, for self-hosting without supabase.
+// The schema is created and upgraded by Migrate.
+type PostgresDB struct {
+	pool    *pgxpool.Pool
+	pfpsURL string // base URL the profile pictures are served from
+}
+
+// Postgres returns a new instance of PostgresDB connected to the database at connString. Profile
+// pictures are stored in the database and their URLs are built from pfpsURL, the URL of the route
+// serving GetProfilePicture.
+func Postgres(connString, pfpsURL string) (*PostgresDB, error) {
+	pool, err := pgxpool.New(context.Background(), connString)
+	if err != nil {
+		return nil, fmt.Errorf("create postgres pool: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("ping postgres: %w", err)
+	}
+
+	return &PostgresDB{pool: pool, pfpsURL: pfpsURL}, nil
+}
+
+// Close closes all connections in the pool.
+func (db *PostgresDB) Close() {
+	db.pool.Close()
+}
+
+// pgTime formats a timestamptz column like postgrest does, so both stores return the same format.
+func pgTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
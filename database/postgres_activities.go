@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// pgActivityColumns are the activities columns in the order scanActivity expects them.
+const pgActivityColumns = `id, user_id, type, description, "timestamp"`
+
+func scanActivity(row pgx.Row) (*Activity, error) {
+	var activity Activity
+	var timestamp time.Time
+	err := row.Scan(&activity.ID, &activity.UserID, &activity.Type, &activity.Description, &timestamp)
+	if err != nil {
+		return nil, err
+	}
+	activity.Timestamp = pgTime(timestamp)
+	return &activity, nil
+}
+
+func (db *PostgresDB) GetActivities(userID string, loadTime time.Time, offset, limit int) ([]Activity, error) {
+	// a zero load time matches everything
+	var before *time.Time
+	if loadTime.UnixMilli() > 0 {
+		before = &loadTime
+	}
+
+	rows, err := db.pool.Query(context.Background(), `select `+pgActivityColumns+` from activities
+		where user_id = $1 and ($2::timestamptz is null or "timestamp" < $2)
+		order by "timestamp" desc
+		offset $3 limit $4`,
+		userID, before, offset, max(limit, 1))
+	if err != nil {
+		return nil, fmt.Errorf("get user activities: %w", err)
+	}
+	defer rows.Close()
+
+	activities := []Activity{}
+	for rows.Next() {
+		activity, err := scanActivity(rows)
+		if err != nil {
+			return nil, fmt.Errorf("get user activities: %w", err)
+		}
+		activities = append(activities, *activity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get user activities: %w", err)
+	}
+	return activities, nil
+}
+
+func (db *PostgresDB) GetLastActivityByType(userID, activityType string) (*Activity, error) {
+	activity, err := scanActivity(db.pool.QueryRow(context.Background(), `select `+pgActivityColumns+` from activities
+		where user_id = $1 and type = $2
+		order by "timestamp" desc
+		limit 1`, userID, activityType))
+	if err != nil {
+		return nil, fmt.Errorf("get last activity by type: %w", err)
+	}
+	return activity, nil
+}
+
+func (db *PostgresDB) InsertActivity(activity *Activity) error {
+	inserted, err := scanActivity(db.pool.QueryRow(context.Background(), `insert into activities
+		(user_id, type, description) values ($1, $2, $3)
+		returning `+pgActivityColumns,
+		activity.UserID, activity.Type, activity.Description))
+	if err != nil {
+		return fmt.Errorf("insert activity: %w", err)
+	}
+	*activity = *inserted
+	return nil
+}
+
+// GetUserStats returns the row of the get_user_stats function as a JSON object.
+func (db *PostgresDB) GetUserStats(userID string) (string, error) {
+	var stats struct {
+		TotalViews    int64 `json:"total_views"`
+		TotalNotes    int64 `json:"total_notes"`
+		DeployedNotes int64 `json:"deployed_notes"`
+	}
+	err := db.pool.QueryRow(context.Background(),
+		"select total_views, total_notes, deployed_notes from get_user_stats($1)", userID).
+		Scan(&stats.TotalViews, &stats.TotalNotes, &stats.DeployedNotes)
+	if err != nil {
+		return "", fmt.Errorf("get user stats: %w", err)
+	}
+
+	b, err := json.Marshal(stats)
+	if err != nil {
+		return "", fmt.Errorf("marshal user stats: %w", err)
+	}
+	return string(b), nil
+}
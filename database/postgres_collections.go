@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// pgCollectionQuery selects collections with their notes in order, in the order scanCollection
+// expects them. The conditions are appended to it, before pgCollectionGroup.
+const pgCollectionQuery = `select c.id, c.user_id, c.slug, c.name, c.description, c.created_at, c.updated_at,
+		coalesce(array_agg(cn.note_id::text order by cn.position) filter (where cn.note_id is not null), '{}')
+	from collections c left join collection_notes cn on cn.collection_id = c.id`
+
+// pgCollectionGroup ends a pgCollectionQuery, oldest collections first.
+const pgCollectionGroup = ` group by c.id order by c.created_at, c.id`
+
+// scanCollection scans a row selected with pgCollectionQuery.
+func scanCollection(row pgx.Row) (*Collection, error) {
+	var collection Collection
+	var createdAt, updatedAt time.Time
+	err := row.Scan(&collection.ID, &collection.UserID, &collection.Slug, &collection.Name, &collection.Description,
+		&createdAt, &updatedAt, &collection.NoteIDs)
+	if err != nil {
+		return nil, err
+	}
+	collection.CreatedAt = pgTime(createdAt)
+	collection.UpdatedAt = pgTime(updatedAt)
+	return &collection, nil
+}
+
+// queryCollections runs a pgCollectionQuery with the conditions and scans every row.
+func (db *PostgresDB) queryCollections(conditions string, args ...any) ([]Collection, error) {
+	rows, err := db.pool.Query(context.Background(), pgCollectionQuery+" where "+conditions+pgCollectionGroup, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Collection, error) {
+		collection, err := scanCollection(row)
+		if err != nil {
+			return Collection{}, err
+		}
+		return *collection, nil
+	})
+}
+
+// ListCollections returns the collections of a user, oldest first.
+func (db *PostgresDB) ListCollections(userID string) ([]Collection, error) {
+	collections, err := db.queryCollections("c.user_id = $1", userID)
+	if err != nil {
+		return nil, fmt.Errorf("list collections: %w", err)
+	}
+	if collections == nil {
+		collections = []Collection{}
+	}
+	return collections, nil
+}
+
+// GetCollectionBySlug returns the collection of a user with a slug.
+func (db *PostgresDB) GetCollectionBySlug(userID, slug string) (*Collection, error) {
+	collections, err := db.queryCollections("c.user_id = $1 and c.slug = $2", userID, slug)
+	if err != nil {
+		return nil, fmt.Errorf("get collection by slug: %w", err)
+	}
+	if len(collections) == 0 {
+		return nil, fmt.Errorf("get collection by slug: %w", ErrNoRows)
+	}
+	return &collections[0], nil
+}
+
+// InsertCollection adds an empty collection for collection.UserID. The ID and timestamps are
+// populated by the database.
+func (db *PostgresDB) InsertCollection(collection *Collection) error {
+	var createdAt, updatedAt time.Time
+	err := db.pool.QueryRow(context.Background(), `insert into collections (user_id, slug, name, description)
+		values ($1, $2, $3, $4)
+		returning id, created_at, updated_at`, collection.UserID, collection.Slug, collection.Name, collection.Description).
+		Scan(&collection.ID, &createdAt, &updatedAt)
+	if err != nil {
+		return fmt.Errorf("insert collection: %w", err)
+	}
+	collection.NoteIDs = []string{}
+	collection.CreatedAt = pgTime(createdAt)
+	collection.UpdatedAt = pgTime(updatedAt)
+	return nil
+}
+
+// UpdateCollection updates the slug, name and description of a collection of a user, and
+// populates its other fields. It returns an error wrapping ErrNoRows if the user has no such
+// collection.
+func (db *PostgresDB) UpdateCollection(collection *Collection) error {
+	tag, err := db.pool.Exec(context.Background(), `update collections
+		set slug = $3, name = $4, description = $5, updated_at = now()
+		where id = $1 and user_id = $2`, collection.ID, collection.UserID, collection.Slug, collection.Name, collection.Description)
+	if err != nil {
+		return fmt.Errorf("update collection: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("update collection: %w", ErrNoRows)
+	}
+
+	updated, err := db.queryCollections("c.id = $1", collection.ID)
+	if err != nil {
+		return fmt.Errorf("get updated collection: %w", err)
+	}
+	if len(updated) > 0 {
+		*collection = updated[0]
+	}
+	return nil
+}
+
+// DeleteCollection deletes a collection of a user, not its notes. It returns an error wrapping
+// ErrNoRows if the user has no such collection.
+func (db *PostgresDB) DeleteCollection(collectionID, userID string) error {
+	tag, err := db.pool.Exec(context.Background(), "delete from collections where id = $1 and user_id = $2", collectionID, userID)
+	if err != nil {
+		return fmt.Errorf("delete collection: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("delete collection: %w", ErrNoRows)
+	}
+	return nil
+}
+
+// SetCollectionNotes replaces the notes of a collection of a user by noteIDs, in that order, using
+// the set_collection_notes function. Ids of notes the user does not own, and repeated ids, are
+// skipped. It returns an error wrapping ErrNoRows if the user has no such collection.
+func (db *PostgresDB) SetCollectionNotes(collectionID, userID string, noteIDs []string) error {
+	var found bool
+	err := db.pool.QueryRow(context.Background(), "select set_collection_notes($1, $2, $3::uuid[])", userID, collectionID, noteIDs).Scan(&found)
+	if err != nil {
+		return fmt.Errorf("set collection notes: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("set collection notes: %w", ErrNoRows)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func scanCustomDomain(row pgx.Row) (*CustomDomain, error) {
+	var domain CustomDomain
+	var verifiedAt *time.Time
+	var createdAt time.Time
+	if err := row.Scan(&domain.UserID, &domain.Domain, &domain.Token, &verifiedAt, &createdAt); err != nil {
+		return nil, err
+	}
+	if verifiedAt != nil {
+		domain.VerifiedAt = pgTime(*verifiedAt)
+	}
+	domain.CreatedAt = pgTime(createdAt)
+	return &domain, nil
+}
+
+// GetCustomDomain returns the custom domain of a user, verified or not.
+func (db *PostgresDB) GetCustomDomain(userID string) (*CustomDomain, error) {
+	domain, err := scanCustomDomain(db.pool.QueryRow(context.Background(),
+		"select user_id, domain, token, verified_at, created_at from custom_domains where user_id = $1", userID))
+	if err != nil {
+		return nil, fmt.Errorf("get custom domain: %w", err)
+	}
+	return domain, nil
+}
+
+// SetCustomDomain sets domain.Domain as the custom domain of domain.UserID, replacing the one they
+// had. The domain has a new token and is not verified, the Token and CreatedAt fields are
+// populated.
+func (db *PostgresDB) SetCustomDomain(domain *CustomDomain) error {
+	set, err := scanCustomDomain(db.pool.QueryRow(context.Background(), `insert into custom_domains (user_id, domain, token)
+		values ($1, $2, $3)
+		on conflict (user_id) do update set domain = excluded.domain, token = excluded.token, verified_at = null, created_at = now()
+		returning user_id, domain, token, verified_at, created_at`, domain.UserID, domain.Domain, newDomainToken()))
+	if err != nil {
+		return fmt.Errorf("set custom domain: %w", err)
+	}
+	*domain = *set
+	return nil
+}
+
+// VerifyCustomDomain marks the custom domain of a user as verified. Other users who had the
+// domain verified lose it, as it changed hands.
+func (db *PostgresDB) VerifyCustomDomain(userID string) (*CustomDomain, error) {
+	ctx := context.Background()
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("verify custom domain: %w", err)
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+
+	_, err = tx.Exec(ctx, `delete from custom_domains
+		where domain = (select domain from custom_domains where user_id = $1)
+		  and user_id <> $1
+		  and verified_at is not null`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("verify custom domain: %w", err)
+	}
+	domain, err := scanCustomDomain(tx.QueryRow(ctx, `update custom_domains set verified_at = now()
+		where user_id = $1
+		returning user_id, domain, token, verified_at, created_at`, userID))
+	if err != nil {
+		return nil, fmt.Errorf("verify custom domain: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("verify custom domain: %w", err)
+	}
+	return domain, nil
+}
+
+// DeleteCustomDomain removes the custom domain of a user. It returns an error wrapping ErrNoRows
+// if the user has none.
+func (db *PostgresDB) DeleteCustomDomain(userID string) error {
+	tag, err := db.pool.Exec(context.Background(), "delete from custom_domains where user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("delete custom domain: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("delete custom domain: %w", ErrNoRows)
+	}
+	return nil
+}
+
+// GetUserByCustomDomain returns the user who has domain verified. It returns an error wrapping
+// ErrNoRows if no one does.
+func (db *PostgresDB) GetUserByCustomDomain(domain string) (*User, error) {
+	user, err := scanUser(db.pool.QueryRow(context.Background(), "select "+pgUserColumns+` from users
+		where id = (select user_id from custom_domains where domain = $1 and verified_at is not null)`, domain))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("get user by custom domain: %w", ErrNoRows)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get user by custom domain: %w", err)
+	}
+	return user, nil
+}
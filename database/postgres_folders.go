@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ListFolders returns the folders of a user's notes, by name, except the deleted notes.
+func (db *PostgresDB) ListFolders(userID string) ([]Folder, error) {
+	rows, err := db.pool.Query(context.Background(), `select folder_id, min(folder_name), count(*), count(*) filter (where deployed)
+		from notes where user_id = $1 and deleted_at is null and folder_id <> ''
+		group by folder_id order by 2, 1`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list folders: %w", err)
+	}
+	folders, err := pgx.CollectRows(rows, pgx.RowToStructByPos[Folder])
+	if err != nil {
+		return nil, fmt.Errorf("list folders: %w", err)
+	}
+	return folders, nil
+}
+
+// DeployFolder deploys the notes of a user's folder that are not deployed yet, live. Notes already
+// deployed, live or as a snapshot, are left alone. It returns how many notes were deployed.
+func (db *PostgresDB) DeployFolder(folderID, userID string) (int64, error) {
+	tag, err := db.pool.Exec(context.Background(), `update notes set deployed = true, publish_mode = 'live'
+		where user_id = $1 and folder_id = $2 and not deployed and deleted_at is null`, userID, folderID)
+	if err != nil {
+		return 0, fmt.Errorf("deploy folder: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// UndeployFolder undeploys the deployed notes of a user's folder. It returns how many notes were
+// undeployed.
+func (db *PostgresDB) UndeployFolder(folderID, userID string) (int64, error) {
+	tag, err := db.pool.Exec(context.Background(), `update notes set deployed = false
+		where user_id = $1 and folder_id = $2 and deployed`, userID, folderID)
+	if err != nil {
+		return 0, fmt.Errorf("undeploy folder: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
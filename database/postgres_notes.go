@@ -0,0 +1,341 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// pgNoteColumns are the notes columns, without the body, in the order scanNote expects them.
+const pgNoteColumns = `id, user_id, source, source_identifier, created_at, updated_at, inserted_at, title, slug, deployed, views,
+	deleted_at, content_hash, publish_mode, published_title, published_at, folder_id, folder_name, noindex, visibility, deploy_at, expires_at, pinned, manual_rank`
+
+// pgNoteBodyColumns are the body columns of notes, selected after pgNoteColumns when scanNote is
+// asked for the body.
+const pgNoteBodyColumns = `body, published_body`
+
+// scanNote scans a row selected with pgNoteColumns. If withBody is set, the row must have
+// pgNoteBodyColumns selected after those columns.
+func scanNote(row pgx.Row, withBody bool) (*Note, error) {
+	var note Note
+	var createdAt, updatedAt, insertedAt time.Time
+	var deletedAt, publishedAt, deployAt, expiresAt *time.Time
+	dest := []any{&note.ID, &note.UserID, &note.Source, &note.SourceIdentifier, &createdAt, &updatedAt,
+		&insertedAt, &note.Title, &note.Slug, &note.Deployed, &note.Views, &deletedAt, &note.ContentHash,
+		&note.PublishMode, &note.PublishedTitle, &publishedAt, &note.FolderID, &note.FolderName, &note.Noindex,
+		&note.Visibility, &deployAt, &expiresAt, &note.Pinned, &note.ManualRank}
+	if withBody {
+		dest = append(dest, &note.Body, &note.PublishedBody)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	note.CreatedAt = pgTime(createdAt)
+	note.UpdatedAt = pgTime(updatedAt)
+	note.InsertedAt = pgTime(insertedAt)
+	if deletedAt != nil {
+		note.DeletedAt = pgTime(*deletedAt)
+	}
+	if publishedAt != nil {
+		note.PublishedAt = pgTime(*publishedAt)
+	}
+	if deployAt != nil {
+		note.DeployAt = pgTime(*deployAt)
+	}
+	if expiresAt != nil {
+		note.ExpiresAt = pgTime(*expiresAt)
+	}
+	return &note, nil
+}
+
+// pgTagFilter is the condition of a notes query on the tag of ListOptions, given as $2. It is
+// always true if the tag is empty.
+const pgTagFilter = ` and ($2 = '' or exists (
+	select 1 from note_tags nt join tags t on t.id = nt.tag_id where nt.note_id = notes.id and t.name = $2
+))`
+
+// pgPublicTagFilter is pgTagFilter for public notes, on the tags their readers see.
+const pgPublicTagFilter = ` and ($2 = '' or $2 = any (public_tags))`
+
+// pgColumns returns the columns to select to list notes with opts, with pgNoteBodyColumns if opts
+// asks for the bodies.
+func (opts ListOptions) pgColumns() string {
+	if opts.Bodies {
+		return pgNoteColumns + ", " + pgNoteBodyColumns
+	}
+	return pgNoteColumns
+}
+
+// queryNotes runs a query selecting pgNoteColumns, followed by pgNoteBodyColumns if withBody is
+// set, and scans every row.
+func (db *PostgresDB) queryNotes(withBody bool, sql string, args ...any) ([]Note, error) {
+	rows, err := db.pool.Query(context.Background(), sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []Note
+	for rows.Next() {
+		note, err := scanNote(rows, withBody)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, *note)
+	}
+	return notes, rows.Err()
+}
+
+// isConstraintViolation reports whether err is a postgres error raised by the named constraint.
+func isConstraintViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.ConstraintName == constraint
+}
+
+func (db *PostgresDB) CountNotes(userID string) (int64, error) {
+	var count int64
+	err := db.pool.QueryRow(context.Background(), "select count(*) from notes where user_id = $1 and deleted_at is null", userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count notes: %w", err)
+	}
+	return count, nil
+}
+
+// GetSourceIdentifiersByUserID retrieves the source identifiers of the notes of a user that are
+// not deleted, so that a note deleted in its source is sent again if it comes back.
+func (db *PostgresDB) GetSourceIdentifiersByUserID(userID string) ([]string, error) {
+	rows, err := db.pool.Query(context.Background(), "select source_identifier from notes where user_id = $1 and deleted_at is null", userID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// GetNoteByID retrieves a note by its ID. It includes the body.
+func (db *PostgresDB) GetNoteByID(noteID string) (*Note, error) {
+	return scanNote(db.pool.QueryRow(context.Background(),
+		"select "+pgNoteColumns+", "+pgNoteBodyColumns+" from notes where id = $1", noteID), true)
+}
+
+// GetNoteBySlug retrieves a note by its username and slug. It includes the body.
+func (db *PostgresDB) GetNoteBySlug(username, slug string) (*Note, error) {
+	id, err := db.GetUserIDByUsername(username)
+	if err != nil {
+		return nil, fmt.Errorf("get user ID by username: %w", err)
+	}
+	return scanNote(db.pool.QueryRow(context.Background(),
+		"select "+pgNoteColumns+", "+pgNoteBodyColumns+" from notes where user_id = $1 and slug = $2", id, slug), true)
+}
+
+// ListNotes returns all notes in the database for a specific user, except the deleted ones. It only provides the body of the notes if opts.Bodies is set.
+func (db *PostgresDB) ListNotes(userID string, opts ListOptions) ([]Note, error) {
+	order, args := opts.pgOrder(false)
+	return db.queryNotes(opts.Bodies, "select "+opts.pgColumns()+" from notes where user_id = $1 and deleted_at is null"+pgTagFilter+order,
+		append([]any{userID, opts.Tag}, args...)...)
+}
+
+// ListDeployedNotes returns the public notes of a user: the deployed notes that are not unlisted
+// or password protected. It only provides the body of the notes if opts.Bodies is set.
+func (db *PostgresDB) ListDeployedNotes(userID string, opts ListOptions) ([]Note, error) {
+	order, args := opts.pgOrder(true)
+	notes, err := db.queryNotes(opts.Bodies, "select "+opts.pgColumns()+" from notes where user_id = $1 and visibility = 'public'"+pgPublicTagFilter+order,
+		append([]any{userID, opts.Tag}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("list deployed notes: %w", err)
+	}
+	return notes, nil
+}
+
+func (db *PostgresDB) IncrementNoteViews(noteID string) error {
+	_, err := db.pool.Exec(context.Background(), "select increment_notes_view_counter($1)", noteID)
+	if err != nil {
+		return fmt.Errorf("increment note views: %w", err)
+	}
+	return nil
+}
+
+// InsertNote inserts a new note into the database and returns the inserted note with its ID.
+// It expects the note to have the user_id, source, source_identifier, created_at,
+// updated_at, title, and body fields set. The ID and inserted_at fields will be populated by the database.
+// If a note with the same user_id, source and source_identifier exists, its synced fields are replaced.
+func (db *PostgresDB) InsertNote(note *Note) error {
+	if note.Slug == "" { // if slug is not set, generate one
+		note.Slug = slugify(note.Title)
+	}
+	if note.Slug == "" { // if slug is still empty, generate a random slug
+		note.Slug = randomB32(5)
+	}
+
+	inserted, err := scanNote(db.pool.QueryRow(context.Background(), `insert into notes
+		(user_id, source, source_identifier, created_at, updated_at, title, slug, body)
+		values ($1, $2, $3, coalesce(nullif($4, '')::timestamptz, now()), coalesce(nullif($5, '')::timestamptz, now()), $6, $7, $8)
+		on conflict (user_id, source, source_identifier) do update set
+			created_at = excluded.created_at,
+			updated_at = excluded.updated_at,
+			title = excluded.title,
+			slug = excluded.slug,
+			body = excluded.body
+		returning `+pgNoteColumns+`, `+pgNoteBodyColumns,
+		note.UserID, note.Source, note.SourceIdentifier, note.CreatedAt, note.UpdatedAt, note.Title, note.Slug, note.Body), true)
+	if err == nil { // exit early if no error
+		*note = *inserted
+		return nil
+	}
+
+	// slug error? if the slug already exists, we will add a random suffix to it
+	if isConstraintViolation(err, "unique_user_slug") {
+		note.Slug = fmt.Sprintf("%s-%s", note.Slug, randomB32(5))
+		err = db.InsertNote(note) // try again with the new slug
+	}
+
+	// non-slug error or slug reattempt error? return error
+	if err != nil {
+		return fmt.Errorf("insert note: %w", err)
+	}
+
+	return nil
+}
+
+func (db *PostgresDB) DeployNote(noteID, userID string) error {
+	_, err := db.pool.Exec(context.Background(), "update notes set deployed = true, publish_mode = 'live' where id = $1 and user_id = $2", noteID, userID)
+	if err != nil {
+		return fmt.Errorf("deploy note: %w", err)
+	}
+	return nil
+}
+
+// PublishNote deploys a note as a snapshot of its current title and body, using the publish_note
+// function. Publishing a note already deployed as a snapshot replaces the snapshot. It returns an
+// error wrapping ErrNoRows if the user owns no such note.
+func (db *PostgresDB) PublishNote(noteID, userID string) error {
+	var found bool
+	err := db.pool.QueryRow(context.Background(), "select publish_note($1, $2)", userID, noteID).Scan(&found)
+	if err != nil {
+		return fmt.Errorf("publish note: %w", err)
+	}
+	if !found {
+		return fmt.Errorf("publish note: %w", ErrNoRows)
+	}
+	return nil
+}
+
+func (db *PostgresDB) UndeployNote(noteID, userID string) error {
+	_, err := db.pool.Exec(context.Background(), "update notes set deployed = false where id = $1 and user_id = $2", noteID, userID)
+	if err != nil {
+		return fmt.Errorf("undeploy note: %w", err)
+	}
+	return nil
+}
+
+// UpdateNote updates an existing note in the database by its user ID, source and source
+// identifier. It returns an error wrapping ErrNoRows if the user owns no such note.
+func (db *PostgresDB) UpdateNote(note *Note) error {
+	updated, err := scanNote(db.pool.QueryRow(context.Background(), `update notes
+		set created_at = $4::timestamptz, updated_at = $5::timestamptz, title = $6, body = $7
+		where user_id = $1 and source = $2 and source_identifier = $3
+		returning `+pgNoteColumns+`, `+pgNoteBodyColumns,
+		note.UserID, note.Source, note.SourceIdentifier, note.CreatedAt, note.UpdatedAt, note.Title, note.Body), true)
+	if err != nil {
+		return fmt.Errorf("update note: %w", err)
+	}
+	*note = *updated
+	return nil
+}
+
+// InsertNotesForUser inserts or updates the given notes of a user in a single transaction, using
+// the sync_notes function. It returns one result per note, in the same order. Notes that fail
+// validation are reported as failed and do not stop the others from being synced, but if the
+// database write fails nothing is written and an error is returned.
+//
+// Stored notes of the sources in live that are missing from their list are marked as deleted and
+// undeployed in the same transaction, and reported after the given notes.
+func (db *PostgresDB) InsertNotesForUser(userID string, notes []Note, live map[string][]string) ([]SyncResult, error) {
+	ctx := context.Background()
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin sync: %w", err)
+	}
+	defer tx.Rollback(ctx) // no-op after commit
+
+	// same lock as sync_notes, held before reading the slugs so that they cannot be taken under us
+	if _, err := tx.Exec(ctx, "select pg_advisory_xact_lock(hashtext($1::uuid::text))", userID); err != nil {
+		return nil, fmt.Errorf("lock sync: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, "select source, source_identifier, slug from notes where user_id = $1", userID)
+	if err != nil {
+		return nil, fmt.Errorf("get source identifiers: %w", err)
+	}
+	existing := make(map[syncKey]string)
+	var key syncKey
+	var slug string
+	_, err = pgx.ForEachRow(rows, []any{&key.source, &key.sourceIdentifier, &slug}, func() error {
+		existing[key] = slug
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get source identifiers: %w", err)
+	}
+
+	rules, err := listSyncRules(tx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list sync rules: %w", err)
+	}
+
+	batch := newSyncBatch(userID, notes, existing, rules)
+	if len(batch.notes) == 0 && live == nil {
+		return batch.results, nil
+	}
+
+	rows, err = tx.Query(ctx, `select note_id, note_source, note_source_identifier, sync_status, note_undeployed
+		from sync_notes($1, $2, $3)`, userID, batch.payload(), batch.live(live))
+	if err != nil {
+		return nil, fmt.Errorf("sync notes: %w", err)
+	}
+	synced, err := pgx.CollectRows(rows, pgx.RowToStructByPos[syncRow])
+	if err != nil {
+		return nil, fmt.Errorf("sync notes: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit sync: %w", err)
+	}
+	batch.apply(synced)
+	return batch.results, nil
+}
+
+// PurgeDeletedNotes permanently deletes the notes that were marked as deleted before deletedBefore.
+// It returns how many notes were deleted.
+func (db *PostgresDB) PurgeDeletedNotes(deletedBefore time.Time) (int64, error) {
+	tag, err := db.pool.Exec(context.Background(), "delete from notes where deleted_at < $1", deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("purge deleted notes: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetNoteManifest returns the manifest entries of a user's notes from source, except the deleted ones.
+func (db *PostgresDB) GetNoteManifest(userID, source string) ([]ManifestEntry, error) {
+	rows, err := db.pool.Query(context.Background(), `select source, source_identifier, updated_at, content_hash, folder_id, folder_name
+		from notes where user_id = $1 and source = $2 and deleted_at is null`, userID, source)
+	if err != nil {
+		return nil, fmt.Errorf("get note manifest: %w", err)
+	}
+
+	manifest := []ManifestEntry{}
+	var entry ManifestEntry
+	var updatedAt time.Time
+	_, err = pgx.ForEachRow(rows, []any{&entry.Source, &entry.SourceIdentifier, &updatedAt, &entry.ContentHash, &entry.FolderID, &entry.FolderName}, func() error {
+		entry.UpdatedAt = pgTime(updatedAt)
+		manifest = append(manifest, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get note manifest: %w", err)
+	}
+	return manifest, nil
+}
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// GetOGImage returns the Open Graph image of a note stored with key. It returns an error if there
+// is none, or if the stored image has another key.
+func (db *PostgresDB) GetOGImage(noteID, key string) ([]byte, error) {
+	var data []byte
+	err := db.pool.QueryRow(context.Background(),
+		"select data from og_images where note_id = $1 and cache_key = $2", noteID, key).Scan(&data)
+	if err != nil {
+		return nil, fmt.Errorf("get og image: %w", err)
+	}
+	return data, nil
+}
+
+// SaveOGImage stores the Open Graph image of a note with key, replacing the one it had.
+func (db *PostgresDB) SaveOGImage(noteID, key string, data []byte) error {
+	_, err := db.pool.Exec(context.Background(), `insert into og_images (note_id, cache_key, data)
+		values ($1, $2, $3)
+		on conflict (note_id) do update set cache_key = excluded.cache_key, data = excluded.data, created_at = now()`,
+		noteID, key, data)
+	if err != nil {
+		return fmt.Errorf("save og image: %w", err)
+	}
+	return nil
+}
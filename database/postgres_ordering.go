@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// pgSortTypes are the types of the sort columns, to cast the keys of cursors to.
+var pgSortTypes = map[string]string{
+	SortUpdated: "timestamptz",
+	SortCreated: "timestamptz",
+	SortViews:   "bigint",
+	SortManual:  "bigint",
+}
+
+// pgOrder returns the cursor condition, order by and limit clauses of opts, to append to a notes
+// query whose parameters before them are $1 and $2, with their parameters. public is set for
+// queries of public notes.
+func (opts ListOptions) pgOrder(public bool) (string, []any) {
+	if opts.Sort == "" {
+		return "", nil
+	}
+	column, dir, op := sortColumn(opts.Sort, public), "desc", "<"
+	if opts.Ascending {
+		dir, op = "asc", ">"
+	}
+
+	var sql string
+	var args []any
+	if opts.After != nil {
+		// after the cursor: pinned notes first, then by column and id in the order of the sort
+		key := "$4::" + pgSortTypes[opts.Sort]
+		sql = fmt.Sprintf(" and (pinned < $3 or (pinned = $3 and (%s %s %s or (%s = %s and id %s $5::uuid))))",
+			column, op, key, column, key, op)
+		args = append(args, opts.After.Pinned, opts.After.Key, opts.After.ID)
+	}
+	sql += fmt.Sprintf(" order by pinned desc, %s %s, id %s", column, dir, dir)
+	if opts.Limit > 0 {
+		sql += " limit " + strconv.Itoa(opts.Limit)
+	}
+	return sql, args
+}
+
+// SetNotePinned pins a note to the top of the profile of its owner, or unpins it. It returns an
+// error wrapping ErrNoRows if the user owns no such note.
+func (db *PostgresDB) SetNotePinned(noteID, userID string, pinned bool) error {
+	tag, err := db.pool.Exec(context.Background(), "update notes set pinned = $3 where id = $1 and user_id = $2", noteID, userID, pinned)
+	if err != nil {
+		return fmt.Errorf("set note pinned: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("set note pinned: %w", ErrNoRows)
+	}
+	return nil
+}
+
+// SetNotesManualOrder ranks the notes of a user in the order of noteIDs for SortManual, and
+// unranks the others, using the set_notes_manual_order function. Ids of notes the user does not
+// own are skipped.
+func (db *PostgresDB) SetNotesManualOrder(userID string, noteIDs []string) error {
+	_, err := db.pool.Exec(context.Background(), "select set_notes_manual_order($1, $2::uuid[])", userID, noteIDs)
+	if err != nil {
+		return fmt.Errorf("set notes manual order: %w", err)
+	}
+	return nil
+}
+
+// SetUserNotesSort sets how the notes on the profile of a user are sorted by default.
+func (db *PostgresDB) SetUserNotesSort(userID, sort string) error {
+	_, err := db.pool.Exec(context.Background(), "update users set notes_sort = $2 where id = $1", userID, sort)
+	if err != nil {
+		return fmt.Errorf("set user notes sort: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// pgRevisionColumns are the note_revisions columns, without the body, in the order scanRevision
+// expects them.
+const pgRevisionColumns = `id, note_id, user_id, title, updated_at, content_hash, coalesce(restored_from::text, ''), created_at`
+
+// scanRevision scans a row selected with pgRevisionColumns. If withBody is set, the row must have
+// the body selected after those columns.
+func scanRevision(row pgx.Row, withBody bool) (*NoteRevision, error) {
+	var revision NoteRevision
+	var updatedAt, createdAt time.Time
+	dest := []any{&revision.ID, &revision.NoteID, &revision.UserID, &revision.Title, &updatedAt,
+		&revision.ContentHash, &revision.RestoredFrom, &createdAt}
+	if withBody {
+		dest = append(dest, &revision.Body)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	revision.UpdatedAt = pgTime(updatedAt)
+	revision.CreatedAt = pgTime(createdAt)
+	return &revision, nil
+}
+
+// ListNoteRevisions returns the revisions of a user's note, newest first. It does not provide the
+// body of the revisions.
+func (db *PostgresDB) ListNoteRevisions(noteID, userID string) ([]NoteRevision, error) {
+	rows, err := db.pool.Query(context.Background(), `select `+pgRevisionColumns+` from note_revisions
+		where note_id = $1 and user_id = $2
+		order by created_at desc, id`, noteID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list note revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []NoteRevision{}
+	for rows.Next() {
+		revision, err := scanRevision(rows, false)
+		if err != nil {
+			return nil, fmt.Errorf("list note revisions: %w", err)
+		}
+		revisions = append(revisions, *revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list note revisions: %w", err)
+	}
+	return revisions, nil
+}
+
+// GetNoteRevision retrieves a revision of a user's note. It includes the body.
+func (db *PostgresDB) GetNoteRevision(revisionID, noteID, userID string) (*NoteRevision, error) {
+	revision, err := scanRevision(db.pool.QueryRow(context.Background(), `select `+pgRevisionColumns+`, body
+		from note_revisions where id = $1 and note_id = $2 and user_id = $3`, revisionID, noteID, userID), true)
+	if err != nil {
+		return nil, fmt.Errorf("get note revision: %w", err)
+	}
+	return revision, nil
+}
+
+// RestoreNoteRevision makes the title and body of a revision the current ones of its note, or its
+// published snapshot if it is deployed as one, using the restore_note_revision function, and
+// returns the revision recording the restore. The content hash of the note is left alone, so the
+// next sync only replaces the restored version if the note was edited in its source since.
+func (db *PostgresDB) RestoreNoteRevision(revisionID, noteID, userID string) (*NoteRevision, error) {
+	var restored *string
+	err := db.pool.QueryRow(context.Background(), "select restore_note_revision($1, $2, $3)::text",
+		userID, noteID, revisionID).Scan(&restored)
+	if err != nil {
+		return nil, fmt.Errorf("restore note revision: %w", err)
+	}
+	if restored == nil {
+		return nil, fmt.Errorf("restore note revision: %w", ErrNoRows)
+	}
+	return db.GetNoteRevision(*restored, noteID, userID)
+}
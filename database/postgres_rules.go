@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// listSyncRules returns the sync rules of a user, oldest first, querying with q.
+func listSyncRules(q interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}, userID string) ([]SyncRule, error) {
+	rows, err := q.Query(context.Background(), `select id, user_id, action, match, value, created_at
+		from sync_rules where user_id = $1 order by created_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := []SyncRule{}
+	var rule SyncRule
+	var createdAt time.Time
+	_, err = pgx.ForEachRow(rows, []any{&rule.ID, &rule.UserID, &rule.Action, &rule.Match, &rule.Value, &createdAt}, func() error {
+		rule.CreatedAt = pgTime(createdAt)
+		rules = append(rules, rule)
+		return nil
+	})
+	return rules, err
+}
+
+// ListSyncRules returns the sync rules of a user, oldest first.
+func (db *PostgresDB) ListSyncRules(userID string) ([]SyncRule, error) {
+	rules, err := listSyncRules(db.pool, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list sync rules: %w", err)
+	}
+	return rules, nil
+}
+
+// InsertSyncRule adds a sync rule for rule.UserID. The ID and created_at fields are populated by
+// the database.
+func (db *PostgresDB) InsertSyncRule(rule *SyncRule) error {
+	var createdAt time.Time
+	err := db.pool.QueryRow(context.Background(), `insert into sync_rules (user_id, action, match, value)
+		values ($1, $2, $3, $4)
+		returning id, created_at`, rule.UserID, rule.Action, rule.Match, rule.Value).Scan(&rule.ID, &createdAt)
+	if err != nil {
+		return fmt.Errorf("insert sync rule: %w", err)
+	}
+	rule.CreatedAt = pgTime(createdAt)
+	return nil
+}
+
+// DeleteSyncRule deletes a sync rule of a user. It returns an error wrapping ErrNoRows if the user
+// has no such rule.
+func (db *PostgresDB) DeleteSyncRule(ruleID, userID string) error {
+	tag, err := db.pool.Exec(context.Background(), "delete from sync_rules where id = $1 and user_id = $2", ruleID, userID)
+	if err != nil {
+		return fmt.Errorf("delete sync rule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("delete sync rule: %w", ErrNoRows)
+	}
+	return nil
+}
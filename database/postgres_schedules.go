@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// pgScheduleTime returns t as a timestamp parameter, null if it is zero.
+func pgScheduleTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// SetNoteSchedule replaces the schedule of a note. It returns an error wrapping ErrNoRows if the
+// user owns no such note.
+func (db *PostgresDB) SetNoteSchedule(noteID, userID string, schedule NoteSchedule) error {
+	tag, err := db.pool.Exec(context.Background(), "update notes set deploy_at = $3, expires_at = $4 where id = $1 and user_id = $2",
+		noteID, userID, pgScheduleTime(schedule.DeployAt), pgScheduleTime(schedule.ExpiresAt))
+	if err != nil {
+		return fmt.Errorf("set note schedule: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("set note schedule: %w", ErrNoRows)
+	}
+	return nil
+}
+
+// RunNoteSchedules deploys the notes scheduled to be deployed by now and undeploys the ones
+// expired by then, using the run_note_schedules function. It returns the notes it deployed or
+// undeployed.
+func (db *PostgresDB) RunNoteSchedules(now time.Time) ([]ScheduledChange, error) {
+	rows, err := db.pool.Query(context.Background(), "select note_id, user_id, action from run_note_schedules($1)", now)
+	if err != nil {
+		return nil, fmt.Errorf("run note schedules: %w", err)
+	}
+	changes, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (ScheduledChange, error) {
+		var change ScheduledChange
+		err := row.Scan(&change.NoteID, &change.UserID, &change.Action)
+		return change, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("run note schedules: %w", err)
+	}
+	return changes, nil
+}
+
+// NextNoteSchedule returns when the next schedule of a note is due, using the next_note_schedule
+// function. It returns the zero time if no note is scheduled.
+func (db *PostgresDB) NextNoteSchedule() (time.Time, error) {
+	var next *time.Time
+	if err := db.pool.QueryRow(context.Background(), "select next_note_schedule()").Scan(&next); err != nil {
+		return time.Time{}, fmt.Errorf("next note schedule: %w", err)
+	}
+	if next == nil {
+		return time.Time{}, nil
+	}
+	return *next, nil
+}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SearchNotes returns the notes of a user matching a full-text search over their title and body,
+// best first, using the search_notes function.
+func (db *PostgresDB) SearchNotes(userID string, opts SearchOptions) ([]SearchResult, error) {
+	rows, err := db.pool.Query(context.Background(), `select id, title, slug, deployed, updated_at, rank, title_highlight, snippet
+		from search_notes(@uid, @query, @for_public, @only_deployed, @updated_after, @updated_before, @page_limit, @page_offset)`,
+		pgx.NamedArgs(searchParams(userID, opts)))
+	if err != nil {
+		return nil, fmt.Errorf("search notes: %w", err)
+	}
+
+	results := []SearchResult{}
+	var result SearchResult
+	var updatedAt time.Time
+	var rank float32
+	_, err = pgx.ForEachRow(rows, []any{&result.ID, &result.Title, &result.Slug, &result.Deployed, &updatedAt,
+		&rank, &result.TitleHighlight, &result.Snippet}, func() error {
+		result.UpdatedAt = pgTime(updatedAt)
+		result.Rank = float64(rank)
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("search notes: %w", err)
+	}
+	return results, nil
+}
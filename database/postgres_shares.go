@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// pgShareColumns are the note_shares columns, without the token hash, in the order scanNoteShare
+// expects them.
+const pgShareColumns = `id, note_id, user_id, expires_at, max_views, views, revoked_at, created_at`
+
+// scanNoteShare scans a row selected with pgShareColumns.
+func scanNoteShare(row pgx.Row) (*NoteShare, error) {
+	var share NoteShare
+	var expiresAt, createdAt time.Time
+	var revokedAt *time.Time
+	err := row.Scan(&share.ID, &share.NoteID, &share.UserID, &expiresAt, &share.MaxViews, &share.Views, &revokedAt, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	share.ExpiresAt = pgTime(expiresAt)
+	share.CreatedAt = pgTime(createdAt)
+	if revokedAt != nil {
+		share.RevokedAt = pgTime(*revokedAt)
+	}
+	return &share, nil
+}
+
+// ListNoteShares returns the share links of a note of a user, newest first, without their tokens.
+func (db *PostgresDB) ListNoteShares(noteID, userID string) ([]NoteShare, error) {
+	rows, err := db.pool.Query(context.Background(), "select "+pgShareColumns+`
+		from note_shares where note_id = $1 and user_id = $2 order by created_at desc, id`, noteID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list note shares: %w", err)
+	}
+	shares, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (NoteShare, error) {
+		share, err := scanNoteShare(row)
+		if err != nil {
+			return NoteShare{}, err
+		}
+		return *share, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list note shares: %w", err)
+	}
+	if shares == nil {
+		shares = []NoteShare{}
+	}
+	return shares, nil
+}
+
+// InsertNoteShare adds a share link to share.NoteID. Its token is generated, and the ID and
+// created_at fields are populated by the database.
+func (db *PostgresDB) InsertNoteShare(share *NoteShare) error {
+	share.Token = newShareToken()
+	var createdAt time.Time
+	err := db.pool.QueryRow(context.Background(), `insert into note_shares (note_id, user_id, token_hash, expires_at, max_views)
+		values ($1, $2, $3, $4::timestamptz, $5)
+		returning id, created_at`, share.NoteID, share.UserID, shareTokenHash(share.Token), share.ExpiresAt, share.MaxViews).
+		Scan(&share.ID, &createdAt)
+	if err != nil {
+		return fmt.Errorf("insert note share: %w", err)
+	}
+	share.CreatedAt = pgTime(createdAt)
+	return nil
+}
+
+// RevokeNoteShare revokes a share link of a note of a user. It returns an error wrapping ErrNoRows
+// if the user has no such share, or if it is already revoked.
+func (db *PostgresDB) RevokeNoteShare(shareID, noteID, userID string) error {
+	tag, err := db.pool.Exec(context.Background(), `update note_shares set revoked_at = now()
+		where id = $1 and note_id = $2 and user_id = $3 and revoked_at is null`, shareID, noteID, userID)
+	if err != nil {
+		return fmt.Errorf("revoke note share: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("revoke note share: %w", ErrNoRows)
+	}
+	return nil
+}
+
+// UseNoteShare counts a view of the share link with token and returns it, using the
+// use_note_share function. It returns an error wrapping ErrNoRows if there is no such share, or
+// if it is revoked, expired or out of views.
+func (db *PostgresDB) UseNoteShare(token string) (*NoteShare, error) {
+	share, err := scanNoteShare(db.pool.QueryRow(context.Background(),
+		"select "+pgShareColumns+" from use_note_share($1)", shareTokenHash(token)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("use note share: %w", ErrNoRows)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("use note share: %w", err)
+	}
+	return share, nil
+}
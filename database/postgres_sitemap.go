@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ListSitemapUsers returns the users that are not noindex and have public notes that are not
+// noindex, by username, using the sitemap_users function.
+func (db *PostgresDB) ListSitemapUsers() ([]SitemapUser, error) {
+	rows, err := db.pool.Query(context.Background(), "select username, notes, last_modified from sitemap_users()")
+	if err != nil {
+		return nil, fmt.Errorf("list sitemap users: %w", err)
+	}
+	users := []SitemapUser{}
+	var user SitemapUser
+	var lastModified time.Time
+	_, err = pgx.ForEachRow(rows, []any{&user.Username, &user.Notes, &lastModified}, func() error {
+		user.LastModified = pgTime(lastModified)
+		users = append(users, user)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list sitemap users: %w", err)
+	}
+	return users, nil
+}
+
+// SetUserNoindex sets whether search engines should not index the profile and notes of a user.
+func (db *PostgresDB) SetUserNoindex(userID string, noindex bool) error {
+	_, err := db.pool.Exec(context.Background(), "update users set noindex = $2 where id = $1", userID, noindex)
+	return err
+}
+
+// SetNoteNoindex sets whether search engines should not index a note. It returns an error wrapping
+// ErrNoRows if the user owns no such note.
+func (db *PostgresDB) SetNoteNoindex(noteID, userID string, noindex bool) error {
+	tag, err := db.pool.Exec(context.Background(),
+		"update notes set noindex = $3 where id = $1 and user_id = $2", noteID, userID, noindex)
+	if err != nil {
+		return fmt.Errorf("set note noindex: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("set note noindex: %w", ErrNoRows)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ListTags returns the tags of a user's notes, most used first, using the list_tags function.
+// Deleted notes are not counted, and neither are notes that are not public if deployedOnly is set,
+// which counts the tags readers see.
+func (db *PostgresDB) ListTags(userID string, deployedOnly bool) ([]Tag, error) {
+	rows, err := db.pool.Query(context.Background(), "select name, notes from list_tags($1, $2)", userID, deployedOnly)
+	if err != nil {
+		return nil, fmt.Errorf("list tags: %w", err)
+	}
+	tags, err := pgx.CollectRows(rows, pgx.RowToStructByPos[Tag])
+	if err != nil {
+		return nil, fmt.Errorf("list tags: %w", err)
+	}
+	return tags, nil
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateUpload starts a chunked upload for upload.UserID, with upload.Live and upload.ExpiresAt
+// set. The ID and created_at fields are populated by the database.
+func (db *PostgresDB) CreateUpload(upload *Upload) error {
+	var createdAt, expiresAt time.Time
+	err := db.pool.QueryRow(context.Background(), `insert into sync_uploads (user_id, live, expires_at)
+		values ($1, $2, $3::timestamptz)
+		returning id, created_at, expires_at`,
+		upload.UserID, upload.Live, upload.ExpiresAt).Scan(&upload.ID, &createdAt, &expiresAt)
+	if err != nil {
+		return fmt.Errorf("create upload: %w", err)
+	}
+	upload.CreatedAt = pgTime(createdAt)
+	upload.ExpiresAt = pgTime(expiresAt)
+	upload.Chunks = []int{}
+	return nil
+}
+
+// GetUpload returns an upload of a user that has not expired, with the sequence numbers of its
+// received chunks.
+func (db *PostgresDB) GetUpload(uploadID, userID string) (*Upload, error) {
+	var upload Upload
+	var createdAt, expiresAt time.Time
+	err := db.pool.QueryRow(context.Background(), `select id, user_id, live, created_at, expires_at,
+			array(select seq from sync_upload_chunks where upload_id = u.id order by seq)
+		from sync_uploads u
+		where id = $1 and user_id = $2 and expires_at > now()`, uploadID, userID).
+		Scan(&upload.ID, &upload.UserID, &upload.Live, &createdAt, &expiresAt, &upload.Chunks)
+	if err != nil {
+		return nil, fmt.Errorf("get upload: %w", err)
+	}
+	upload.CreatedAt = pgTime(createdAt)
+	upload.ExpiresAt = pgTime(expiresAt)
+	return &upload, nil
+}
+
+// PutUploadChunk stores the notes of chunk seq of an upload, replacing the chunk if it was already
+// received.
+func (db *PostgresDB) PutUploadChunk(uploadID, userID string, seq int, notes []Note) error {
+	tag, err := db.pool.Exec(context.Background(), `insert into sync_upload_chunks (upload_id, seq, notes)
+		select id, $3, $4 from sync_uploads where id = $1 and user_id = $2 and expires_at > now()
+		on conflict (upload_id, seq) do update set notes = excluded.notes`,
+		uploadID, userID, seq, notes)
+	if err != nil {
+		return fmt.Errorf("put upload chunk: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("put upload chunk: %w", ErrNoRows)
+	}
+	return nil
+}
+
+// GetUploadNotes returns the notes of every received chunk of an upload, in chunk order.
+func (db *PostgresDB) GetUploadNotes(uploadID, userID string) ([]Note, error) {
+	if _, err := db.GetUpload(uploadID, userID); err != nil {
+		return nil, fmt.Errorf("get upload notes: %w", err)
+	}
+
+	rows, err := db.pool.Query(context.Background(),
+		"select notes from sync_upload_chunks where upload_id = $1 order by seq", uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("get upload notes: %w", err)
+	}
+	var notes []Note
+	var chunk []Note
+	_, err = pgx.ForEachRow(rows, []any{&chunk}, func() error {
+		notes = append(notes, chunk...)
+		chunk = nil
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get upload notes: %w", err)
+	}
+	return notes, nil
+}
+
+// DeleteUpload deletes an upload of a user and its chunks.
+func (db *PostgresDB) DeleteUpload(uploadID, userID string) error {
+	_, err := db.pool.Exec(context.Background(), "delete from sync_uploads where id = $1 and user_id = $2", uploadID, userID)
+	if err != nil {
+		return fmt.Errorf("delete upload: %w", err)
+	}
+	return nil
+}
+
+// PurgeExpiredUploads deletes the uploads that expired before expiredBefore, with their chunks.
+// It returns how many uploads were deleted.
+func (db *PostgresDB) PurgeExpiredUploads(expiredBefore time.Time) (int64, error) {
+	tag, err := db.pool.Exec(context.Background(), "delete from sync_uploads where expires_at < $1", expiredBefore)
+	if err != nil {
+		return 0, fmt.Errorf("purge expired uploads: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// pgUserColumns are the users columns in the order scanUser expects them.
+const pgUserColumns = `id, created_at, username, email_address, password_b64_hash, name, description,
+	profile_picture_url, twitter_username, instagram_username, github_username, has_connected_client, noindex, notes_sort`
+
+func scanUser(row pgx.Row) (*User, error) {
+	var user User
+	var createdAt time.Time
+	err := row.Scan(&user.ID, &createdAt, &user.Username, &user.Email, &user.HashedPassword, &user.Name,
+		&user.Description, &user.ProfilePictureURL, &user.TwitterUsername, &user.InstagramUsername,
+		&user.GithubUsername, &user.HasConnectedClient, &user.Noindex, &user.NotesSort)
+	if err != nil {
+		return nil, err
+	}
+	user.CreatedAt = pgTime(createdAt)
+	return &user, nil
+}
+
+// getting stuff
+
+// GetUserIDByUsername retrieves the user ID by their username.
+func (db *PostgresDB) GetUserIDByUsername(username string) (string, error) {
+	var id string
+	err := db.pool.QueryRow(context.Background(), "select id from users where username = $1", username).Scan(&id)
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// GetUserByID retrieves a user by their ID.
+func (db *PostgresDB) GetUserByID(userID string) (*User, error) {
+	return scanUser(db.pool.QueryRow(context.Background(), "select "+pgUserColumns+" from users where id = $1", userID))
+}
+
+// GetUserByEmail retrieves a user by their email address.
+func (db *PostgresDB) GetUserByEmail(email string) (*User, error) {
+	return scanUser(db.pool.QueryRow(context.Background(), "select "+pgUserColumns+" from users where email_address = $1", email))
+}
+
+// GetUserByUsername retrieves a user by their username.
+func (db *PostgresDB) GetUserByUsername(username string) (*User, error) {
+	return scanUser(db.pool.QueryRow(context.Background(), "select "+pgUserColumns+" from users where username = $1", username))
+}
+
+// UsernameExists checks if a username already exists in the database.
+func (db *PostgresDB) UsernameExists(username string) (bool, error) {
+	var exists bool
+	err := db.pool.QueryRow(context.Background(), "select exists (select 1 from users where username = $1)", username).Scan(&exists)
+	if err != nil {
+		return true, err // fail closed (assume exists)
+	}
+	return exists, nil
+}
+
+// profile stuff
+
+// UpdateName updates the name of a user in the database. It expects the user to have the new name set.
+func (db *PostgresDB) UpdateName(user *User) error {
+	_, err := db.pool.Exec(context.Background(), "update users set name = $2 where id = $1", user.ID, user.Name)
+	return err
+}
+
+// UpdateUserDescription updates the description of a user in the database. It expects the user
+// to have the new description set.
+func (db *PostgresDB) UpdateUserDescription(user *User) error {
+	_, err := db.pool.Exec(context.Background(), "update users set description = $2 where id = $1", user.ID, user.Description)
+	return err
+}
+
+// UpdateUserProfilePictureURL updates the profile picture URL of a user in the database.
+// It expects the user to have the new profile picture URL set.
+func (db *PostgresDB) UpdateUserProfilePictureURL(user *User) error {
+	_, err := db.pool.Exec(context.Background(), "update users set profile_picture_url = $2 where id = $1", user.ID, user.ProfilePictureURL)
+	return err
+}
+
+func (db *PostgresDB) UpdateUserSocials(user *User) error {
+	_, err := db.pool.Exec(context.Background(), `update users
+		set twitter_username = $2, instagram_username = $3, github_username = $4
+		where id = $1`,
+		user.ID, user.TwitterUsername, user.InstagramUsername, user.GithubUsername)
+	return err
+}
+
+func (db *PostgresDB) SetHasConnectedClient(userID string, hasConnected bool) error {
+	_, err := db.pool.Exec(context.Background(), "update users set has_connected_client = $2 where id = $1", userID, hasConnected)
+	return err
+}
+
+// SaveProfilePicture saves a profile picture in the profile_pictures table and returns its URL.
+func (db *PostgresDB) SaveProfilePicture(file io.Reader, name string) (string, error) {
+	ext := filepath.Ext(name)
+	if ext != ".png" && ext != ".jpg" && ext != ".jpeg" {
+		return "", fmt.Errorf("unsupported file type: %s", ext)
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, file); err != nil {
+		return "", fmt.Errorf("save profile picture: %w", err)
+	}
+
+	filename := newID() + ext
+	_, err := db.pool.Exec(context.Background(),
+		"insert into profile_pictures (filename, content_type, data) values ($1, $2, $3)",
+		filename, mime.TypeByExtension(ext), buf.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("save profile picture: %w", err)
+	}
+	return db.pfpsURL + "/" + filename, nil
+}
+
+// GetProfilePicture returns the contents and content type of a profile picture saved with
+// SaveProfilePicture.
+func (db *PostgresDB) GetProfilePicture(filename string) ([]byte, string, error) {
+	var data []byte
+	var contentType string
+	err := db.pool.QueryRow(context.Background(),
+		"select data, content_type from profile_pictures where filename = $1", filename).Scan(&data, &contentType)
+	if err != nil {
+		return nil, "", fmt.Errorf("get profile picture: %w", err)
+	}
+	return data, contentType, nil
+}
+
+// insert stuff
+
+// InsertUser inserts a new user into the database and populated the given user with its ID.
+func (db *PostgresDB) InsertUser(user *User) error {
+	inserted, err := scanUser(db.pool.QueryRow(context.Background(), `insert into users
+		(username, email_address, password_b64_hash, name, description, profile_picture_url,
+		twitter_username, instagram_username, github_username, has_connected_client)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		returning `+pgUserColumns,
+		user.Username, user.Email, user.HashedPassword, user.Name, user.Description, user.ProfilePictureURL,
+		user.TwitterUsername, user.InstagramUsername, user.GithubUsername, user.HasConnectedClient))
+	if err != nil {
+		return err
+	}
+	*user = *inserted
+	return nil
+}
@@ -0,0 +1,34 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// SetNoteVisibility sets the visibility of a note, deploying it live if it was private and
+// undeploying it if it becomes private. If passwordHash is not empty, it replaces the password of
+// the note. It returns an error wrapping ErrNoRows if the user owns no such note.
+func (db *PostgresDB) SetNoteVisibility(noteID, userID, visibility, passwordHash string) error {
+	tag, err := db.pool.Exec(context.Background(), `update notes
+		set visibility = $3,
+		    password_hash = case when $4 = '' then password_hash else $4 end,
+		    publish_mode = case when deployed then publish_mode else 'live' end
+		where id = $1 and user_id = $2`, noteID, userID, visibility, passwordHash)
+	if err != nil {
+		return fmt.Errorf("set note visibility: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("set note visibility: %w", ErrNoRows)
+	}
+	return nil
+}
+
+// GetNotePasswordHash returns the hash of the password of a note, empty if it never had one.
+func (db *PostgresDB) GetNotePasswordHash(noteID string) (string, error) {
+	var hash string
+	err := db.pool.QueryRow(context.Background(), "select password_hash from notes where id = $1", noteID).Scan(&hash)
+	if err != nil {
+		return "", fmt.Errorf("get note password hash: %w", err)
+	}
+	return hash, nil
+}
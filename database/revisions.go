@@ -0,0 +1,57 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// ListNoteRevisions returns the revisions of a user's note, newest first. It does not provide the
+// body of the revisions.
+func (db *DB) ListNoteRevisions(noteID, userID string) ([]NoteRevision, error) {
+	revisions := []NoteRevision{}
+	for page := 0; ; page++ {
+		var output []NoteRevision
+		_, err := db.client.From("note_revisions").Select("id,note_id,user_id,title,updated_at,content_hash,restored_from,created_at", "", false).
+			Eq("note_id", noteID).Eq("user_id", userID).Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+			Range(page*pageSize, (page+1)*pageSize-1, "").ExecuteTo(&output)
+		if err != nil {
+			return nil, fmt.Errorf("list note revisions: %w", err)
+		}
+		revisions = append(revisions, output...)
+		if len(output) < pageSize {
+			return revisions, nil
+		}
+	}
+}
+
+// GetNoteRevision retrieves a revision of a user's note. It includes the body.
+func (db *DB) GetNoteRevision(revisionID, noteID, userID string) (*NoteRevision, error) {
+	var revision NoteRevision
+	_, err := db.client.From("note_revisions").Select("*", "", false).
+		Eq("id", revisionID).Eq("note_id", noteID).Eq("user_id", userID).Single().ExecuteTo(&revision)
+	if err != nil {
+		return nil, fmt.Errorf("get note revision: %w", err)
+	}
+	return &revision, nil
+}
+
+// RestoreNoteRevision makes the title and body of a revision the current ones of its note, or its
+// published snapshot if it is deployed as one, using the restore_note_revision function, and
+// returns the revision recording the restore. The content hash of the note is left alone, so the
+// next sync only replaces the restored version if the note was edited in its source since.
+func (db *DB) RestoreNoteRevision(revisionID, noteID, userID string) (*NoteRevision, error) {
+	var restored *string
+	err := db.rpc("restore_note_revision", map[string]any{
+		"uid": userID,
+		"nid": noteID,
+		"rid": revisionID,
+	}, &restored)
+	if err != nil {
+		return nil, fmt.Errorf("restore note revision: %w", err)
+	}
+	if restored == nil {
+		return nil, fmt.Errorf("restore note revision: %w", ErrNoRows)
+	}
+	return db.GetNoteRevision(*restored, noteID, userID)
+}
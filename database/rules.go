@@ -0,0 +1,119 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SyncRule is a rule of a user applied to their notes on every sync: notes with a tag or in a
+// folder are deployed, undeployed or excluded from the sync.
+type SyncRule struct {
+	ID        string `json:"id,omitempty"`
+	UserID    string `json:"user_id"` // fk to users
+	Action    string `json:"action"`  // RuleDeploy, RuleUndeploy or RuleExclude
+	Match     string `json:"match"`   // MatchTag or MatchFolder
+	Value     string `json:"value"`   // the tag, or the id or name of the folder
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// Actions of a sync rule, from the one that wins over the others to the one that loses.
+const (
+	RuleExclude  = "exclude"  // the note is not synced, and treated as gone from its source
+	RuleUndeploy = "undeploy" // the note is undeployed
+	RuleDeploy   = "deploy"   // the note is deployed live, unless it already is deployed
+)
+
+// What a sync rule matches notes by.
+const (
+	MatchTag    = "tag"    // the note has the tag Value
+	MatchFolder = "folder" // the note is in the folder with the id or name (case insensitive) Value
+)
+
+// Normalize checks the action and match of rule and normalizes its value. It returns why the rule
+// is invalid, or an empty string.
+func (r *SyncRule) Normalize() string {
+	switch r.Action {
+	case RuleDeploy, RuleUndeploy, RuleExclude:
+	default:
+		return "invalid action, must be deploy, undeploy or exclude"
+	}
+	switch r.Match {
+	case MatchTag:
+		r.Value = NormalizeTag(r.Value)
+	case MatchFolder:
+		r.Value = strings.TrimSpace(r.Value)
+	default:
+		return "invalid match, must be tag or folder"
+	}
+	if r.Value == "" {
+		return "missing value"
+	}
+	return ""
+}
+
+// Matches reports whether note is matched by the rule. tags must be the tags of the note.
+func (r *SyncRule) Matches(note *Note, tags []string) bool {
+	switch r.Match {
+	case MatchTag:
+		return slices.Contains(tags, r.Value)
+	case MatchFolder:
+		return note.FolderID != "" && (note.FolderID == r.Value || strings.EqualFold(note.FolderName, r.Value))
+	}
+	return false
+}
+
+// MatchRule returns the rule of rules that applies to note: the first matching exclude rule, or
+// else the first matching undeploy rule, or else the first matching deploy rule. It returns nil
+// if no rule matches.
+func MatchRule(rules []SyncRule, note *Note) *SyncRule {
+	tags := ExtractTags(note.Body)
+	for _, action := range []string{RuleExclude, RuleUndeploy, RuleDeploy} {
+		for i := range rules {
+			if rules[i].Action == action && rules[i].Matches(note, tags) {
+				return &rules[i]
+			}
+		}
+	}
+	return nil
+}
+
+// ListSyncRules returns the sync rules of a user, oldest first.
+func (db *DB) ListSyncRules(userID string) ([]SyncRule, error) {
+	rules := []SyncRule{}
+	_, err := db.client.From("sync_rules").Select("*", "", false).Eq("user_id", userID).
+		Order("created_at", nil).ExecuteTo(&rules)
+	if err != nil {
+		return nil, fmt.Errorf("list sync rules: %w", err)
+	}
+	return rules, nil
+}
+
+// InsertSyncRule adds a sync rule for rule.UserID. The ID and created_at fields are populated by
+// the database.
+func (db *DB) InsertSyncRule(rule *SyncRule) error {
+	_, err := db.client.From("sync_rules").Insert(map[string]any{
+		"user_id": rule.UserID,
+		"action":  rule.Action,
+		"match":   rule.Match,
+		"value":   rule.Value,
+	}, false, "", "", "").Single().ExecuteTo(rule)
+	if err != nil {
+		return fmt.Errorf("insert sync rule: %w", err)
+	}
+	return nil
+}
+
+// DeleteSyncRule deletes a sync rule of a user. It returns an error wrapping ErrNoRows if the user
+// has no such rule.
+func (db *DB) DeleteSyncRule(ruleID, userID string) error {
+	var deleted []SyncRule
+	_, err := db.client.From("sync_rules").Delete("representation", "").Eq("id", ruleID).Eq("user_id", userID).ExecuteTo(&deleted)
+	if err != nil {
+		return fmt.Errorf("delete sync rule: %w", err)
+	}
+	if len(deleted) == 0 {
+		return fmt.Errorf("delete sync rule: %w", ErrNoRows)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// NoteSchedule is when a note is deployed and when it expires and is undeployed. Zero times are
+// not scheduled.
+type NoteSchedule struct {
+	DeployAt  time.Time
+	ExpiresAt time.Time
+}
+
+// Actions of a ScheduledChange.
+const (
+	ScheduleDeployed   = "deployed"
+	ScheduleUndeployed = "undeployed"
+)
+
+// ScheduledChange is a note deployed or undeployed when its schedule was run.
+type ScheduledChange struct {
+	NoteID string `json:"note_id"`
+	UserID string `json:"user_id"`
+	Action string `json:"action"` // ScheduleDeployed or ScheduleUndeployed
+}
+
+// scheduleTime returns t as a timestamp to store, nil if it is zero.
+func scheduleTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// SetNoteSchedule replaces the schedule of a note. It returns an error wrapping ErrNoRows if the
+// user owns no such note.
+func (db *DB) SetNoteSchedule(noteID, userID string, schedule NoteSchedule) error {
+	_, count, err := db.client.From("notes").Update(map[string]any{
+		"deploy_at":  scheduleTime(schedule.DeployAt),
+		"expires_at": scheduleTime(schedule.ExpiresAt),
+	}, "minimal", "exact").Eq("id", noteID).Eq("user_id", userID).Execute()
+	if err != nil {
+		return fmt.Errorf("set note schedule: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("set note schedule: %w", ErrNoRows)
+	}
+	return nil
+}
+
+// RunNoteSchedules deploys the notes scheduled to be deployed by now and undeploys the ones
+// expired by then, using the run_note_schedules function. It returns the notes it deployed or
+// undeployed.
+func (db *DB) RunNoteSchedules(now time.Time) ([]ScheduledChange, error) {
+	var changes []ScheduledChange
+	err := db.rpc("run_note_schedules", map[string]any{
+		"run_until": now.UTC().Format(time.RFC3339Nano),
+	}, &changes)
+	if err != nil {
+		return nil, fmt.Errorf("run note schedules: %w", err)
+	}
+	return changes, nil
+}
+
+// NextNoteSchedule returns when the next schedule of a note is due, using the next_note_schedule
+// function. It returns the zero time if no note is scheduled.
+func (db *DB) NextNoteSchedule() (time.Time, error) {
+	var next *string
+	if err := db.rpc("next_note_schedule", map[string]any{}, &next); err != nil {
+		return time.Time{}, fmt.Errorf("next note schedule: %w", err)
+	}
+	if next == nil {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, *next)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse next note schedule: %w", err)
+	}
+	return t, nil
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SearchOptions are the query, filters and page of a note search.
+type SearchOptions struct {
+	Query string // web search style: words, "phrases", or, -excluded
+
+	Public        bool      // only search the public notes, as their readers see them
+	Deployed      *bool     // only search the notes that are (or are not) deployed, if set
+	UpdatedAfter  time.Time // only search the notes updated at or after this, if not zero
+	UpdatedBefore time.Time // only search the notes updated before this, if not zero
+
+	Limit  int
+	Offset int
+}
+
+// SearchResult is a note matching a search. TitleHighlight and Snippet are html, with the matches
+// wrapped in <mark>.
+type SearchResult struct {
+	ID             string  `json:"id"`
+	Title          string  `json:"title"`
+	Slug           string  `json:"slug"`
+	Deployed       bool    `json:"deployed"`
+	UpdatedAt      string  `json:"updated_at"`
+	Rank           float64 `json:"rank"`
+	TitleHighlight string  `json:"title_highlight"`
+	Snippet        string  `json:"snippet"`
+}
+
+var (
+	htmlBreakRegexp = regexp.MustCompile(`(?i)<(br|/p|/div|/li|/h[1-6])(\s[^>]*)?/?>`)
+	htmlTagRegexp   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// stripHTML returns the text of an html body, with entities other than &nbsp; left encoded so that
+// it can still be shown as html. It must match the strip_html function of the migrations.
+func stripHTML(html string) string {
+	s := htmlBreakRegexp.ReplaceAllString(html, " ")
+	s = htmlTagRegexp.ReplaceAllString(s, "")
+	return strings.ReplaceAll(s, "&nbsp;", " ")
+}
+
+// searchParams returns the arguments of the search_notes function.
+func searchParams(userID string, opts SearchOptions) map[string]any {
+	params := map[string]any{
+		"uid":         userID,
+		"query":       opts.Query,
+		"for_public":  opts.Public,
+		"page_limit":  opts.Limit,
+		"page_offset": opts.Offset,
+	}
+	if opts.Deployed != nil {
+		params["only_deployed"] = *opts.Deployed
+	}
+	if !opts.UpdatedAfter.IsZero() {
+		params["updated_after"] = opts.UpdatedAfter
+	}
+	if !opts.UpdatedBefore.IsZero() {
+		params["updated_before"] = opts.UpdatedBefore
+	}
+	return params
+}
+
+// SearchNotes returns the notes of a user matching a full-text search over their title and body,
+// best first, using the search_notes function.
+func (db *DB) SearchNotes(userID string, opts SearchOptions) ([]SearchResult, error) {
+	results := []SearchResult{}
+	if err := db.rpc("search_notes", searchParams(userID, opts), &results); err != nil {
+		return nil, fmt.Errorf("search notes: %w", err)
+	}
+	return results, nil
+}
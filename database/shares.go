@@ -0,0 +1,93 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+)
+
+// NoteShare is a link showing a note to whoever has its token, even if the note is not deployed,
+// until it expires, runs out of views or is revoked.
+type NoteShare struct {
+	ID        string `json:"id"`
+	NoteID    string `json:"note_id"`         // fk to notes
+	UserID    string `json:"user_id"`         // fk to users, the owner of the note
+	Token     string `json:"token,omitempty"` // only known when the share is created, its hash is stored
+	ExpiresAt string `json:"expires_at"`
+	MaxViews  int64  `json:"max_views"` // 0 for no limit
+	Views     int64  `json:"views"`
+	RevokedAt string `json:"revoked_at,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// newShareToken returns a random token for a share link.
+func newShareToken() string {
+	return strings.ToLower(strings.TrimRight(randomB32(20), "="))
+}
+
+// shareTokenHash returns the hash stored for the token of a share link.
+func shareTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ListNoteShares returns the share links of a note of a user, newest first, without their tokens.
+func (db *DB) ListNoteShares(noteID, userID string) ([]NoteShare, error) {
+	shares := []NoteShare{}
+	_, err := db.client.From("note_shares").Select("id,note_id,user_id,expires_at,max_views,views,revoked_at,created_at", "", false).
+		Eq("note_id", noteID).Eq("user_id", userID).Order("created_at", &postgrest.OrderOpts{Ascending: false}).ExecuteTo(&shares)
+	if err != nil {
+		return nil, fmt.Errorf("list note shares: %w", err)
+	}
+	return shares, nil
+}
+
+// InsertNoteShare adds a share link to share.NoteID. Its token is generated, and the ID and
+// created_at fields are populated by the database.
+func (db *DB) InsertNoteShare(share *NoteShare) error {
+	share.Token = newShareToken()
+	_, err := db.client.From("note_shares").Insert(map[string]any{
+		"note_id":    share.NoteID,
+		"user_id":    share.UserID,
+		"token_hash": shareTokenHash(share.Token),
+		"expires_at": share.ExpiresAt,
+		"max_views":  share.MaxViews,
+	}, false, "", "", "").Single().ExecuteTo(share)
+	if err != nil {
+		return fmt.Errorf("insert note share: %w", err)
+	}
+	return nil
+}
+
+// RevokeNoteShare revokes a share link of a note of a user. It returns an error wrapping ErrNoRows
+// if the user has no such share, or if it is already revoked.
+func (db *DB) RevokeNoteShare(shareID, noteID, userID string) error {
+	_, count, err := db.client.From("note_shares").Update(map[string]any{
+		"revoked_at": time.Now().UTC().Format(time.RFC3339Nano),
+	}, "minimal", "exact").Eq("id", shareID).Eq("note_id", noteID).Eq("user_id", userID).Is("revoked_at", "null").Execute()
+	if err != nil {
+		return fmt.Errorf("revoke note share: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("revoke note share: %w", ErrNoRows)
+	}
+	return nil
+}
+
+// UseNoteShare counts a view of the share link with token and returns it, using the
+// use_note_share function. It returns an error wrapping ErrNoRows if there is no such share, or
+// if it is revoked, expired or out of views.
+func (db *DB) UseNoteShare(token string) (*NoteShare, error) {
+	var shares []NoteShare
+	if err := db.rpc("use_note_share", map[string]any{"hash": shareTokenHash(token)}, &shares); err != nil {
+		return nil, fmt.Errorf("use note share: %w", err)
+	}
+	if len(shares) == 0 {
+		return nil, fmt.Errorf("use note share: %w", ErrNoRows)
+	}
+	return &shares[0], nil
+}
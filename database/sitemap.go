@@ -0,0 +1,46 @@
+package database
+
+import (
+	"fmt"
+)
+
+// SitemapUser is a user with deployed notes that search engines can index, with how many there
+// are and when the last one changed for its readers.
+type SitemapUser struct {
+	Username     string `json:"username"`
+	Notes        int64  `json:"notes"`
+	LastModified string `json:"last_modified"`
+}
+
+// ListSitemapUsers returns the users that are not noindex and have public notes that are not
+// noindex, by username, using the sitemap_users function.
+func (db *DB) ListSitemapUsers() ([]SitemapUser, error) {
+	users := []SitemapUser{}
+	if err := db.rpc("sitemap_users", map[string]any{}, &users); err != nil {
+		return nil, fmt.Errorf("list sitemap users: %w", err)
+	}
+	return users, nil
+}
+
+// SetUserNoindex sets whether search engines should not index the profile and notes of a user.
+func (db *DB) SetUserNoindex(userID string, noindex bool) error {
+	_, _, err := db.client.From("users").Update(map[string]bool{
+		"noindex": noindex,
+	}, "", "").Eq("id", userID).Execute()
+	return err
+}
+
+// SetNoteNoindex sets whether search engines should not index a note. It returns an error wrapping
+// ErrNoRows if the user owns no such note.
+func (db *DB) SetNoteNoindex(noteID, userID string, noindex bool) error {
+	_, count, err := db.client.From("notes").Update(map[string]bool{
+		"noindex": noindex,
+	}, "minimal", "exact").Eq("id", noteID).Eq("user_id", userID).Execute()
+	if err != nil {
+		return fmt.Errorf("set note noindex: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("set note noindex: %w", ErrNoRows)
+	}
+	return nil
+}
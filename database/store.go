@@ -0,0 +1,147 @@
+package database
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNoRows is returned by stores when a lookup that expects exactly one row finds none. The
+// message matches the one returned by postgres drivers so that callers can treat both the same.
+var ErrNoRows = errors.New("no rows in result set")
+
+// Store is the set of database operations used by the backend. It is implemented by DB (supabase),
+// PostgresDB (plain postgres) and MemoryDB (in-memory, for running offline and in tests).
+type Store interface {
+	// users
+
+	GetUserIDByUsername(username string) (string, error)
+	GetUserByID(userID string) (*User, error)
+	GetUserByEmail(email string) (*User, error)
+	GetUserByUsername(username string) (*User, error)
+	UsernameExists(username string) (bool, error)
+	UpdateName(user *User) error
+	UpdateUserDescription(user *User) error
+	UpdateUserProfilePictureURL(user *User) error
+	UpdateUserSocials(user *User) error
+	SetHasConnectedClient(userID string, hasConnected bool) error
+	SetUserNoindex(userID string, noindex bool) error
+	SetUserNotesSort(userID, sort string) error
+	SaveProfilePicture(file io.Reader, name string) (string, error)
+	GetProfilePicture(filename string) ([]byte, string, error)
+	InsertUser(user *User) error
+
+	// notes
+
+	CountNotes(userID string) (int64, error)
+	GetSourceIdentifiersByUserID(userID string) ([]string, error)
+	GetNoteByID(noteID string) (*Note, error)
+	GetNoteBySlug(username, slug string) (*Note, error)
+	ListNotes(userID string, opts ListOptions) ([]Note, error)
+	ListDeployedNotes(userID string, opts ListOptions) ([]Note, error)
+	IncrementNoteViews(noteID string) error
+	InsertNote(note *Note) error
+	DeployNote(noteID, userID string) error
+	PublishNote(noteID, userID string) error
+	UndeployNote(noteID, userID string) error
+	UpdateNote(note *Note) error
+	InsertNotesForUser(userID string, notes []Note, live map[string][]string) ([]SyncResult, error)
+	PurgeDeletedNotes(deletedBefore time.Time) (int64, error)
+	GetNoteManifest(userID, source string) ([]ManifestEntry, error)
+	SearchNotes(userID string, opts SearchOptions) ([]SearchResult, error)
+	SetNoteNoindex(noteID, userID string, noindex bool) error
+	SetNotePinned(noteID, userID string, pinned bool) error
+	SetNotesManualOrder(userID string, noteIDs []string) error
+
+	// visibility
+
+	SetNoteVisibility(noteID, userID, visibility, passwordHash string) error
+	GetNotePasswordHash(noteID string) (string, error)
+
+	// schedules
+
+	SetNoteSchedule(noteID, userID string, schedule NoteSchedule) error
+	RunNoteSchedules(now time.Time) ([]ScheduledChange, error)
+	NextNoteSchedule() (time.Time, error)
+
+	// share links
+
+	ListNoteShares(noteID, userID string) ([]NoteShare, error)
+	InsertNoteShare(share *NoteShare) error
+	RevokeNoteShare(shareID, noteID, userID string) error
+	UseNoteShare(token string) (*NoteShare, error)
+
+	// collections
+
+	ListCollections(userID string) ([]Collection, error)
+	GetCollectionBySlug(userID, slug string) (*Collection, error)
+	InsertCollection(collection *Collection) error
+	UpdateCollection(collection *Collection) error
+	DeleteCollection(collectionID, userID string) error
+	SetCollectionNotes(collectionID, userID string, noteIDs []string) error
+
+	// Open Graph images
+
+	GetOGImage(noteID, key string) ([]byte, error)
+	SaveOGImage(noteID, key string, data []byte) error
+
+	// custom domains
+
+	GetCustomDomain(userID string) (*CustomDomain, error)
+	SetCustomDomain(domain *CustomDomain) error
+	VerifyCustomDomain(userID string) (*CustomDomain, error)
+	DeleteCustomDomain(userID string) error
+	GetUserByCustomDomain(domain string) (*User, error)
+
+	// sitemaps
+
+	ListSitemapUsers() ([]SitemapUser, error)
+
+	// folders
+
+	ListFolders(userID string) ([]Folder, error)
+	DeployFolder(folderID, userID string) (int64, error)
+	UndeployFolder(folderID, userID string) (int64, error)
+
+	// tags
+
+	ListTags(userID string, deployedOnly bool) ([]Tag, error)
+
+	// sync rules
+
+	ListSyncRules(userID string) ([]SyncRule, error)
+	InsertSyncRule(rule *SyncRule) error
+	DeleteSyncRule(ruleID, userID string) error
+
+	// revisions
+
+	ListNoteRevisions(noteID, userID string) ([]NoteRevision, error)
+	GetNoteRevision(revisionID, noteID, userID string) (*NoteRevision, error)
+	RestoreNoteRevision(revisionID, noteID, userID string) (*NoteRevision, error)
+
+	// chunked uploads
+
+	CreateUpload(upload *Upload) error
+	GetUpload(uploadID, userID string) (*Upload, error)
+	PutUploadChunk(uploadID, userID string, seq int, notes []Note) error
+	GetUploadNotes(uploadID, userID string) ([]Note, error)
+	DeleteUpload(uploadID, userID string) error
+	PurgeExpiredUploads(expiredBefore time.Time) (int64, error)
+
+	// activities
+
+	GetActivities(userID string, loadTime time.Time, offset, limit int) ([]Activity, error)
+	GetLastActivityByType(userID, activityType string) (*Activity, error)
+	InsertActivity(activity *Activity) error
+
+	// stats
+
+	GetUserStats(userID string) (string, error)
+}
+
+// make sure the implementations satisfy the interface
+var (
+	_ Store = (*DB)(nil)
+	_ Store = (*PostgresDB)(nil)
+	_ Store = (*MemoryDB)(nil)
+)
@@ -0,0 +1,317 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// SyncStatus is the outcome of syncing a single note with InsertNotesForUser.
+type SyncStatus string
+
+const (
+	SyncInserted  SyncStatus = "inserted"  // the note did not exist and was inserted
+	SyncUpdated   SyncStatus = "updated"   // the note existed and some of its fields changed
+	SyncUnchanged SyncStatus = "unchanged" // the note existed and nothing changed
+	SyncFailed    SyncStatus = "failed"    // the note was rejected, see Reason
+	SyncDeleted   SyncStatus = "deleted"   // the note is gone from its source and was marked as deleted
+	SyncExcluded  SyncStatus = "excluded"  // the note matches an exclude rule and was not synced, see RuleID
+)
+
+// statuses of the rows of the sync_notes function reporting what the deploy and undeploy rules did
+// to a note, merged into the result of the note
+const (
+	syncRuleDeployed   SyncStatus = "rule_deployed"
+	syncRuleUndeployed SyncStatus = "rule_undeployed"
+)
+
+// ManifestEntry is what the server stores about a synced note. Clients compare it with their notes
+// to only upload the ones that are new or changed.
+type ManifestEntry struct {
+	Source           string `json:"source"`
+	SourceIdentifier string `json:"source_identifier"`
+	UpdatedAt        string `json:"updated_at"`
+	ContentHash      string `json:"content_hash"`
+	FolderID         string `json:"folder_id"`
+	FolderName       string `json:"folder_name"`
+}
+
+// ContentHash returns the hash identifying the title and body of a note: the hex sha256 of the
+// byte length of the title, a colon, the title and the body. The notes table computes the same
+// hash in its migrations, keep them in sync.
+func ContentHash(note *Note) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d:%s%s", len(note.Title), note.Title, note.Body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// SyncRequest is the body of a sync. Older clients send only the array of notes.
+type SyncRequest struct {
+	Notes []Note `json:"notes"`
+
+	// Live maps a source to the source identifiers of every note that currently exists in it,
+	// including the ones not sent in Notes. Stored notes of a listed source that are missing from
+	// its list are marked as deleted. Sources that are not listed are left alone.
+	Live map[string][]string `json:"live,omitempty"`
+}
+
+// Upload is a chunked sync in progress. Its notes are uploaded in numbered chunks, which can be
+// sent again until the upload is committed, and are then synced all at once with its live map.
+type Upload struct {
+	ID        string              `json:"id,omitempty"`
+	UserID    string              `json:"user_id"` // fk to users
+	Live      map[string][]string `json:"live"`    // see SyncRequest.Live
+	CreatedAt string              `json:"created_at,omitempty"`
+	ExpiresAt string              `json:"expires_at"` // the upload and its chunks are purged after this
+
+	Chunks []int `json:"chunks"` // sequence numbers of the received chunks, in order (not a column)
+}
+
+// SyncResult reports what InsertNotesForUser did with one of the notes it was given.
+type SyncResult struct {
+	Source           string     `json:"source"`
+	SourceIdentifier string     `json:"source_identifier"`
+	ID               string     `json:"id,omitempty"` // id of the stored note, empty if failed
+	Status           SyncStatus `json:"status"`
+	Reason           string     `json:"reason,omitempty"` // why the note failed
+
+	RuleID     string `json:"rule_id,omitempty"`    // the sync rule that excluded, deployed or undeployed the note
+	Deployed   bool   `json:"deployed,omitempty"`   // the note was deployed by a rule
+	Undeployed bool   `json:"undeployed,omitempty"` // the note was undeployed by a rule, or because it was deleted
+}
+
+// syncTimestampLayouts are the accepted formats of a synced note's created_at and updated_at.
+// The second one is what AppleScript's «class isot» produces (no time zone, read as UTC).
+var syncTimestampLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05"}
+
+func validSyncTimestamp(s string) bool {
+	if s == "" {
+		return true // defaults to the time of the sync
+	}
+	for _, layout := range syncTimestampLayouts {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// syncKey identifies a note of a user during a sync.
+type syncKey struct {
+	source           string
+	sourceIdentifier string
+}
+
+func keyOf(note *Note) syncKey {
+	return syncKey{source: note.Source, sourceIdentifier: note.SourceIdentifier}
+}
+
+// syncBatch is a validated set of notes ready to be written by a store in one transaction.
+type syncBatch struct {
+	notes    []Note       // valid notes, with user_id set and slugs assigned to the ones that are new
+	results  []SyncResult // one per note given to newSyncBatch, failed and excluded ones are already filled in
+	index    map[syncKey]int
+	rules    map[syncKey]*SyncRule // deploy or undeploy rule matching each note, if any
+	excluded map[syncKey]bool
+}
+
+// newSyncBatch validates notes for a sync, leaves out the ones matching an exclude rule of rules
+// and assigns unique slugs to the new ones. existing maps the source and source identifier of the
+// user's stored notes to their slugs.
+func newSyncBatch(userID string, notes []Note, existing map[syncKey]string, rules []SyncRule) *syncBatch {
+	b := &syncBatch{
+		results:  make([]SyncResult, len(notes)),
+		index:    make(map[syncKey]int, len(notes)),
+		rules:    make(map[syncKey]*SyncRule),
+		excluded: make(map[syncKey]bool),
+	}
+
+	taken := make(map[string]bool, len(existing))
+	for _, slug := range existing {
+		taken[slug] = true
+	}
+
+	for i, note := range notes {
+		b.results[i] = SyncResult{Source: note.Source, SourceIdentifier: note.SourceIdentifier}
+		if reason := b.reject(note); reason != "" {
+			b.results[i].Status = SyncFailed
+			b.results[i].Reason = reason
+			continue
+		}
+
+		rule := MatchRule(rules, &note)
+		if rule != nil && rule.Action == RuleExclude {
+			b.results[i].Status = SyncExcluded
+			b.results[i].RuleID = rule.ID
+			b.excluded[keyOf(&note)] = true
+			continue
+		}
+		if rule != nil {
+			b.rules[keyOf(&note)] = rule
+		}
+
+		note.UserID = userID
+		note.ContentHash = ContentHash(&note)
+		if slug, ok := existing[keyOf(&note)]; ok {
+			note.Slug = slug // slugs of existing notes never change on sync
+		} else {
+			note.Slug = uniqueSlug(note.Title, taken)
+			taken[note.Slug] = true
+		}
+
+		b.index[keyOf(&note)] = i
+		b.notes = append(b.notes, note)
+	}
+	return b
+}
+
+// reject returns why note cannot be synced, or an empty string if it can.
+func (b *syncBatch) reject(note Note) string {
+	switch {
+	case note.Source == "":
+		return "missing source"
+	case note.SourceIdentifier == "":
+		return "missing source_identifier"
+	case !validSyncTimestamp(note.CreatedAt):
+		return fmt.Sprintf("invalid created_at %q", note.CreatedAt)
+	case !validSyncTimestamp(note.UpdatedAt):
+		return fmt.Sprintf("invalid updated_at %q", note.UpdatedAt)
+	}
+	if _, ok := b.index[keyOf(&note)]; ok || b.excluded[keyOf(&note)] {
+		return "duplicate source and source_identifier in this sync"
+	}
+	return ""
+}
+
+// set records the outcome of a note of the batch.
+func (b *syncBatch) set(key syncKey, id string, status SyncStatus) {
+	if i, ok := b.index[key]; ok {
+		b.results[i].ID = id
+		b.results[i].Status = status
+	}
+}
+
+// syncRow is a row returned by the sync_notes function.
+type syncRow struct {
+	NoteID           string     `json:"note_id"`
+	Source           string     `json:"note_source"`
+	SourceIdentifier string     `json:"note_source_identifier"`
+	Status           SyncStatus `json:"sync_status"`
+	Undeployed       bool       `json:"note_undeployed"`
+}
+
+// apply records the outcomes returned by the sync_notes function.
+func (b *syncBatch) apply(rows []syncRow) {
+	for _, row := range rows {
+		key := syncKey{source: row.Source, sourceIdentifier: row.SourceIdentifier}
+		switch row.Status {
+		case SyncDeleted:
+			b.deleted(key, row.NoteID, row.Undeployed)
+		case syncRuleDeployed, syncRuleUndeployed:
+			b.ruled(key, row.Status == syncRuleDeployed)
+		default:
+			b.set(key, row.NoteID, row.Status)
+		}
+	}
+}
+
+// ruleDeploy returns whether the rule matching a note of the batch wants it deployed, or nil if
+// no deploy or undeploy rule matches it.
+func (b *syncBatch) ruleDeploy(key syncKey) *bool {
+	rule, ok := b.rules[key]
+	if !ok {
+		return nil
+	}
+	deploy := rule.Action == RuleDeploy
+	return &deploy
+}
+
+// ruled records that the rule matching a note of the batch deployed or undeployed it.
+func (b *syncBatch) ruled(key syncKey, deployed bool) {
+	if i, ok := b.index[key]; ok {
+		b.results[i].RuleID = b.rules[key].ID
+		b.results[i].Deployed = deployed
+		b.results[i].Undeployed = !deployed
+	}
+}
+
+// live returns the live map of a sync without the notes excluded by a rule, so that their stored
+// copies, synced before the rule existed, are marked as deleted.
+func (b *syncBatch) live(live map[string][]string) map[string][]string {
+	if live == nil || len(b.excluded) == 0 {
+		return live
+	}
+	filtered := make(map[string][]string, len(live))
+	for source, ids := range live {
+		filtered[source] = slices.DeleteFunc(slices.Clone(ids), func(id string) bool {
+			return b.excluded[syncKey{source: source, sourceIdentifier: id}]
+		})
+	}
+	return filtered
+}
+
+// deleted records a note that was marked as deleted. Deleted notes are reported after the notes
+// of the batch.
+func (b *syncBatch) deleted(key syncKey, id string, undeployed bool) {
+	b.results = append(b.results, SyncResult{
+		Source:           key.source,
+		SourceIdentifier: key.sourceIdentifier,
+		ID:               id,
+		Status:           SyncDeleted,
+		Undeployed:       undeployed,
+	})
+}
+
+// liveSet returns the notes of a sync's live map as a set.
+func liveSet(live map[string][]string) map[syncKey]bool {
+	set := make(map[syncKey]bool)
+	for source, ids := range live {
+		for _, id := range ids {
+			set[syncKey{source: source, sourceIdentifier: id}] = true
+		}
+	}
+	return set
+}
+
+// payload returns the notes of the batch as the payload of the sync_notes function.
+func (b *syncBatch) payload() []map[string]any {
+	payload := make([]map[string]any, 0, len(b.notes))
+	for _, note := range b.notes {
+		payload = append(payload, map[string]any{
+			"source":            note.Source,
+			"source_identifier": note.SourceIdentifier,
+			"created_at":        nullIfEmpty(note.CreatedAt),
+			"updated_at":        nullIfEmpty(note.UpdatedAt),
+			"title":             note.Title,
+			"slug":              note.Slug,
+			"body":              note.Body,
+			"content_hash":      note.ContentHash,
+			"folder_id":         note.FolderID,
+			"folder_name":       note.FolderName,
+			"rule_deploy":       b.ruleDeploy(keyOf(&note)),
+		})
+	}
+	return payload
+}
+
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// uniqueSlug returns a slug for title that is not in taken.
+func uniqueSlug(title string, taken map[string]bool) string {
+	base := slugify(title)
+	if base == "" { // if slug is still empty, generate a random slug
+		base = randomB32(5)
+	}
+	slug := base
+	for taken[slug] { // if the slug already exists, we will add a random suffix to it
+		slug = fmt.Sprintf("%s-%s", base, randomB32(5))
+	}
+	return slug
+}
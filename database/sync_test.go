@@ -0,0 +1,63 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestInsertNotesForUserKeepsUsersAndSourcesApart(t *testing.T) {
+	m := Memory("")
+	sync := func(userID string, notes ...Note) []SyncResult {
+		t.Helper()
+		results, err := m.InsertNotesForUser(userID, notes, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range results {
+			if r.Status != SyncInserted {
+				t.Fatalf("got %s for %s of %s, want %s", r.Status, r.SourceIdentifier, userID, SyncInserted)
+			}
+		}
+		return results
+	}
+
+	alice := sync("alice", Note{Source: "apple-notes", SourceIdentifier: "x1", Title: "Alice's", Body: "alice"})
+	bob := sync("bob", Note{Source: "apple-notes", SourceIdentifier: "x1", Title: "Bob's", Body: "bob"})
+	other := sync("alice", Note{Source: "other", SourceIdentifier: "x1", Title: "Other", Body: "other"})
+
+	if bob[0].ID == alice[0].ID || other[0].ID == alice[0].ID {
+		t.Fatalf("got ids %s, %s and %s, want three notes", alice[0].ID, bob[0].ID, other[0].ID)
+	}
+	for id, want := range map[string]struct{ userID, body string }{
+		alice[0].ID: {"alice", "alice"},
+		bob[0].ID:   {"bob", "bob"},
+		other[0].ID: {"alice", "other"},
+	} {
+		note, err := m.GetNoteByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if note.UserID != want.userID || note.Body != want.body {
+			t.Errorf("note %s: got user %s and body %q, want user %s and body %q", id, note.UserID, note.Body, want.userID, want.body)
+		}
+	}
+}
+
+func TestInsertNotesForUserIgnoresServerOwnedFields(t *testing.T) {
+	m := Memory("")
+	results, err := m.InsertNotesForUser("alice", []Note{{
+		ID: "00000000-0000-0000-0000-000000000000", UserID: "bob", Source: "apple-notes", SourceIdentifier: "x1",
+		Title: "Hello", Body: "hello", Deployed: true, Views: 42,
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	note, err := m.GetNoteByID(results[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note.ID == "00000000-0000-0000-0000-000000000000" || note.UserID != "alice" || note.Deployed || note.Views != 0 {
+		t.Errorf("got id %s, user %s, deployed %t and %d views, want a new undeployed note of alice without views",
+			note.ID, note.UserID, note.Deployed, note.Views)
+	}
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Tag is a #hashtag used in the body of a user's notes, with how many of the notes have it.
+type Tag struct {
+	Name  string `json:"name"`
+	Notes int64  `json:"notes"`
+}
+
+// ListOptions filter and sort the notes returned by ListNotes and ListDeployedNotes.
+type ListOptions struct {
+	Tag string // only list the notes with this tag, if set
+
+	Sort      string      // SortUpdated, SortCreated, SortViews or SortManual, pinned notes first; unsorted if empty
+	Ascending bool        // sort in ascending instead of descending order, pinned notes still first
+	Limit     int         // list at most this many notes, all if 0
+	After     *ListCursor // only list the notes after this one, in the same sort
+
+	Bodies bool // also provide the bodies of the notes, which are left out by default
+}
+
+// maxTagLength is the length in characters past which tags are cut.
+const maxTagLength = 64
+
+// hashtagRegexp matches a hashtag that does not follow a word, an & (entities), a / (links) or
+// another #.
+var hashtagRegexp = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&/#])#([\p{L}\p{N}_][\p{L}\p{N}_-]*)`)
+
+// ExtractTags returns the lower case tags of an html body, sorted and without duplicates. Tags must
+// have a letter. It must match the note_hashtags function of the migrations.
+func ExtractTags(body string) []string {
+	tags := []string{}
+	for _, m := range hashtagRegexp.FindAllStringSubmatch(stripHTML(body), -1) {
+		tag := []rune(strings.ToLower(strings.TrimRight(m[1], "-")))
+		tag = tag[:min(len(tag), maxTagLength)]
+		if slices.ContainsFunc(tag, unicode.IsLetter) {
+			tags = append(tags, string(tag))
+		}
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+// NormalizeTag returns tag as stored: lower case, without a leading #.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+// ListTags returns the tags of a user's notes, most used first, using the list_tags function.
+// Deleted notes are not counted, and neither are notes that are not public if deployedOnly is set,
+// which counts the tags readers see.
+func (db *DB) ListTags(userID string, deployedOnly bool) ([]Tag, error) {
+	tags := []Tag{}
+	err := db.rpc("list_tags", map[string]any{
+		"uid":           userID,
+		"only_deployed": deployedOnly,
+	}, &tags)
+	if err != nil {
+		return nil, fmt.Errorf("list tags: %w", err)
+	}
+	return tags, nil
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// CreateUpload starts a chunked upload for upload.UserID, with upload.Live and upload.ExpiresAt
+// set. The ID and created_at fields are populated by the database.
+func (db *DB) CreateUpload(upload *Upload) error {
+	var created []Upload
+	_, err := db.client.From("sync_uploads").Insert(map[string]any{
+		"user_id":    upload.UserID,
+		"live":       upload.Live,
+		"expires_at": upload.ExpiresAt,
+	}, false, "", "", "").ExecuteTo(&created)
+	if err != nil {
+		return fmt.Errorf("create upload: %w", err)
+	}
+	if len(created) == 0 {
+		return fmt.Errorf("created upload is empty, something went wrong")
+	}
+	*upload = created[0]
+	upload.Chunks = []int{}
+	return nil
+}
+
+// GetUpload returns an upload of a user that has not expired, with the sequence numbers of its
+// received chunks.
+func (db *DB) GetUpload(uploadID, userID string) (*Upload, error) {
+	var upload Upload
+	_, err := db.client.From("sync_uploads").Select("id,user_id,live,created_at,expires_at", "", false).
+		Eq("id", uploadID).Eq("user_id", userID).Gt("expires_at", time.Now().Format(time.RFC3339)).Single().ExecuteTo(&upload)
+	if err != nil {
+		return nil, fmt.Errorf("get upload: %w", err)
+	}
+
+	upload.Chunks = []int{}
+	for page := 0; ; page++ {
+		var chunks []struct {
+			Seq int `json:"seq"`
+		}
+		_, err := db.client.From("sync_upload_chunks").Select("seq", "", false).Eq("upload_id", uploadID).
+			Order("seq", nil).Range(page*pageSize, (page+1)*pageSize-1, "").ExecuteTo(&chunks)
+		if err != nil {
+			return nil, fmt.Errorf("get upload chunks: %w", err)
+		}
+		for _, chunk := range chunks {
+			upload.Chunks = append(upload.Chunks, chunk.Seq)
+		}
+		if len(chunks) < pageSize {
+			return &upload, nil
+		}
+	}
+}
+
+// PutUploadChunk stores the notes of chunk seq of an upload, replacing the chunk if it was already
+// received.
+func (db *DB) PutUploadChunk(uploadID, userID string, seq int, notes []Note) error {
+	if _, err := db.GetUpload(uploadID, userID); err != nil {
+		return fmt.Errorf("put upload chunk: %w", err)
+	}
+	_, _, err := db.client.From("sync_upload_chunks").Upsert(map[string]any{
+		"upload_id": uploadID,
+		"seq":       seq,
+		"notes":     notes,
+	}, "upload_id,seq", "minimal", "").Execute()
+	if err != nil {
+		return fmt.Errorf("put upload chunk: %w", err)
+	}
+	return nil
+}
+
+// GetUploadNotes returns the notes of every received chunk of an upload, in chunk order.
+func (db *DB) GetUploadNotes(uploadID, userID string) ([]Note, error) {
+	if _, err := db.GetUpload(uploadID, userID); err != nil {
+		return nil, fmt.Errorf("get upload notes: %w", err)
+	}
+
+	var notes []Note
+	for page := 0; ; page++ {
+		var chunks []struct {
+			Notes []Note `json:"notes"`
+		}
+		_, err := db.client.From("sync_upload_chunks").Select("notes", "", false).Eq("upload_id", uploadID).
+			Order("seq", nil).Range(page*pageSize, (page+1)*pageSize-1, "").ExecuteTo(&chunks)
+		if err != nil {
+			return nil, fmt.Errorf("get upload notes: %w", err)
+		}
+		for _, chunk := range chunks {
+			notes = append(notes, chunk.Notes...)
+		}
+		if len(chunks) < pageSize {
+			return notes, nil
+		}
+	}
+}
+
+// DeleteUpload deletes an upload of a user and its chunks.
+func (db *DB) DeleteUpload(uploadID, userID string) error {
+	_, _, err := db.client.From("sync_uploads").Delete("minimal", "").Eq("id", uploadID).Eq("user_id", userID).Execute()
+	if err != nil {
+		return fmt.Errorf("delete upload: %w", err)
+	}
+	return nil
+}
+
+// PurgeExpiredUploads deletes the uploads that expired before expiredBefore, with their chunks.
+// It returns how many uploads were deleted.
+func (db *DB) PurgeExpiredUploads(expiredBefore time.Time) (int64, error) {
+	_, count, err := db.client.From("sync_uploads").Delete("minimal", "exact").Lt("expires_at", expiredBefore.Format(time.RFC3339)).Execute()
+	if err != nil {
+		return 0, fmt.Errorf("purge expired uploads: %w", err)
+	}
+	return count, nil
+}
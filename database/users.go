@@ -128,6 +128,16 @@ func (db *DB) SaveProfilePicture(file io.Reader, name string) (string, error) {
 		storage_go.UrlOptions{}).SignedURL, nil
 }
 
+// GetProfilePicture returns the contents and content type of a profile picture saved with
+// SaveProfilePicture.
+func (db *DB) GetProfilePicture(filename string) ([]byte, string, error) {
+	data, err := db.client.Storage.DownloadFile(db.pfps_bucketid, filename)
+	if err != nil {
+		return nil, "", fmt.Errorf("get profile picture: %w", err)
+	}
+	return data, mime.TypeByExtension(filepath.Ext(filename)), nil
+}
+
 // insert stuff
 
 // InsertUser inserts a new user into the database and populated the given user with its ID.
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"fmt"
+)
+
+// ValidVisibility reports whether visibility is one of the visibilities of a note.
+func ValidVisibility(visibility string) bool {
+	switch visibility {
+	case VisibilityPrivate, VisibilityPublic, VisibilityUnlisted, VisibilityProtected:
+		return true
+	}
+	return false
+}
+
+// SetNoteVisibility sets the visibility of a note, deploying it live if it was private and
+// undeploying it if it becomes private. If passwordHash is not empty, it replaces the password of
+// the note. It returns an error wrapping ErrNoRows if the user owns no such note.
+func (db *DB) SetNoteVisibility(noteID, userID, visibility, passwordHash string) error {
+	note, err := db.GetNoteByID(noteID)
+	if err != nil || note.UserID != userID {
+		return fmt.Errorf("set note visibility: %w", ErrNoRows)
+	}
+
+	update := map[string]any{"visibility": visibility}
+	if passwordHash != "" {
+		update["password_hash"] = passwordHash
+	}
+	if !note.Deployed {
+		update["publish_mode"] = PublishLive
+	}
+	_, _, err = db.client.From("notes").Update(update, "minimal", "").Eq("id", noteID).Eq("user_id", userID).Execute()
+	if err != nil {
+		return fmt.Errorf("set note visibility: %w", err)
+	}
+	return nil
+}
+
+// GetNotePasswordHash returns the hash of the password of a note, empty if it never had one.
+func (db *DB) GetNotePasswordHash(noteID string) (string, error) {
+	var rows []struct {
+		PasswordHash string `json:"password_hash"`
+	}
+	_, err := db.client.From("notes").Select("password_hash", "", false).Eq("id", noteID).ExecuteTo(&rows)
+	if err != nil {
+		return "", fmt.Errorf("get note password hash: %w", err)
+	}
+	if len(rows) == 0 {
+		return "", fmt.Errorf("get note password hash: %w", ErrNoRows)
+	}
+	return rows[0].PasswordHash, nil
+}
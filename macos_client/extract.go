@@ -8,6 +8,9 @@ import (
 	"github.com/shashwtd/webnotes/database"
 )
 
+// noteSource is the source of the notes extracted by this client.
+const noteSource = "apple-notes"
+
 // helps and hints from the apple-notes-to-sqlite project (python):
 // github.com/dogsheep/apple-notes-to-sqlite
 
@@ -32,13 +35,14 @@ tell application "Notes"
 			log "%s-created: " & noteCreated
 			log "%s-updated: " & noteUpdated
 			log "%s-folder: " & noteFolderId
+			log "%s-folder-name: " & folderName
 			log "%s-title: " & noteTitle
 			log noteBody
 			log "%s%s"
 		end if
 	end repeat
 end tell
-`, delim, delim, delim, delim, delim, delim, delim)
+`, delim, delim, delim, delim, delim, delim, delim, delim)
 }
 
 // extractNotes runs the AppleScript and parses the output to extract notes.
@@ -68,7 +72,7 @@ func extractNotes() ([]database.Note, error) {
 		switch {
 		case strings.HasPrefix(line, delim+"-id: "):
 			note = database.Note{
-				Source: "apple-notes",
+				Source: noteSource,
 			}
 			bodyLines = nil
 			inNote = true
@@ -83,7 +87,9 @@ func extractNotes() ([]database.Note, error) {
 		case strings.HasPrefix(line, delim+"-title: "):
 			note.Title = strings.TrimPrefix(line, delim+"-title: ")
 		case strings.HasPrefix(line, delim+"-folder: "):
-			// uhhh
+			note.FolderID = strings.TrimPrefix(line, delim+"-folder: ")
+		case strings.HasPrefix(line, delim+"-folder-name: "):
+			note.FolderName = strings.TrimPrefix(line, delim+"-folder-name: ")
 		case line == delim+delim:
 			// End of one note
 			note.Body = strings.Join(bodyLines, "\n")
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/shashwtd/webnotes/database"
+)
+
+// maxChunkSize is the size of the JSON body above which a sync is sent as a chunked upload, and
+// the size chunks are kept under (unless a single note is larger).
+const maxChunkSize = 4 << 20
+
+// pendingUpload is the chunked upload that failed before being committed, if any. The next sync
+// resumes it first, without sending again the chunks the server already has.
+var pendingUpload *chunkedUpload
+
+type chunkedUpload struct {
+	id     string
+	chunks [][]byte // JSON arrays of notes
+}
+
+// apiRequest sends a request to the API with the session token and, if body is not nil, a gzip
+// compressed JSON body. The JSON response is decoded into out. It returns the response status code.
+func apiRequest(session_token, method, path string, body []byte, out any) (int, error) {
+	u, err := url.Parse(API_URL + path)
+	if err != nil {
+		return 0, fmt.Errorf("parsing URL: %w", err) // literally should never happen
+	}
+	header := http.Header{
+		"Cookie": []string{fmt.Sprintf("session_token=%s", session_token)},
+	}
+
+	var reqBody io.ReadCloser = http.NoBody
+	if body != nil {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(body) // writes to a bytes.Buffer never fail
+		zw.Close()
+		reqBody = io.NopCloser(&buf)
+		header.Set("Content-Type", "application/json")
+		header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := http.DefaultClient.Do(&http.Request{
+		Method: method,
+		URL:    u,
+		Header: header,
+		Body:   reqBody,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_ = json.NewDecoder(resp.Body).Decode(out)
+	return resp.StatusCode, nil
+}
+
+// splitChunks splits notes in JSON arrays of at most maxChunkSize bytes, unless a single note is
+// larger than that.
+func splitChunks(notes []database.Note) ([][]byte, error) {
+	var chunks [][]byte
+	chunk := []byte("[")
+	for _, note := range notes {
+		b, err := json.Marshal(note)
+		if err != nil {
+			return nil, fmt.Errorf("marshalling note: %w", err)
+		}
+		if len(chunk) > 1 && len(chunk)+len(b)+1 > maxChunkSize {
+			chunks = append(chunks, append(chunk, ']'))
+			chunk = []byte("[")
+		}
+		if len(chunk) > 1 {
+			chunk = append(chunk, ',')
+		}
+		chunk = append(chunk, b...)
+	}
+	return append(chunks, append(chunk, ']')), nil
+}
+
+// uploadInChunks syncs notes with a chunked upload.
+func uploadInChunks(session_token string, notes []database.Note, live map[string][]string) error {
+	chunks, err := splitChunks(notes)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(map[string]any{"live": live})
+	if err != nil {
+		return fmt.Errorf("marshalling live notes: %w", err)
+	}
+	var beginResp struct {
+		Upload database.Upload `json:"upload"`
+		Error  string          `json:"error"`
+	}
+	status, err := apiRequest(session_token, http.MethodPost, "/notes/uploads", body, &beginResp)
+	if err != nil {
+		return fmt.Errorf("begin upload request: %w", err)
+	}
+	if status != http.StatusCreated {
+		return fmt.Errorf("unexpected status code: %d, error message: %s", status, beginResp.Error)
+	}
+	slog.Info("chunked upload started", "id", beginResp.Upload.ID, "chunks", len(chunks))
+
+	pendingUpload = &chunkedUpload{id: beginResp.Upload.ID, chunks: chunks}
+	return sendUpload(session_token, nil)
+}
+
+// resumeUpload sends the chunks of pendingUpload the server did not receive, and commits it. An
+// upload the server no longer has is dropped, its notes are sent again by the next sync.
+func resumeUpload(session_token string) error {
+	var uploadResp struct {
+		Upload database.Upload `json:"upload"`
+		Error  string          `json:"error"`
+	}
+	status, err := apiRequest(session_token, http.MethodGet, "/notes/uploads/"+pendingUpload.id, nil, &uploadResp)
+	if err != nil {
+		return fmt.Errorf("get upload request: %w", err)
+	}
+	if status == http.StatusNotFound {
+		slog.Warn("chunked upload expired, dropping it", "id", pendingUpload.id)
+		pendingUpload = nil
+		return nil
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d, error message: %s", status, uploadResp.Error)
+	}
+	slog.Info("resuming chunked upload", "id", pendingUpload.id, "received", len(uploadResp.Upload.Chunks), "chunks", len(pendingUpload.chunks))
+	return sendUpload(session_token, uploadResp.Upload.Chunks)
+}
+
+// sendUpload sends the chunks of pendingUpload that are not in received, commits the upload and
+// clears pendingUpload.
+func sendUpload(session_token string, received []int) error {
+	for seq, chunk := range pendingUpload.chunks {
+		if slices.Contains(received, seq) {
+			continue
+		}
+		var chunkResp struct {
+			Error string `json:"error"`
+		}
+		status, err := apiRequest(session_token, http.MethodPut, fmt.Sprintf("/notes/uploads/%s/chunks/%d", pendingUpload.id, seq), chunk, &chunkResp)
+		if err != nil {
+			return fmt.Errorf("put chunk %d request: %w", seq, err)
+		}
+		if status != http.StatusOK {
+			return fmt.Errorf("put chunk %d: unexpected status code: %d, error message: %s", seq, status, chunkResp.Error)
+		}
+	}
+
+	body, err := json.Marshal(map[string]int{"chunks": len(pendingUpload.chunks)})
+	if err != nil {
+		return fmt.Errorf("marshalling commit: %w", err)
+	}
+	var syncResp syncResponse
+	status, err := apiRequest(session_token, http.MethodPost, "/notes/uploads/"+pendingUpload.id+"/commit", body, &syncResp)
+	if err != nil {
+		return fmt.Errorf("commit upload request: %w", err)
+	}
+	if status != http.StatusCreated {
+		return fmt.Errorf("commit upload: unexpected status code: %d, error message: %s", status, syncResp.Error)
+	}
+	syncResp.warnFailed()
+	pendingUpload = nil
+	return nil
+}
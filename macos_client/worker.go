@@ -1,10 +1,8 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
@@ -28,6 +26,13 @@ func init() {
 }
 
 func doWorker(session_token string) error {
+	// finish the chunked upload that failed last time, if any
+	if pendingUpload != nil {
+		if err := resumeUpload(session_token); err != nil {
+			return fmt.Errorf("resuming upload: %w", err)
+		}
+	}
+
 	notes, err := extractNotes()
 	if err != nil {
 		return fmt.Errorf("extraction: %w", err)
@@ -37,46 +42,159 @@ func doWorker(session_token string) error {
 		slog.Info("note", "id", note.SourceIdentifier, "title", note.Title, "created", note.CreatedAt, "updated", note.UpdatedAt)
 	}
 
+	// never upload the notes the user excluded, the server would drop them anyway
+	rules, err := getSyncRules(session_token)
+	if err != nil {
+		slog.Warn("could not get the sync rules, the server will apply them", "error", err)
+	} else {
+		notes = withoutExcluded(notes, rules)
+	}
+
+	// only upload the notes the server does not have yet, or has an older version of
+	changed := notes
+	manifest, err := getManifest(session_token)
+	if err != nil {
+		slog.Warn("could not get the note manifest, uploading every note", "error", err)
+	} else {
+		changed = changedNotes(notes, manifest)
+	}
+
 	// write to DB
-	err = writeDB(session_token, notes)
+	err = writeDB(session_token, changed, notes)
 	if err != nil {
 		return fmt.Errorf("writing to DB: %w", err)
 	}
-	slog.Info("notes written to DB", "count", len(notes), "time", time.Now().Format(time.RFC3339))
+	slog.Info("notes written to DB", "count", len(changed), "time", time.Now().Format(time.RFC3339))
 	slog.Info("operation completed successfully", "time", time.Now().Format(time.RFC3339))
 	return nil
 }
 
-func writeDB(session_token string, notes []database.Note) error {
-	u, err := url.Parse(API_URL + "/notes/list")
+// getManifest returns what the server stores about the synced apple notes, by source identifier.
+func getManifest(session_token string) (map[string]database.ManifestEntry, error) {
+	var manifestResp struct {
+		Manifest []database.ManifestEntry `json:"manifest"`
+		Error    string                   `json:"error"`
+	}
+	status, err := apiRequest(session_token, http.MethodGet, "/notes/manifest?source="+url.QueryEscape(noteSource), nil, &manifestResp)
 	if err != nil {
-		return fmt.Errorf("parsing URL: %w", err) // literally should never happen
+		return nil, fmt.Errorf("get manifest request: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d, error message: %s", status, manifestResp.Error)
 	}
-	body, err := json.Marshal(notes)
+
+	manifest := make(map[string]database.ManifestEntry, len(manifestResp.Manifest))
+	for _, entry := range manifestResp.Manifest {
+		manifest[entry.SourceIdentifier] = entry
+	}
+	return manifest, nil
+}
+
+// getSyncRules returns the sync rules of the user.
+func getSyncRules(session_token string) ([]database.SyncRule, error) {
+	var rulesResp struct {
+		Rules []database.SyncRule `json:"rules"`
+		Error string              `json:"error"`
+	}
+	status, err := apiRequest(session_token, http.MethodGet, "/notes/rules", nil, &rulesResp)
 	if err != nil {
-		return fmt.Errorf("marshalling notes: %w", err)
+		return nil, fmt.Errorf("get sync rules request: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d, error message: %s", status, rulesResp.Error)
+	}
+	return rulesResp.Rules, nil
+}
+
+// withoutExcluded returns the notes that no exclude rule matches. Excluded notes are also left out
+// of the live notes, so the server deletes the copies it has of them.
+func withoutExcluded(notes []database.Note, rules []database.SyncRule) []database.Note {
+	var kept []database.Note
+	for _, note := range notes {
+		if rule := database.MatchRule(rules, &note); rule != nil && rule.Action == database.RuleExclude {
+			slog.Info("note excluded by a sync rule", "id", note.SourceIdentifier, "rule", rule.ID)
+			continue
+		}
+		kept = append(kept, note)
+	}
+	return kept
+}
+
+// changedNotes returns the notes that are missing from the manifest or differ from their entry.
+func changedNotes(notes []database.Note, manifest map[string]database.ManifestEntry) []database.Note {
+	var changed []database.Note
+	for _, note := range notes {
+		entry, ok := manifest[note.SourceIdentifier]
+		if ok && entry.ContentHash == database.ContentHash(&note) && sameTime(entry.UpdatedAt, note.UpdatedAt) &&
+			entry.FolderID == note.FolderID && entry.FolderName == note.FolderName { // moving a note keeps its modification date
+			continue
+		}
+		changed = append(changed, note)
+	}
+	return changed
+}
+
+// sameTime reports whether two timestamps are the same instant. The server formats them its own
+// way, so they are compared as times. Timestamps that cannot be parsed are never the same.
+func sameTime(a, b string) bool {
+	ta, errA := parseTime(a)
+	tb, errB := parseTime(b)
+	return errA == nil && errB == nil && ta.Equal(tb)
+}
+
+func parseTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		t, err = time.Parse("2006-01-02T15:04:05", s) // «class isot», no time zone
+	}
+	return t, err
+}
+
+// writeDB uploads the given notes. live must hold every extracted note, so the server can mark the
+// others as deleted. Syncs larger than maxChunkSize are sent as a chunked upload.
+func writeDB(session_token string, notes, live []database.Note) error {
+	// every note that exists in apple notes right now, so the server can mark the others as deleted
+	ids := make([]string, 0, len(live))
+	for _, note := range live {
+		ids = append(ids, note.SourceIdentifier)
 	}
-	resp, err := http.DefaultClient.Do(&http.Request{
-		Method: http.MethodPost,
-		URL:    u,
-		Header: http.Header{
-			"Content-Type": {"application/json"},
-			"Cookie":       []string{fmt.Sprintf("session_token=%s", session_token)},
-		},
-		Body: io.NopCloser(bytes.NewReader(body)),
+	liveMap := map[string][]string{noteSource: ids}
+
+	body, err := json.Marshal(database.SyncRequest{
+		Notes: notes,
+		Live:  liveMap,
 	})
+	if err != nil {
+		return fmt.Errorf("marshalling notes: %w", err)
+	}
+	if len(body) > maxChunkSize {
+		return uploadInChunks(session_token, notes, liveMap)
+	}
+
+	var syncResp syncResponse
+	status, err := apiRequest(session_token, http.MethodPost, "/notes/list", body, &syncResp)
 	if err != nil {
 		return fmt.Errorf("post notes request: %w", err)
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusCreated { // leave on success
+	if status == http.StatusCreated { // leave on success
+		syncResp.warnFailed()
 		return nil
 	}
-	type errorResponse struct {
-		Error string `json:"error"`
-	}
-	var errResp errorResponse
-	_ = json.NewDecoder(resp.Body).Decode(&errResp)
 
-	return fmt.Errorf("unexpected status code: %d, error message: %s", resp.StatusCode, errResp.Error)
+	return fmt.Errorf("unexpected status code: %d, error message: %s", status, syncResp.Error)
+}
+
+// syncResponse is the response of a sync, in one request or committed from a chunked upload.
+type syncResponse struct {
+	Results []database.SyncResult `json:"results"`
+	Error   string                `json:"error"`
+}
+
+// warnFailed logs the notes the server could not sync.
+func (r *syncResponse) warnFailed() {
+	for _, result := range r.Results {
+		if result.Status == database.SyncFailed {
+			slog.Warn("note not synced", "id", result.SourceIdentifier, "reason", result.Reason)
+		}
+	}
 }